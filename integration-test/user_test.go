@@ -57,7 +57,7 @@ func (s *UserRegisterSuite) TearDownSuite() {
 func (s *UserRegisterSuite) TestUserRegisterSuccess() {
 	response, err := s.client.R().
 		SetHeader("Content-Type", "application/json").
-		SetBody(`{"login":"login", "password":"password"}`).
+		SetBody(`{"login":"login", "password":"Str0ngPassw0rd"}`).
 		Post(registerURL)
 	if err != nil {
 		fmt.Println(err.Error())
@@ -83,7 +83,7 @@ func (s *UserRegisterSuite) TestUserRegisterLoginIsAlreadyUsed() {
 
 	response, _ := s.client.R().
 		SetHeader("Content-Type", "application/json").
-		SetBody(fmt.Sprintf(`{"login": "%s", "password":"password"}`, dummyLogin)).
+		SetBody(fmt.Sprintf(`{"login": "%s", "password":"Str0ngPassw0rd"}`, dummyLogin)).
 		Post(registerURL)
 	s.Equal(http.StatusConflict, response.StatusCode())
 }
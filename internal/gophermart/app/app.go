@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/apolsh/yapr-gophermart/config"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/client"
 	httpController "github.com/apolsh/yapr-gophermart/internal/gophermart/controller/httpserver"
 	"github.com/apolsh/yapr-gophermart/internal/gophermart/service"
 	postgresStorage "github.com/apolsh/yapr-gophermart/internal/gophermart/storage/postgres"
@@ -37,6 +38,15 @@ func Run(cfg *config.Config) {
 
 	var userStorage service.UserStorage
 	var orderStorage service.OrderStorage
+	var refreshTokenStorage service.RefreshTokenStorage
+	var revokedTokenStorage service.RevokedTokenStorage
+	var loginAttemptStorage service.LoginAttemptStorage
+	var requestLimitStorage service.RequestLimitStorage
+	var sessionStorage service.SessionStorage
+	var apiKeyStorage service.APIKeyStorage
+	var passwordResetStorage service.PasswordResetStorage
+	var webhookStorage service.WebhookStorage
+	var accrualJobStorage service.AccrualJobStorage
 
 	if cfg.DatabaseType == config.PostgresStorageType {
 		_, err := pgxpool.ParseConfig(cfg.DatabaseURI)
@@ -59,16 +69,160 @@ func Run(cfg *config.Config) {
 		defer pool.Close()
 		orderStorage = postgresStorage.NewOrderStoragePG(pool)
 		userStorage = postgresStorage.NewUserStoragePG(pool)
+		refreshTokenStorage = postgresStorage.NewRefreshTokenStoragePG(pool)
+		revokedTokenStorage = postgresStorage.NewRevokedTokenStoragePG(pool)
+		loginAttemptStorage = postgresStorage.NewLoginAttemptStoragePG(pool)
+		requestLimitStorage = postgresStorage.NewRequestLimitStoragePG(pool)
+		sessionStorage = postgresStorage.NewSessionStoragePG(pool)
+		apiKeyStorage = postgresStorage.NewAPIKeyStoragePG(pool)
+		passwordResetStorage = postgresStorage.NewPasswordResetStoragePG(pool)
+		webhookStorage = postgresStorage.NewWebhookStoragePG(pool)
+		accrualJobStorage = postgresStorage.NewAccrualJobStoragePG(pool)
 	}
 
-	gophermartService, err := service.NewGophermartServiceImpl(cfg.TokenSecretKey, cfg.LoyaltyServiceMaxTries, cfg.AccrualSystemAddress, userStorage, orderStorage)
+	tokenKeys, err := service.NewTokenKeySet(cfg.TokenSigningKeyFiles, cfg.TokenSecretKey, cfg.EphemeralTokenKey)
+	if err != nil {
+		log.Fatal(fmt.Errorf("error while loading token keys: %w", err))
+	}
+
+	passwordHasher, err := service.NewPasswordHasher(
+		cfg.PasswordHashAlgorithm,
+		service.Argon2idParams{
+			Memory:      uint32(cfg.Argon2Memory),
+			Iterations:  uint32(cfg.Argon2Iterations),
+			Parallelism: uint8(cfg.Argon2Parallelism),
+			SaltLength:  16,
+			KeyLength:   32,
+		},
+		cfg.BcryptCost,
+		cfg.PasswordPepper)
+	if err != nil {
+		log.Fatal(fmt.Errorf("error while init password hasher: %w", err))
+	}
+
+	twoFactor := service.TwoFactorOptions{Issuer: cfg.TOTPIssuer, ChallengeTTL: cfg.TwoFactorChallengeTTL}
+	if cfg.TOTPEncryptionKey != "" {
+		twoFactor.Cipher, err = service.NewSecretCipher(cfg.TOTPEncryptionKey)
+		if err != nil {
+			log.Fatal(fmt.Errorf("error while init totp secret cipher: %w", err))
+		}
+	} else {
+		log.Warn("TOTP_ENCRYPTION_KEY is not set, two-factor authentication can't be enrolled")
+	}
+
+	var oidcProvider client.OIDCProvider
+	if cfg.OIDCDiscoveryURL != "" {
+		oidcProvider, err = client.NewOIDCProviderImpl(client.OIDCConfig{
+			DiscoveryURL: cfg.OIDCDiscoveryURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+		})
+		if err != nil {
+			log.Fatal(fmt.Errorf("error while init oidc provider: %w", err))
+		}
+	}
+
+	var mailer client.Mailer
+	if cfg.SMTPAddress != "" {
+		mailer = client.NewSMTPMailer(cfg.SMTPAddress, cfg.MailFrom, cfg.SMTPUsername, cfg.SMTPPassword)
+	} else if cfg.MailerFile != "" {
+		mailer = client.NewFileMailer(cfg.MailerFile)
+	}
+	emailVerification := service.EmailVerificationOptions{
+		Required: cfg.RequireEmailLogin,
+		Mailer:   mailer,
+		LinkURL:  cfg.EmailVerificationURL,
+		TTL:      cfg.EmailVerificationTTL,
+	}
+	passwordReset := service.PasswordResetOptions{
+		TTL:                 cfg.PasswordResetTTL,
+		MaxRequestsPerLogin: cfg.PasswordResetLimit,
+	}
+	if cfg.PasswordResetURL != "" {
+		passwordReset.Notifier = service.NewMailPasswordResetNotifier(mailer, cfg.PasswordResetURL)
+	}
+
+	webhooks := service.WebhookOptions{
+		Sender:              client.NewHTTPWebhookSender(cfg.WebhookTimeout, cfg.WebhookAllowPrivate),
+		MaxAttempts:         cfg.WebhookMaxAttempts,
+		Lease:               cfg.WebhookTimeout + time.Minute,
+		TOTPCipher:          twoFactor.Cipher,
+		AllowPrivateTargets: cfg.WebhookAllowPrivate,
+	}
+	if cfg.WebhookEncryptionKey != "" {
+		webhooks.Cipher, err = service.NewSecretCipher(cfg.WebhookEncryptionKey)
+		if err != nil {
+			log.Fatal(fmt.Errorf("error while init webhook secret cipher: %w", err))
+		}
+	} else {
+		log.Warn("WEBHOOK_ENCRYPTION_KEY is not set, webhooks can't be created")
+	}
+
+	gophermartService, err := service.NewGophermartServiceImpl(
+		tokenKeys,
+		cfg.AccessTokenTTL,
+		cfg.RefreshTokenTTL,
+		cfg.LoyaltyServiceMaxTries,
+		cfg.OrderRecheckLimit,
+		cfg.AccrualSystemAddress,
+		cfg.LoyaltyServiceRateLimit,
+		service.LoginThrottlePolicy{
+			MaxFailuresPerLogin: cfg.LoginMaxFailures,
+			MaxFailuresPerIP:    cfg.LoginMaxFailuresPerIP,
+			FailureWindow:       cfg.LoginFailureWindow,
+			BaseLockout:         cfg.LoginBaseLockout,
+			MaxLockout:          cfg.LoginMaxLockout,
+		},
+		cfg.RequestLimitWindow,
+		service.PasswordPolicy{
+			MinLength:      cfg.PasswordMinLength,
+			MinCharClasses: cfg.PasswordMinCharClasses,
+		},
+		passwordHasher,
+		twoFactor,
+		cfg.DataRetentionPeriod,
+		oidcProvider,
+		emailVerification,
+		passwordReset,
+		webhooks,
+		userStorage,
+		orderStorage,
+		refreshTokenStorage,
+		revokedTokenStorage,
+		loginAttemptStorage,
+		requestLimitStorage,
+		sessionStorage,
+		apiKeyStorage,
+		passwordResetStorage,
+		webhookStorage,
+		accrualJobStorage)
 	if err != nil {
 		log.Fatal(fmt.Errorf("error while init app: %w", err))
 	}
-	err = gophermartService.StartAccrualInfoSynchronizer(context.Background(), cfg.LoyaltyServiceRateLimit)
+	err = gophermartService.MigrateLogins(context.Background())
+	if err != nil {
+		log.Fatal(fmt.Errorf("error while normalizing logins: %w", err))
+	}
+	err = gophermartService.EncryptWebhookSecrets(context.Background())
+	if err != nil {
+		log.Fatal(fmt.Errorf("error while encrypting webhook secrets: %w", err))
+	}
+	if cfg.BootstrapAdminLogin != "" {
+		err = gophermartService.BootstrapAdmin(context.Background(), cfg.BootstrapAdminLogin, cfg.BootstrapAdminPassword)
+		if err != nil {
+			log.Fatal(fmt.Errorf("error while bootstrapping admin user: %w", err))
+		}
+	}
+	backgroundCtx, stopBackgroundJobs := context.WithCancel(context.Background())
+	err = gophermartService.StartAccrualInfoSynchronizer(backgroundCtx, cfg.AccrualWorkers)
 	if err != nil {
 		log.Fatal(fmt.Errorf("error while init app: %w", err))
 	}
+	gophermartService.StartDeletedUserPurger(backgroundCtx)
+	gophermartService.StartAccrualReconciler(backgroundCtx, cfg.ReconciliationInterval, cfg.ReconciliationWindow)
+	gophermartService.StartOrderEventsListener(backgroundCtx)
+	gophermartService.StartWebhookDispatcher(backgroundCtx)
 
 	r := chi.NewRouter()
 	httpController.RegisterRoutes(r, gophermartService)
@@ -79,11 +233,11 @@ func Run(cfg *config.Config) {
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGQUIT)
 
 	httpServerConfigs := &http.Server{
-		Addr:         cfg.RunAddress,
-		Handler:      r,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  15 * time.Second,
+		Addr:        cfg.RunAddress,
+		Handler:     r,
+		ReadTimeout: 5 * time.Second,
+		// no WriteTimeout, order event streams are long-lived, other requests are limited by the router
+		IdleTimeout: 15 * time.Second,
 	}
 
 	httpServer := httpController.NewServer(httpServerConfigs)
@@ -95,6 +249,8 @@ func Run(cfg *config.Config) {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
+		// stopping the background jobs closes the order event streams, which would hold up the shutdown otherwise
+		stopBackgroundJobs()
 		if err := httpServer.Stop(ctx); err != nil {
 			log.Fatal(fmt.Errorf("could not gracefully shutdown the http server: %v", err))
 		}
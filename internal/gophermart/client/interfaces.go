@@ -11,6 +11,44 @@ type LoyaltyService interface {
 	GetLoyaltyPoints(ctx context.Context, orderNum string) (LoyaltyPointsInfo, error)
 }
 
+type OIDCProvider interface {
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier string) (OIDCIDToken, error)
+}
+
+// OIDCIDToken holds the verified claims of an ID token. The nonce must still be checked by the caller.
+type OIDCIDToken struct {
+	Issuer            string
+	Subject           string
+	Nonce             string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// WebhookSender posts an event to a webhook and returns the status code of the response.
+type WebhookSender interface {
+	Send(ctx context.Context, request WebhookRequest) (int, error)
+}
+
+type WebhookRequest struct {
+	URL        string
+	Secret     string
+	EventType  string
+	DeliveryID int64
+	Payload    []byte
+}
+
 type LoyaltyPointsInfo struct {
 	Order   string          `json:"order"`
 	Status  string          `json:"status"`
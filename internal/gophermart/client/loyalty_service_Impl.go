@@ -2,8 +2,12 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 )
@@ -11,6 +15,22 @@ import (
 type LoyaltyServiceImpl struct {
 	client  *resty.Client
 	baseURL string
+	limiter *RateLimiter
+}
+
+// TooManyRequestsError is returned when the loyalty service asks to slow down, the limiter is paused for RetryAfter
+// and follows Limit requests per minute afterwards if the service advertised it.
+type TooManyRequestsError struct {
+	RetryAfter time.Duration
+	Limit      int
+}
+
+func (e *TooManyRequestsError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyRequests, e.RetryAfter)
+}
+
+func (e *TooManyRequestsError) Is(target error) bool {
+	return target == ErrTooManyRequests
 }
 
 const (
@@ -20,13 +40,25 @@ const (
 	StatusProcessed  = "PROCESSED"
 )
 
-func NewLoyaltyServiceImpl(baseURL string) (LoyaltyService, error) {
+// defaultRetryAfter is used if a 429 response has no valid Retry-After header
+const defaultRetryAfter = time.Minute
+
+var advertisedRateLimit = regexp.MustCompile(`No more than (\d+) requests per minute allowed`)
+
+// NewLoyaltyServiceImpl returns a client whose requests share the limiter, even if they are made concurrently.
+func NewLoyaltyServiceImpl(baseURL string, limiter *RateLimiter) (LoyaltyService, error) {
+	if limiter == nil {
+		return nil, errors.New("loyalty service rate limiter was not initialized")
+	}
 	client := resty.New()
-	return &LoyaltyServiceImpl{client: client, baseURL: baseURL}, nil
+	return &LoyaltyServiceImpl{client: client, baseURL: baseURL, limiter: limiter}, nil
 }
 
 func (l LoyaltyServiceImpl) GetLoyaltyPoints(ctx context.Context, orderNum string) (LoyaltyPointsInfo, error) {
 	loyaltyPointsRes := LoyaltyPointsInfo{}
+	if err := l.limiter.Wait(ctx); err != nil {
+		return loyaltyPointsRes, err
+	}
 	response, err := l.client.R().
 		SetContext(ctx).
 		SetResult(&loyaltyPointsRes).
@@ -38,7 +70,7 @@ func (l LoyaltyServiceImpl) GetLoyaltyPoints(ctx context.Context, orderNum strin
 		return loyaltyPointsRes, ErrOrderIsNotRegisteredYet
 	}
 	if response.StatusCode() == http.StatusTooManyRequests {
-		return loyaltyPointsRes, ErrTooManyRequests
+		return loyaltyPointsRes, l.slowDown(response)
 	}
 	if response.StatusCode() == http.StatusInternalServerError {
 		return loyaltyPointsRes, ErrUnknownLoyaltyService
@@ -46,3 +78,31 @@ func (l LoyaltyServiceImpl) GetLoyaltyPoints(ctx context.Context, orderNum strin
 
 	return loyaltyPointsRes, nil
 }
+
+// slowDown pauses all the requests as the 429 response demands and adopts the advertised rate.
+func (l LoyaltyServiceImpl) slowDown(response *resty.Response) error {
+	now := time.Now()
+	tooManyRequests := &TooManyRequestsError{RetryAfter: parseRetryAfter(response.Header().Get("Retry-After"), now)}
+	if match := advertisedRateLimit.FindStringSubmatch(response.String()); match != nil {
+		if limit, err := strconv.Atoi(match[1]); err == nil && limit > 0 {
+			tooManyRequests.Limit = limit
+			l.limiter.SetLimit(limit)
+		}
+	}
+	l.limiter.Pause(now.Add(tooManyRequests.RetryAfter))
+	return tooManyRequests
+}
+
+// parseRetryAfter accepts both forms of the header, the delay in seconds and the HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if date.After(now) {
+			return date.Sub(now)
+		}
+		return 0
+	}
+	return defaultRetryAfter
+}
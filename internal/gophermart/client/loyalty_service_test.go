@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoyaltyServiceSuite struct {
+	suite.Suite
+}
+
+func TestLoyaltyServiceSuite(t *testing.T) {
+	suite.Run(t, new(LoyaltyServiceSuite))
+}
+
+func (s *LoyaltyServiceSuite) TestParseRetryAfter() {
+	now := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"30", 30 * time.Second},
+		{"0", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"", defaultRetryAfter},
+		{"-5", defaultRetryAfter},
+		{"soon", defaultRetryAfter},
+	}
+	for _, test := range tests {
+		assert.Equal(s.T(), test.expected, parseRetryAfter(test.value, now), test.value)
+	}
+}
+
+func (s *LoyaltyServiceSuite) TestRateLimiterRefill() {
+	limiter := NewRateLimiter(120)
+	start := limiter.refilledAt
+
+	// the burst is the requests of a second
+	for i := 0; i < 2; i++ {
+		_, ok := limiter.take(start)
+		s.Require().True(ok)
+	}
+	wait, ok := limiter.take(start)
+	s.Require().False(ok)
+	assert.Equal(s.T(), 500*time.Millisecond, wait)
+
+	_, ok = limiter.take(start.Add(500 * time.Millisecond))
+	assert.True(s.T(), ok)
+
+	// the tokens don't pile up beyond the burst
+	for i := 0; i < 2; i++ {
+		_, ok = limiter.take(start.Add(time.Hour))
+		s.Require().True(ok)
+	}
+	_, ok = limiter.take(start.Add(time.Hour))
+	assert.False(s.T(), ok)
+}
+
+func (s *LoyaltyServiceSuite) TestRateLimiterPause() {
+	limiter := NewRateLimiter(60)
+	start := limiter.refilledAt
+
+	limiter.Pause(start.Add(10 * time.Second))
+	// an earlier pause doesn't shorten the current one
+	limiter.Pause(start.Add(5 * time.Second))
+
+	wait, ok := limiter.take(start.Add(time.Second))
+	s.Require().False(ok)
+	assert.Equal(s.T(), 9*time.Second, wait)
+
+	// the bucket is refilled from empty after the pause
+	wait, ok = limiter.take(start.Add(10 * time.Second))
+	s.Require().False(ok)
+	assert.Equal(s.T(), time.Second, wait)
+	_, ok = limiter.take(start.Add(11 * time.Second))
+	assert.True(s.T(), ok)
+}
+
+func (s *LoyaltyServiceSuite) TestRateLimiterSetLimit() {
+	limiter := NewRateLimiter(600)
+
+	limiter.SetLimit(0)
+	assert.Equal(s.T(), 600, limiter.Limit())
+
+	limiter.SetLimit(5)
+	assert.Equal(s.T(), 5, limiter.Limit())
+	assert.Equal(s.T(), 1.0, limiter.tokens)
+}
+
+func (s *LoyaltyServiceSuite) TestRateLimiterWaitIsCanceled() {
+	limiter := NewRateLimiter(60)
+	limiter.Pause(time.Now().Add(time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(s.T(), limiter.Wait(ctx), context.DeadlineExceeded)
+}
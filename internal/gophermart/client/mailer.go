@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errInvalidMailHeader = errors.New("mail header must not contain line breaks")
+
+// SMTPMailer sends plain text mails through an SMTP relay, authenticating with PLAIN auth if a username is set.
+type SMTPMailer struct {
+	address string
+	from    string
+	auth    smtp.Auth
+}
+
+// FileMailer appends mails to a file instead of sending them, it is meant for development and tests.
+type FileMailer struct {
+	mu   sync.Mutex
+	path string
+}
+
+// MemoryMailer keeps sent mails in memory, it is meant for tests.
+type MemoryMailer struct {
+	mu    sync.Mutex
+	mails []Mail
+}
+
+func NewSMTPMailer(address, from, username, password string) *SMTPMailer {
+	m := &SMTPMailer{address: address, from: from}
+	if username != "" {
+		host := address
+		if i := strings.LastIndex(address, ":"); i >= 0 {
+			host = address[:i]
+		}
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func NewFileMailer(path string) *FileMailer {
+	return &FileMailer{path: path}
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *SMTPMailer) Send(_ context.Context, mail Mail) error {
+	message, err := formatMail(m.from, mail)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.address, m.auth, m.from, []string{mail.To}, message)
+}
+
+func (m *FileMailer) Send(_ context.Context, mail Mail) error {
+	message, err := formatMail("gophermart", mail)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error during opening mail file %s, cause: %w", m.path, err)
+	}
+	defer file.Close()
+	_, err = fmt.Fprintf(file, "%s\r\n\r\n", message)
+	return err
+}
+
+func (m *MemoryMailer) Send(_ context.Context, mail Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mails = append(m.mails, mail)
+	return nil
+}
+
+// Mails returns the mails sent so far.
+func (m *MemoryMailer) Mails() []Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Mail(nil), m.mails...)
+}
+
+func formatMail(from string, mail Mail) ([]byte, error) {
+	for _, header := range []string{from, mail.To, mail.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, errInvalidMailHeader
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", mail.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", mail.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+	return []byte(b.String()), nil
+}
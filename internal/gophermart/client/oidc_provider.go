@@ -0,0 +1,297 @@
+package client
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	oidcDiscoveryPath = "/.well-known/openid-configuration"
+	oidcScopes        = "openid profile email"
+	// oidcKeysRefreshInterval limits how often the keys are fetched again because of an unknown key id
+	oidcKeysRefreshInterval = time.Minute
+)
+
+var (
+	ErrOIDCProviderUnavailable = errors.New("oidc provider is unavailable")
+	ErrOIDCCodeRejected        = errors.New("oidc provider rejected the authorization code")
+	ErrOIDCInvalidIDToken      = errors.New("invalid oidc id token")
+)
+
+type OIDCConfig struct {
+	DiscoveryURL string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcTokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+type oidcIDTokenClaims struct {
+	jwt.RegisteredClaims
+	AuthorizedParty   string `json:"azp,omitempty"`
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     bool   `json:"email_verified,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+}
+
+// OIDCProviderImpl is an OpenID Connect relying party for the authorization code flow with PKCE. The provider
+// metadata is discovered on first use, the signing keys are fetched again if a token is signed by an unknown key.
+type OIDCProviderImpl struct {
+	client *resty.Client
+	config OIDCConfig
+
+	mu            sync.Mutex
+	metadata      *oidcMetadata
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+func NewOIDCProviderImpl(config OIDCConfig) (OIDCProvider, error) {
+	if config.DiscoveryURL == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("oidc discovery url, client id and redirect url are required")
+	}
+	discoveryURL := strings.TrimSuffix(config.DiscoveryURL, "/")
+	if !strings.HasSuffix(discoveryURL, oidcDiscoveryPath) {
+		discoveryURL += oidcDiscoveryPath
+	}
+	config.DiscoveryURL = discoveryURL
+
+	return &OIDCProviderImpl{client: resty.New().SetTimeout(10 * time.Second), config: config}, nil
+}
+
+func (p *OIDCProviderImpl) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	metadata, err := p.getMetadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {oidcScopes},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+func (p *OIDCProviderImpl) Exchange(ctx context.Context, code, codeVerifier string) (OIDCIDToken, error) {
+	metadata, err := p.getMetadata(ctx)
+	if err != nil {
+		return OIDCIDToken{}, err
+	}
+
+	form := map[string]string{
+		"grant_type":    "authorization_code",
+		"code":          code,
+		"redirect_uri":  p.config.RedirectURL,
+		"code_verifier": codeVerifier,
+	}
+	tokenResponse := oidcTokenResponse{}
+	request := p.client.R().SetContext(ctx).SetFormData(form).SetResult(&tokenResponse)
+	if p.config.ClientSecret != "" {
+		// client_secret_basic, the credentials are form encoded first, see RFC 6749, section 2.3.1
+		request.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	} else {
+		request.SetFormData(map[string]string{"client_id": p.config.ClientID})
+	}
+
+	response, err := request.Post(metadata.TokenEndpoint)
+	if err != nil {
+		return OIDCIDToken{}, fmt.Errorf("%w: %s", ErrOIDCProviderUnavailable, err)
+	}
+	if response.StatusCode() >= 400 && response.StatusCode() < 500 {
+		return OIDCIDToken{}, fmt.Errorf("%w: %s", ErrOIDCCodeRejected, response.String())
+	}
+	if !response.IsSuccess() {
+		return OIDCIDToken{}, fmt.Errorf("%w: token endpoint responded with %d", ErrOIDCProviderUnavailable, response.StatusCode())
+	}
+	if tokenResponse.IDToken == "" {
+		return OIDCIDToken{}, fmt.Errorf("%w: token response has no id token", ErrOIDCInvalidIDToken)
+	}
+
+	return p.verifyIDToken(ctx, metadata, tokenResponse.IDToken)
+}
+
+func (p *OIDCProviderImpl) verifyIDToken(ctx context.Context, metadata *oidcMetadata, rawIDToken string) (OIDCIDToken, error) {
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.getKey(ctx, kid)
+	}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}))
+	token, err := parser.ParseWithClaims(rawIDToken, &oidcIDTokenClaims{}, keyFunc)
+	if err != nil {
+		return OIDCIDToken{}, fmt.Errorf("%w: %s", ErrOIDCInvalidIDToken, err)
+	}
+
+	claims := token.Claims.(*oidcIDTokenClaims)
+	switch {
+	case claims.ExpiresAt == nil:
+		return OIDCIDToken{}, fmt.Errorf("%w: token has no expiration time", ErrOIDCInvalidIDToken)
+	case claims.Subject == "":
+		return OIDCIDToken{}, fmt.Errorf("%w: token has no subject", ErrOIDCInvalidIDToken)
+	case claims.Issuer != metadata.Issuer:
+		return OIDCIDToken{}, fmt.Errorf("%w: unexpected issuer %s", ErrOIDCInvalidIDToken, claims.Issuer)
+	case !claims.VerifyAudience(p.config.ClientID, true):
+		return OIDCIDToken{}, fmt.Errorf("%w: token is issued for another client", ErrOIDCInvalidIDToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID:
+		return OIDCIDToken{}, fmt.Errorf("%w: token is authorized for another client", ErrOIDCInvalidIDToken)
+	}
+
+	return OIDCIDToken{
+		Issuer:            claims.Issuer,
+		Subject:           claims.Subject,
+		Nonce:             claims.Nonce,
+		Email:             claims.Email,
+		EmailVerified:     claims.EmailVerified,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+func (p *OIDCProviderImpl) getMetadata(ctx context.Context) (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	metadata := &oidcMetadata{}
+	response, err := p.client.R().SetContext(ctx).SetResult(metadata).Get(p.config.DiscoveryURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrOIDCProviderUnavailable, err)
+	}
+	if !response.IsSuccess() {
+		return nil, fmt.Errorf("%w: discovery endpoint responded with %d", ErrOIDCProviderUnavailable, response.StatusCode())
+	}
+	if metadata.Issuer == "" || metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: incomplete provider metadata", ErrOIDCProviderUnavailable)
+	}
+	p.metadata = metadata
+	return metadata, nil
+}
+
+func (p *OIDCProviderImpl) getKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < oidcKeysRefreshInterval {
+		return nil, fmt.Errorf("unknown key id %s", kid)
+	}
+
+	jwks := dto.JWKS{}
+	response, err := p.client.R().SetContext(ctx).SetResult(&jwks).Get(p.metadata.JWKSURI)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrOIDCProviderUnavailable, err)
+	}
+	if !response.IsSuccess() {
+		return nil, fmt.Errorf("%w: jwks endpoint responded with %d", ErrOIDCProviderUnavailable, response.StatusCode())
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(jwk)
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %s", kid)
+}
+
+// lookupKey finds the key by its id, a token without key id can only be verified if the provider has a single key.
+func (p *OIDCProviderImpl) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func parseJWK(jwk dto.JWK) (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if jwk.Curve != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %s", jwk.Curve)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", jwk.Curve)
+		}
+		x, err := decode(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", jwk.KeyType)
+	}
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket shared by all the requests to a service. The service may pause the requests and
+// change the rate at any time, the requests waiting meanwhile follow the new limit.
+type RateLimiter struct {
+	mu          sync.Mutex
+	perMinute   int
+	tokens      float64
+	refilledAt  time.Time
+	pausedUntil time.Time
+}
+
+// NewRateLimiter returns a limiter allowing perMinute requests per minute, the bucket starts full.
+func NewRateLimiter(perMinute int) *RateLimiter {
+	l := &RateLimiter{perMinute: perMinute, refilledAt: time.Now()}
+	l.tokens = l.burst()
+	return l
+}
+
+// Wait blocks until a request may be sent or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		wait, ok := l.take(time.Now())
+		if ok {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Pause stops the requests until the given time, the bucket is refilled from empty afterwards.
+func (l *RateLimiter) Pause(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !until.After(l.pausedUntil) {
+		return
+	}
+	l.pausedUntil = until
+	l.tokens = 0
+	l.refilledAt = until
+}
+
+// SetLimit changes the number of requests per minute, the tokens collected so far are kept up to the new burst.
+func (l *RateLimiter) SetLimit(perMinute int) {
+	if perMinute < 1 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+	l.perMinute = perMinute
+	l.tokens = math.Min(l.tokens, l.burst())
+}
+
+// Limit returns the current number of requests per minute.
+func (l *RateLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.perMinute
+}
+
+// take consumes a token, otherwise it returns how long to wait before trying again.
+func (l *RateLimiter) take(now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now), false
+	}
+	l.refill(now)
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - l.tokens) * float64(time.Minute) / float64(l.perMinute)), false
+}
+
+func (l *RateLimiter) refill(now time.Time) {
+	if !now.After(l.refilledAt) {
+		return
+	}
+	l.tokens = math.Min(l.burst(), l.tokens+now.Sub(l.refilledAt).Minutes()*float64(l.perMinute))
+	l.refilledAt = now
+}
+
+// burst allows the requests of a second at once, so that a slow limit is spread over the minute.
+func (l *RateLimiter) burst() float64 {
+	return math.Max(1, math.Floor(float64(l.perMinute)/60))
+}
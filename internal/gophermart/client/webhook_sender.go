@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	WebhookSignatureHeader = "X-Gophermart-Signature"
+	WebhookEventHeader     = "X-Gophermart-Event"
+	WebhookDeliveryHeader  = "X-Gophermart-Delivery"
+	// maxWebhookResponseSize is read from the response, so that the connection can be reused
+	maxWebhookResponseSize = 64 << 10
+)
+
+// ErrWebhookTargetForbidden is returned if a webhook resolves to an address of the internal network.
+var ErrWebhookTargetForbidden = errors.New("webhook target address is not allowed")
+
+// HTTPWebhookSender posts the payloads signed with the secret of the webhook. Redirects are not followed.
+type HTTPWebhookSender struct {
+	client *http.Client
+}
+
+// NewHTTPWebhookSender returns a sender which refuses to connect to internal addresses unless allowPrivateTargets
+// is set, e.g. for development. The address is checked when dialing, after the name is resolved, so a name which
+// resolves differently on every lookup cannot get around the check.
+func NewHTTPWebhookSender(timeout time.Duration, allowPrivateTargets bool) *HTTPWebhookSender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivateTargets {
+		dialer.Control = rejectForbiddenWebhookTarget
+	}
+	return &HTTPWebhookSender{client: &http.Client{
+		Timeout: timeout,
+		// no proxy, it would be dialed instead of the target
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// IsForbiddenWebhookIP reports whether the ip belongs to the host or the internal network, webhooks must not be
+// delivered there.
+func IsForbiddenWebhookIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast()
+}
+
+func rejectForbiddenWebhookTarget(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return ErrWebhookTargetForbidden
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || IsForbiddenWebhookIP(ip) {
+		return ErrWebhookTargetForbidden
+	}
+	return nil
+}
+
+func (s *HTTPWebhookSender) Send(ctx context.Context, request WebhookRequest) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, request.URL, bytes.NewReader(request.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("error during building webhook request, cause: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gophermart-webhooks")
+	req.Header.Set(WebhookEventHeader, request.EventType)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(request.DeliveryID, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(request.Secret, time.Now(), request.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxWebhookResponseSize))
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload returns the signature header value t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<payload>">.
+// The time is signed as well, so that receivers can reject replayed requests.
+func SignWebhookPayload(secret string, timestamp time.Time, payload []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "t=" + unix + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -2,43 +2,193 @@ package httpserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/service"
 	"github.com/apolsh/yapr-gophermart/internal/logger"
 )
 
 type ContextKey string
 
-var UserID ContextKey = "UserID"
+var (
+	UserID    ContextKey = "UserID"
+	Roles     ContextKey = "Roles"
+	SessionID ContextKey = "SessionID"
+	APIKeyID  ContextKey = "APIKeyID"
+	Scopes    ContextKey = "Scopes"
+)
+
+const (
+	apiKeyHeaderKey = "X-API-Key"
+	bearerScheme    = "Bearer"
+	authRealm       = "gophermart"
+)
+
+var (
+	errMissingCredentials   = errors.New("authorization credentials are missing")
+	errMalformedCredentials = errors.New("authorization credentials are malformed")
+)
 
 var authMiddlewareLogger = logger.LoggerOfComponent("authMiddleware")
 
-func AuthMiddleware(parseCallback func(string) (string, error)) func(handler http.Handler) http.Handler {
+type parseCallback func(context.Context, string) (dto.Principal, error)
+
+// AuthMiddleware authenticates the caller either by an API key from the X-API-Key header or by an access token.
+// The API key takes precedence if both are given.
+func AuthMiddleware(parseToken, parseAPIKey parseCallback) func(handler http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			cookie, err := r.Cookie(authorizationHeaderKey)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusUnauthorized)
+			if key := r.Header.Get(apiKeyHeaderKey); key != "" {
+				principal, err := parseAPIKey(r.Context(), key)
+				if err != nil {
+					authMiddlewareLogger.Error(fmt.Errorf("authorization error: %w", err))
+					writeAPIKeyError(w, err)
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), principal)))
 				return
 			}
-			cookieParts := strings.Split(cookie.Value, " ")
-			if len(cookieParts) != 2 {
-				http.Error(w, "", http.StatusUnauthorized)
+
+			token, err := extractToken(r)
+			if err != nil {
+				writeAuthError(w, err)
 				return
 			}
 
-			id, err := parseCallback(cookieParts[1])
+			principal, err := parseToken(r.Context(), token)
 			if err != nil {
 				authMiddlewareLogger.Error(fmt.Errorf("authorization error: %w", err))
-				http.Error(w, "", http.StatusUnauthorized)
+				writeAuthError(w, err)
 				return
 			}
+			next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), principal)))
+		})
+	}
+}
 
-			ctxWithUserID := context.WithValue(r.Context(), UserID, id)
-			rWithUserID := r.WithContext(ctxWithUserID)
-			next.ServeHTTP(w, rWithUserID)
+func withPrincipal(ctx context.Context, principal dto.Principal) context.Context {
+	ctx = context.WithValue(ctx, UserID, principal.UserID)
+	ctx = context.WithValue(ctx, Roles, principal.Roles)
+	ctx = context.WithValue(ctx, SessionID, principal.SessionID)
+	ctx = context.WithValue(ctx, APIKeyID, principal.APIKeyID)
+	return context.WithValue(ctx, Scopes, principal.Scopes)
+}
+
+// RequireRole lets the request through if the caller has at least one of the roles, it must be used after
+// AuthMiddleware.
+func RequireRole(roles ...string) func(handler http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, role := range roles {
+				if HasRole(r.Context(), role) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.Error(w, "", http.StatusForbidden)
+		})
+	}
+}
+
+// RequireScope lets the request through if the caller is allowed to use the scope, it must be used after
+// AuthMiddleware. Only API keys are limited by scopes.
+func RequireScope(scope string) func(handler http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !principalFromContext(r.Context()).HasScope(scope) {
+				http.Error(w, "", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// RequireAccessToken rejects callers authenticated with an API key, it guards the management of the account itself.
+func RequireAccessToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if principalFromContext(r.Context()).APIKeyID != "" {
+			http.Error(w, "", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func principalFromContext(ctx context.Context) dto.Principal {
+	apiKeyID, _ := ctx.Value(APIKeyID).(string)
+	scopes, _ := ctx.Value(Scopes).([]string)
+	return dto.Principal{Roles: RolesFromContext(ctx), APIKeyID: apiKeyID, Scopes: scopes}
+}
+
+// RolesFromContext returns the roles of the caller put into the context by AuthMiddleware.
+func RolesFromContext(ctx context.Context) []string {
+	roles, _ := ctx.Value(Roles).([]string)
+	return roles
+}
+
+func HasRole(ctx context.Context, role string) bool {
+	return dto.Principal{Roles: RolesFromContext(ctx)}.HasRole(role)
+}
+
+// extractToken reads the bearer token from the Authorization request header, falling back to the cookie.
+func extractToken(r *http.Request) (string, error) {
+	if header := r.Header.Get(authorizationHeaderKey); header != "" {
+		return parseBearerCredentials(header)
+	}
+
+	cookie, err := r.Cookie(authorizationHeaderKey)
+	if err != nil {
+		return "", errMissingCredentials
+	}
+	return parseBearerCredentials(cookie.Value)
+}
+
+func parseBearerCredentials(value string) (string, error) {
+	parts := strings.Split(value, " ")
+	if len(parts) != 2 || !strings.EqualFold(parts[0], bearerScheme) || parts[1] == "" {
+		return "", errMalformedCredentials
+	}
+	return parts[1], nil
+}
+
+// writeAuthError responds with a WWW-Authenticate challenge as described in RFC 6750, section 3.
+func writeAuthError(w http.ResponseWriter, err error) {
+	var status int
+	var errorCode, description string
+
+	switch {
+	case errors.Is(err, errMissingCredentials):
+		status = http.StatusUnauthorized
+	case errors.Is(err, errMalformedCredentials):
+		status, errorCode, description = http.StatusBadRequest, "invalid_request", "malformed"
+	case errors.Is(err, service.ErrorTokenExpired):
+		status, errorCode, description = http.StatusUnauthorized, "invalid_token", "expired"
+	case errors.Is(err, service.ErrorTokenRevoked):
+		status, errorCode, description = http.StatusUnauthorized, "invalid_token", "revoked"
+	case errors.Is(err, service.ErrorInvalidToken):
+		status, errorCode, description = http.StatusUnauthorized, "invalid_token", "malformed"
+	default:
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	challenge := fmt.Sprintf(`%s realm="%s"`, bearerScheme, authRealm)
+	if errorCode != "" {
+		challenge += fmt.Sprintf(`, error="%s", error_description="%s"`, errorCode, description)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, "", status)
+}
+
+func writeAPIKeyError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrorInvalidAPIKey) {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	http.Error(w, "", http.StatusInternalServerError)
+}
@@ -10,6 +10,10 @@ import (
 const (
 	shutdownTimeout        = 3 * time.Second
 	authorizationHeaderKey = "Authorization"
+	refreshTokenHeaderKey  = "Refresh-Token"
+	refreshTokenCookiePath = "/api/user/token"
+	oidcStateCookieName    = "OIDC-State"
+	oidcStateCookiePath    = "/api/user/oidc"
 )
 
 type Server struct {
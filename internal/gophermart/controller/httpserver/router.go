@@ -2,12 +2,15 @@
 package httpserver
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -26,123 +29,912 @@ type AuthRequest struct {
 	Password string `json:"password"`
 }
 
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+type PasswordResetRequest struct {
+	Login string `json:"login"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TwoFactorChallengeResponse struct {
+	ChallengeToken string `json:"challenge_token"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+type TOTPVerifyRequest struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+}
+
+type UserRolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+type UserRolesResponse struct {
+	Roles []string `json:"roles"`
+}
+
 type GophermartService interface {
-	AddUser(ctx context.Context, login, password string) (string, error)
-	LoginUser(ctx context.Context, login, password string) (string, error)
-	ParseJWTToken(token string) (string, error)
+	AddUser(ctx context.Context, login, password string, client dto.ClientInfo) (dto.TokenPair, error)
+	LoginUser(ctx context.Context, login, password string, client dto.ClientInfo) (dto.TokenPair, error)
+	VerifyEmail(ctx context.Context, verificationToken string) error
+	LoginTwoFactor(ctx context.Context, challengeToken, code string, client dto.ClientInfo) (dto.TokenPair, error)
+	StartOIDCLogin(ctx context.Context) (dto.OIDCAuthorization, error)
+	FinishOIDCLogin(ctx context.Context, stateToken, state, code string, client dto.ClientInfo) (dto.TokenPair, error)
+	EnrollTOTP(ctx context.Context, userID string) (dto.TOTPEnrollment, error)
+	VerifyTOTP(ctx context.Context, userID, code string) ([]string, error)
+	RefreshTokens(ctx context.Context, refreshToken string) (dto.TokenPair, error)
+	ParseJWTToken(ctx context.Context, token string) (dto.Principal, error)
+	Logout(ctx context.Context, accessToken, refreshToken string) error
+	RequestPasswordReset(ctx context.Context, login string) error
+	ResetPassword(ctx context.Context, resetToken, newPassword string) error
+	ChangePassword(ctx context.Context, userID, oldPassword, newPassword string, client dto.ClientInfo) (dto.TokenPair, error)
+	GetSessions(ctx context.Context, userID, currentSessionID string) ([]dto.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+	CreateAPIKey(ctx context.Context, userID, name string, scopes []string) (dto.CreatedAPIKey, error)
+	GetAPIKeys(ctx context.Context, userID string) ([]dto.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID string) error
+	ParseAPIKey(ctx context.Context, key string) (dto.Principal, error)
+	GetJWKS() dto.JWKS
+	ExportUserData(ctx context.Context, userID string) (dto.UserDataExport, error)
+	DeleteAccount(ctx context.Context, userID, password, code string) error
+	SetUserRoles(ctx context.Context, userID string, roles []string) ([]string, error)
+	GetLoginLockouts(ctx context.Context, limit int) ([]dto.LoginLockout, error)
 	AddOrder(ctx context.Context, orderNum string, userID string) error
-	GetOrdersByUser(ctx context.Context, id string) ([]dto.Order, error)
+	AddOrders(ctx context.Context, orderNums []string, userID string) ([]dto.OrderUploadResult, error)
+	GetOrdersByUser(ctx context.Context, id string, query dto.OrderQuery) (dto.OrderPage, error)
+	GetOrder(ctx context.Context, userID, orderNum string) (dto.OrderDetails, error)
+	GetAnyOrder(ctx context.Context, orderNum string) (dto.OrderDetails, error)
+	RecheckOrder(ctx context.Context, userID, orderNum string) (dto.Order, error)
+	RecheckAnyOrder(ctx context.Context, triggeredBy, orderNum string) (dto.Order, error)
+	SubscribeOrderEvents(ctx context.Context, userID string, lastEventID int64) (<-chan dto.OrderEvent, error)
+	CreateWebhook(ctx context.Context, userID, webhookURL string, eventTypes []string) (dto.CreatedWebhook, error)
+	GetWebhooks(ctx context.Context, userID string) ([]dto.Webhook, error)
+	DeleteWebhook(ctx context.Context, userID, webhookID string) error
+	GetWebhookDeliveries(ctx context.Context, userID, webhookID string) ([]dto.WebhookDelivery, error)
 	GetBalanceByUserID(ctx context.Context, id string) (dto.Balance, error)
 	CreateWithdraw(ctx context.Context, id string, withdraw dto.Withdraw) error
 	GetWithdrawalsByUserID(ctx context.Context, id string) ([]dto.Withdraw, error)
-	StartAccrualInfoSynchronizer(ctx context.Context, loyaltyServiceRateLimit int) error
+	GetAccrualAdjustments(ctx context.Context, id string) ([]dto.AccrualAdjustment, error)
+	StartAccrualInfoSynchronizer(ctx context.Context, workers int) error
 	Close()
 }
 
-type controller struct {
-	gophermartService GophermartService
-}
+type controller struct {
+	gophermartService GophermartService
+}
+
+const (
+	applicationJSONContentType   = "application/json"
+	textPlainContentType         = "text/plain"
+	applicationXGzipContentType  = "application/x-gzip"
+	applicationNDJSONContentType = "application/x-ndjson"
+	eventStreamContentType       = "text/event-stream"
+	// maxOrderBodySize and maxOrdersBatchBodySize are enough for the longest order numbers
+	maxOrderBodySize       = 1 << 10
+	maxOrdersBatchBodySize = 1 << 20
+)
+
+var log = logger.LoggerOfComponent("router")
+
+var (
+	// orderEventsHeartbeat keeps idle event streams open through proxies
+	orderEventsHeartbeat = 15 * time.Second
+	// orderEventsRetry is the reconnection delay suggested to the clients
+	orderEventsRetry = 3 * time.Second
+)
+
+const requestTimeout = 60 * time.Second
+
+func RegisterRoutes(r *chi.Mux, s GophermartService) {
+	c := &controller{gophermartService: s}
+
+	r.Use(middleware.RequestID)
+	r.Use(middleware.RealIP)
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.Compress(5))
+
+	withTimeout := middleware.Timeout(requestTimeout)
+
+	r.With(withTimeout).Get("/.well-known/jwks.json", c.getJWKS)
+
+	// the order event stream stays open until the client goes away, it is the only route without the timeout
+	r.With(AuthMiddleware(s.ParseJWTToken, s.ParseAPIKey), RequireScope(dto.ScopeOrdersRead)).
+		Get("/api/user/orders/events", c.getOrderEvents)
+
+	r.Route("/api/user", func(r chi.Router) {
+		r.Use(withTimeout)
+		r.Group(func(r chi.Router) {
+			r.Post("/register", c.userRegisterHandler)
+			r.Post("/login", c.userLoginHandler)
+			r.Post("/login/2fa", c.twoFactorLoginHandler)
+			r.Post("/token/refresh", c.tokenRefreshHandler)
+			r.Get("/oidc/login", c.oidcLoginHandler)
+			r.Get("/oidc/callback", c.oidcCallbackHandler)
+			r.Get("/email/verify", c.verifyEmailHandler)
+			r.Post("/password/reset-request", c.passwordResetRequestHandler)
+			r.Post("/password/reset", c.resetPasswordHandler)
+		})
+		r.With(AuthMiddleware(s.ParseJWTToken, s.ParseAPIKey)).Group(func(r chi.Router) {
+			r.With(RequireAccessToken).Group(func(r chi.Router) {
+				r.Post("/logout", c.userLogoutHandler)
+				r.Delete("/", c.deleteAccountHandler)
+				r.Get("/export", c.exportUserDataHandler)
+				r.Route("/sessions", func(r chi.Router) {
+					r.Get("/", c.getSessions)
+					r.Delete("/{id}", c.revokeSession)
+				})
+				r.Route("/api-keys", func(r chi.Router) {
+					r.Post("/", c.createAPIKey)
+					r.Get("/", c.getAPIKeys)
+					r.Delete("/{id}", c.revokeAPIKey)
+				})
+				r.Route("/webhooks", func(r chi.Router) {
+					r.Post("/", c.createWebhook)
+					r.Get("/", c.getWebhooks)
+					r.Delete("/{id}", c.deleteWebhook)
+					r.Get("/{id}/deliveries", c.getWebhookDeliveries)
+				})
+				r.Put("/password", c.changePasswordHandler)
+				r.Route("/2fa", func(r chi.Router) {
+					r.Post("/enroll", c.enrollTOTPHandler)
+					r.Post("/verify", c.verifyTOTPHandler)
+				})
+			})
+			r.Route("/orders", func(r chi.Router) {
+				r.With(RequireScope(dto.ScopeOrdersWrite)).Post("/", c.createOrder)
+				r.With(RequireScope(dto.ScopeOrdersWrite)).Post("/batch", c.createOrders)
+				r.With(RequireScope(dto.ScopeOrdersRead)).Get("/", c.getOrders)
+				r.With(RequireScope(dto.ScopeOrdersRead)).Get("/{number}", c.getOrder)
+				r.With(RequireScope(dto.ScopeOrdersWrite)).Post("/{number}/recheck", c.recheckOrder)
+			})
+			r.Route("/balance", func(r chi.Router) {
+				r.With(RequireScope(dto.ScopeBalanceRead)).Get("/", c.getBalance)
+				r.With(RequireScope(dto.ScopeWithdraw)).Post("/withdraw", c.createWithdraw)
+				r.With(RequireScope(dto.ScopeBalanceRead)).Get("/adjustments", c.getAccrualAdjustments)
+			})
+			r.With(RequireScope(dto.ScopeBalanceRead)).Get("/withdrawals", c.getWithdrawals)
+		})
+	})
+
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(withTimeout)
+		r.Use(AuthMiddleware(s.ParseJWTToken, s.ParseAPIKey))
+		r.Use(RequireAccessToken)
+		r.With(RequireRole(dto.RoleAdmin)).Put("/users/{id}/roles", c.setUserRolesHandler)
+		r.With(RequireRole(dto.RoleAdmin)).Get("/lockouts", c.getLoginLockouts)
+		r.With(RequireRole(dto.RoleAdmin, dto.RoleSupport)).Get("/orders/{number}", c.getAnyOrder)
+		r.With(RequireRole(dto.RoleAdmin, dto.RoleSupport)).Post("/orders/{number}/recheck", c.recheckAnyOrder)
+	})
+}
+
+func (c *controller) userRegisterHandler(w http.ResponseWriter, r *http.Request) {
+	if !isValidContentType(r, applicationJSONContentType, applicationXGzipContentType) {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	req := &AuthRequest{}
+	err := extractJSONBody(r, &req)
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := c.gophermartService.AddUser(r.Context(), req.Login, req.Password, clientInfo(r))
+	if err != nil {
+		if errors.Is(err, service.ErrorEmptyValue) {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrorWeakPassword) || errors.Is(err, service.ErrorInvalidEmailLogin) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, storage.ErrorLoginIsAlreadyUsed) {
+			http.Error(w, "", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, service.ErrorEmailNotVerified) {
+			http.Error(w, err.Error(), http.StatusAccepted)
+			return
+		}
+		log.Error(fmt.Errorf("error during user registration: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	writeTokens(w, tokens)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *controller) userLoginHandler(w http.ResponseWriter, r *http.Request) {
+
+	if !isValidContentType(r, applicationJSONContentType, applicationXGzipContentType) {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	req := &AuthRequest{}
+	err := extractJSONBody(r, &req)
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := c.gophermartService.LoginUser(r.Context(), req.Login, req.Password, clientInfo(r))
+	if err != nil {
+		var lockedErr *service.LoginLockedError
+		if errors.As(err, &lockedErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		var twoFactorErr *service.TwoFactorRequiredError
+		if errors.As(err, &twoFactorErr) {
+			writeJSON(w, http.StatusAccepted, TwoFactorChallengeResponse{ChallengeToken: twoFactorErr.ChallengeToken})
+			return
+		}
+		if errors.Is(storage.ErrItemNotFound, err) || errors.Is(service.ErrorInvalidPassword, err) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if errors.Is(err, service.ErrorEmailNotVerified) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		log.Error(fmt.Errorf("error during user login: %w", err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeTokens(w, tokens)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *controller) twoFactorLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if !isValidContentType(r, applicationJSONContentType, applicationXGzipContentType) {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	req := &TwoFactorLoginRequest{}
+	err := extractJSONBody(r, &req)
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := c.gophermartService.LoginTwoFactor(r.Context(), req.ChallengeToken, req.Code, clientInfo(r))
+	if err != nil {
+		var lockedErr *service.LoginLockedError
+		if errors.As(err, &lockedErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if errors.Is(err, service.ErrorEmptyValue) {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrorInvalidTOTPCode) || errors.Is(err, service.ErrorInvalidToken) ||
+			errors.Is(err, service.ErrorTokenExpired) || errors.Is(err, service.ErrorTokenRevoked) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		log.Error(fmt.Errorf("error during two-factor login: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	writeTokens(w, tokens)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *controller) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	authorization, err := c.gophermartService.StartOIDCLogin(r.Context())
+	if err != nil {
+		if errors.Is(err, service.ErrorOIDCNotConfigured) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		log.Error(fmt.Errorf("error during starting oidc login: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	// the provider redirects back with a top-level navigation, a strict cookie would not be sent
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    authorization.StateToken,
+		Path:     oidcStateCookiePath,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authorization.URL, http.StatusFound)
+}
+
+func (c *controller) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookieName, Value: "", Path: oidcStateCookiePath, MaxAge: -1})
+
+	query := r.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		http.Error(w, providerError, http.StatusUnauthorized)
+		return
+	}
+	var stateToken string
+	if cookie, err := r.Cookie(oidcStateCookieName); err == nil {
+		stateToken = cookie.Value
+	}
+
+	tokens, err := c.gophermartService.FinishOIDCLogin(r.Context(), stateToken, query.Get("state"), query.Get("code"), clientInfo(r))
+	if err != nil {
+		var twoFactorErr *service.TwoFactorRequiredError
+		if errors.As(err, &twoFactorErr) {
+			writeJSON(w, http.StatusAccepted, TwoFactorChallengeResponse{ChallengeToken: twoFactorErr.ChallengeToken})
+			return
+		}
+		if errors.Is(err, service.ErrorOIDCNotConfigured) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		if errors.Is(err, service.ErrorInvalidOIDCState) || errors.Is(err, service.ErrorOIDCLoginFailed) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		log.Error(fmt.Errorf("error during finishing oidc login: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	writeTokens(w, tokens)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *controller) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	err := c.gophermartService.VerifyEmail(r.Context(), r.URL.Query().Get("token"))
+	if err != nil {
+		if errors.Is(err, service.ErrorEmptyValue) || errors.Is(err, service.ErrorInvalidToken) ||
+			errors.Is(err, service.ErrorTokenExpired) || errors.Is(err, service.ErrorTokenRevoked) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, storage.ErrItemNotFound) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+		log.Error(fmt.Errorf("error during email verification: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *controller) tokenRefreshHandler(w http.ResponseWriter, r *http.Request) {
+	req := &RefreshRequest{}
+	if isValidContentType(r, applicationJSONContentType, applicationXGzipContentType) {
+		err := extractJSONBody(r, &req)
+		if err != nil {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+	} else if cookie, err := r.Cookie(refreshTokenHeaderKey); err == nil {
+		req.RefreshToken = cookie.Value
+	}
+
+	tokens, err := c.gophermartService.RefreshTokens(r.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, service.ErrorEmptyValue) {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrorInvalidRefreshToken) || errors.Is(err, service.ErrorRefreshTokenReused) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		log.Error(fmt.Errorf("error during refreshing tokens: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	writeTokens(w, tokens)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *controller) userLogoutHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := extractToken(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	var refreshToken string
+	if cookie, err := r.Cookie(refreshTokenHeaderKey); err == nil {
+		refreshToken = cookie.Value
+	}
+
+	err = c.gophermartService.Logout(r.Context(), token, refreshToken)
+	if err != nil {
+		log.Error(fmt.Errorf("error during user logout: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: authorizationHeaderKey, Value: "", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{Name: refreshTokenHeaderKey, Value: "", Path: refreshTokenCookiePath, MaxAge: -1})
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *controller) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if !isValidContentType(r, applicationJSONContentType, applicationXGzipContentType) {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	req := &ChangePasswordRequest{}
+	err := extractJSONBody(r, &req)
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value(UserID).(string)
+
+	tokens, err := c.gophermartService.ChangePassword(r.Context(), userID, req.OldPassword, req.NewPassword, clientInfo(r))
+	if err != nil {
+		var lockedErr *service.LoginLockedError
+		if errors.As(err, &lockedErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if errors.Is(err, service.ErrorEmptyValue) {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrorWeakPassword) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrorInvalidPassword) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		log.Error(fmt.Errorf("error during changing password: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	writeTokens(w, tokens)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *controller) passwordResetRequestHandler(w http.ResponseWriter, r *http.Request) {
+	if !isValidContentType(r, applicationJSONContentType, applicationXGzipContentType) {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	req := &PasswordResetRequest{}
+	err := extractJSONBody(r, &req)
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	err = c.gophermartService.RequestPasswordReset(r.Context(), req.Login)
+	if err != nil {
+		var limitedErr *service.RateLimitedError
+		if errors.As(err, &limitedErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limitedErr.RetryAfter.Seconds()))))
+			http.Error(w, "too many password reset requests", http.StatusTooManyRequests)
+			return
+		}
+		if errors.Is(err, service.ErrorEmptyValue) {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrorPasswordResetNotConfigured) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		log.Error(fmt.Errorf("error during password reset request: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	// the same answer whether the login exists or not
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (c *controller) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if !isValidContentType(r, applicationJSONContentType, applicationXGzipContentType) {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	req := &ResetPasswordRequest{}
+	err := extractJSONBody(r, &req)
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	err = c.gophermartService.ResetPassword(r.Context(), req.Token, req.NewPassword)
+	if err != nil {
+		if errors.Is(err, service.ErrorEmptyValue) {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrorInvalidPasswordResetToken) || errors.Is(err, service.ErrorWeakPassword) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Error(fmt.Errorf("error during password reset: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *controller) getSessions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(UserID).(string)
+	sessionID, _ := r.Context().Value(SessionID).(string)
+
+	sessions, err := c.gophermartService.GetSessions(r.Context(), userID, sessionID)
+	if err != nil {
+		log.Error(fmt.Errorf("error during receiving sessions: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+func (c *controller) revokeSession(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(UserID).(string)
+
+	err := c.gophermartService.RevokeSession(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+		log.Error(fmt.Errorf("error during revoking session: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *controller) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !isValidContentType(r, applicationJSONContentType, applicationXGzipContentType) {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	req := &CreateAPIKeyRequest{}
+	err := extractJSONBody(r, &req)
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	userID := r.Context().Value(UserID).(string)
+
+	key, err := c.gophermartService.CreateAPIKey(r.Context(), userID, req.Name, req.Scopes)
+	if err != nil {
+		if errors.Is(err, service.ErrorEmptyValue) {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrorInvalidAPIKeyName) || errors.Is(err, service.ErrorUnknownScope) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Error(fmt.Errorf("error during creating api key: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusCreated, key)
+}
+
+func (c *controller) getAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(UserID).(string)
+
+	keys, err := c.gophermartService.GetAPIKeys(r.Context(), userID)
+	if err != nil {
+		log.Error(fmt.Errorf("error during receiving api keys: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+func (c *controller) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(UserID).(string)
+
+	err := c.gophermartService.RevokeAPIKey(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+		log.Error(fmt.Errorf("error during revoking api key: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *controller) createWebhook(w http.ResponseWriter, r *http.Request) {
+	if !isValidContentType(r, applicationJSONContentType, applicationXGzipContentType) {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	req := &CreateWebhookRequest{}
+	err := extractJSONBody(r, &req)
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	userID := r.Context().Value(UserID).(string)
+
+	webhook, err := c.gophermartService.CreateWebhook(r.Context(), userID, req.URL, req.EventTypes)
+	if err != nil {
+		if errors.Is(err, service.ErrorEmptyValue) {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrorInvalidWebhookURL) || errors.Is(err, service.ErrorUnknownWebhookEvent) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrorWebhooksNotConfigured) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		log.Error(fmt.Errorf("error during creating webhook: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusCreated, webhook)
+}
+
+func (c *controller) getWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(UserID).(string)
+
+	webhooks, err := c.gophermartService.GetWebhooks(r.Context(), userID)
+	if err != nil {
+		log.Error(fmt.Errorf("error during receiving webhooks: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, webhooks)
+}
+
+func (c *controller) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(UserID).(string)
 
-const (
-	applicationJSONContentType  = "application/json"
-	textPlainContentType        = "text/plain"
-	applicationXGzipContentType = "application/x-gzip"
-)
+	err := c.gophermartService.DeleteWebhook(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+		log.Error(fmt.Errorf("error during deleting webhook: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
 
-var log = logger.LoggerOfComponent("router")
+func (c *controller) getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(UserID).(string)
 
-func RegisterRoutes(r *chi.Mux, s GophermartService) {
-	c := &controller{gophermartService: s}
+	deliveries, err := c.gophermartService.GetWebhookDeliveries(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+		log.Error(fmt.Errorf("error during receiving webhook deliveries: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
 
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Compress(5))
+func (c *controller) exportUserDataHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(UserID).(string)
 
-	r.Use(middleware.Timeout(60 * time.Second))
+	export, err := c.gophermartService.ExportUserData(r.Context(), userID)
+	if err != nil {
+		log.Error(fmt.Errorf("error during exporting user data: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
 
-	r.Route("/api/user", func(r chi.Router) {
-		r.Group(func(r chi.Router) {
-			r.Post("/register", c.userRegisterHandler)
-			r.Post("/login", c.userLoginHandler)
-		})
-		r.With(AuthMiddleware(s.ParseJWTToken)).Group(func(r chi.Router) {
-			r.Route("/orders", func(r chi.Router) {
-				r.Post("/", c.createOrder)
-				r.Get("/", c.getOrders)
-			})
-			r.Route("/balance", func(r chi.Router) {
-				r.Get("/", c.getBalance)
-				r.Post("/withdraw", c.createWithdraw)
-			})
-			r.Get("/withdrawals", c.getWithdrawals)
-		})
-	})
+	w.Header().Set("Content-Disposition", `attachment; filename="gophermart-export.json"`)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, export)
 }
 
-func (c *controller) userRegisterHandler(w http.ResponseWriter, r *http.Request) {
+func (c *controller) deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
 	if !isValidContentType(r, applicationJSONContentType, applicationXGzipContentType) {
 		http.Error(w, "", http.StatusBadRequest)
 		return
 	}
 
-	req := &AuthRequest{}
+	req := &DeleteAccountRequest{}
 	err := extractJSONBody(r, &req)
 	if err != nil {
 		http.Error(w, "", http.StatusBadRequest)
 		return
 	}
 
-	token, err := c.gophermartService.AddUser(r.Context(), req.Login, req.Password)
+	userID := r.Context().Value(UserID).(string)
+
+	err = c.gophermartService.DeleteAccount(r.Context(), userID, req.Password, req.Code)
 	if err != nil {
+		var lockedErr *service.LoginLockedError
+		if errors.As(err, &lockedErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
 		if errors.Is(err, service.ErrorEmptyValue) {
 			http.Error(w, "", http.StatusBadRequest)
 			return
 		}
-		if errors.Is(err, storage.ErrorLoginIsAlreadyUsed) {
-			http.Error(w, "", http.StatusConflict)
+		if errors.Is(err, service.ErrorInvalidPassword) || errors.Is(err, service.ErrorInvalidTOTPCode) ||
+			errors.Is(err, service.ErrorTwoFactorRequired) {
+			http.Error(w, err.Error(), http.StatusForbidden)
 			return
 		}
-		log.Error(fmt.Errorf("error during user registration: %w", err))
+		log.Error(fmt.Errorf("error during deleting account: %w", err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Add(authorizationHeaderKey, token)
-	http.SetCookie(w, &http.Cookie{Name: authorizationHeaderKey, Value: fmt.Sprintf("Bearer %s", token)})
+	http.SetCookie(w, &http.Cookie{Name: authorizationHeaderKey, Value: "", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{Name: refreshTokenHeaderKey, Value: "", Path: refreshTokenCookiePath, MaxAge: -1})
 	w.WriteHeader(http.StatusOK)
 }
 
-func (c *controller) userLoginHandler(w http.ResponseWriter, r *http.Request) {
+func (c *controller) enrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(UserID).(string)
+
+	enrollment, err := c.gophermartService.EnrollTOTP(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrorTwoFactorAlreadyEnabled) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, service.ErrorTwoFactorNotConfigured) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		log.Error(fmt.Errorf("error during totp enrollment: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, enrollment)
+}
 
+func (c *controller) verifyTOTPHandler(w http.ResponseWriter, r *http.Request) {
 	if !isValidContentType(r, applicationJSONContentType, applicationXGzipContentType) {
 		http.Error(w, "", http.StatusBadRequest)
 		return
 	}
 
-	req := &AuthRequest{}
+	req := &TOTPVerifyRequest{}
 	err := extractJSONBody(r, &req)
 	if err != nil {
 		http.Error(w, "", http.StatusBadRequest)
 		return
 	}
 
-	token, err := c.gophermartService.LoginUser(r.Context(), req.Login, req.Password)
+	userID := r.Context().Value(UserID).(string)
+
+	recoveryCodes, err := c.gophermartService.VerifyTOTP(r.Context(), userID, req.Code)
 	if err != nil {
-		if errors.Is(storage.ErrItemNotFound, err) || errors.Is(service.ErrorInvalidPassword, err) {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+		if errors.Is(err, service.ErrorEmptyValue) {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrorInvalidTOTPCode) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if errors.Is(err, service.ErrorTwoFactorAlreadyEnabled) || errors.Is(err, service.ErrorTwoFactorNotEnrolled) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, service.ErrorTwoFactorNotConfigured) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
 			return
 		}
+		log.Error(fmt.Errorf("error during totp verification: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
 
-		log.Error(fmt.Errorf("error during user login: %w", err))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+func (c *controller) setUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	if !isValidContentType(r, applicationJSONContentType, applicationXGzipContentType) {
+		http.Error(w, "", http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Add(authorizationHeaderKey, token)
-	http.SetCookie(w, &http.Cookie{Name: authorizationHeaderKey, Value: fmt.Sprintf("Bearer %s", token)})
-	w.WriteHeader(http.StatusOK)
+	req := &UserRolesRequest{}
+	err := extractJSONBody(r, &req)
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	roles, err := c.gophermartService.SetUserRoles(r.Context(), chi.URLParam(r, "id"), req.Roles)
+	if err != nil {
+		if errors.Is(err, service.ErrorUnknownRole) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, storage.ErrItemNotFound) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+		log.Error(fmt.Errorf("error during setting user roles: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, UserRolesResponse{Roles: roles})
 }
 
 func (c *controller) createOrder(w http.ResponseWriter, r *http.Request) {
@@ -151,26 +943,31 @@ func (c *controller) createOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var orderNum int
-	err := extractJSONBody(r, &orderNum)
-	if err != nil {
+	// the number is kept as sent, it may be longer than any integer type and may start with zeros
+	body, err := extractBody(r, maxOrderBodySize)
+	if errors.Is(err, errBodyTooLarge) {
+		http.Error(w, "", http.StatusRequestEntityTooLarge)
+		return
+	}
+	orderNum := string(bytes.TrimSpace(body))
+	if err != nil || orderNum == "" {
 		http.Error(w, "", http.StatusBadRequest)
 		return
 	}
 
 	userID := r.Context().Value(UserID).(string)
 
-	err = c.gophermartService.AddOrder(r.Context(), strconv.Itoa(orderNum), userID)
+	err = c.gophermartService.AddOrder(r.Context(), orderNum, userID)
 	if err != nil {
-		if errors.Is(service.ErrorInvalidOrderNumberFormat, err) {
+		if errors.Is(err, service.ErrorInvalidOrderNumberFormat) {
 			http.Error(w, "", http.StatusUnprocessableEntity)
 			return
 		}
-		if errors.Is(storage.ErrOrderAlreadyStored, err) {
+		if errors.Is(err, storage.ErrOrderAlreadyStored) {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
-		if errors.Is(storage.ErrOrderAlreadyStoredByOtherUser, err) {
+		if errors.Is(err, storage.ErrOrderAlreadyStoredByOtherUser) {
 			http.Error(w, "", http.StatusConflict)
 			return
 		}
@@ -181,15 +978,97 @@ func (c *controller) createOrder(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusAccepted)
 }
 
+func (c *controller) createOrders(w http.ResponseWriter, r *http.Request) {
+	if !isValidContentType(r, applicationJSONContentType, textPlainContentType, applicationNDJSONContentType, applicationXGzipContentType) {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	orderNums, err := extractOrderNumbers(r)
+	if errors.Is(err, errBodyTooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value(UserID).(string)
+	results, err := c.gophermartService.AddOrders(r.Context(), orderNums, userID)
+	if err != nil {
+		if errors.Is(err, service.ErrorEmptyValue) || errors.Is(err, service.ErrorOrdersBatchTooLarge) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Error(fmt.Errorf("error during creating orders: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// extractOrderNumbers reads a JSON array of order numbers, given as numbers or strings, or a text with one order
+// number per line. Elements of the array which are neither numbers nor strings are kept as is, so that they are
+// reported as invalid.
+func extractOrderNumbers(r *http.Request) ([]string, error) {
+	body, err := extractBody(r, maxOrdersBatchBodySize)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	if !bytes.HasPrefix(trimmed, []byte("[")) {
+		orderNums := make([]string, 0)
+		for _, line := range strings.Split(string(trimmed), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				orderNums = append(orderNums, line)
+			}
+		}
+		return orderNums, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(trimmed, &items); err != nil {
+		return nil, err
+	}
+	orderNums := make([]string, 0, len(items))
+	for _, item := range items {
+		var orderNum string
+		if err := json.Unmarshal(item, &orderNum); err != nil {
+			orderNum = string(item)
+		}
+		orderNums = append(orderNums, orderNum)
+	}
+	return orderNums, nil
+}
+
 func (c *controller) getOrders(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(UserID).(string)
-	orders, err := c.gophermartService.GetOrdersByUser(r.Context(), userID)
+	query, err := parseOrderQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := c.gophermartService.GetOrdersByUser(r.Context(), userID, query)
 	if err != nil {
+		if errors.Is(err, service.ErrorInvalidOrderQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		log.Error(fmt.Errorf("error during receiving orders: %w", err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	orders := page.Orders
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if page.NextCursor != "" {
+		next := *r.URL
+		params := next.Query()
+		params.Set("cursor", page.NextCursor)
+		next.RawQuery = params.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
 
 	if len(orders) == 0 {
 		w.WriteHeader(http.StatusNoContent)
@@ -202,6 +1081,175 @@ func (c *controller) getOrders(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (c *controller) getOrder(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(UserID).(string)
+	order, err := c.gophermartService.GetOrder(r.Context(), userID, chi.URLParam(r, "number"))
+	writeOrderDetails(w, order, err)
+}
+
+func (c *controller) getLoginLockouts(w http.ResponseWriter, r *http.Request) {
+	var limit int
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	lockouts, err := c.gophermartService.GetLoginLockouts(r.Context(), limit)
+	if err != nil {
+		log.Error(fmt.Errorf("error during receiving login lockouts: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, lockouts)
+}
+
+func (c *controller) getAnyOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := c.gophermartService.GetAnyOrder(r.Context(), chi.URLParam(r, "number"))
+	writeOrderDetails(w, order, err)
+}
+
+func (c *controller) recheckOrder(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(UserID).(string)
+	order, err := c.gophermartService.RecheckOrder(r.Context(), userID, chi.URLParam(r, "number"))
+	writeRecheckedOrder(w, order, err)
+}
+
+func (c *controller) recheckAnyOrder(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(UserID).(string)
+	order, err := c.gophermartService.RecheckAnyOrder(r.Context(), userID, chi.URLParam(r, "number"))
+	writeRecheckedOrder(w, order, err)
+}
+
+func writeRecheckedOrder(w http.ResponseWriter, order dto.Order, err error) {
+	if err != nil {
+		var limitedErr *service.RateLimitedError
+		if errors.As(err, &limitedErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limitedErr.RetryAfter.Seconds()))))
+			http.Error(w, "too many order recheck requests", http.StatusTooManyRequests)
+			return
+		}
+		if errors.Is(err, storage.ErrItemNotFound) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, storage.ErrOrderAlreadyProcessed) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Error(fmt.Errorf("error during rechecking order: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, order)
+}
+
+// getOrderEvents streams the status changes of the orders of the user as server-sent events. A reconnecting
+// client gets the events it has missed after the one in the Last-Event-ID header.
+func (c *controller) getOrderEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	var lastEventID int64
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		var err error
+		lastEventID, err = strconv.ParseInt(header, 10, 64)
+		if err != nil || lastEventID < 0 {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	userID := r.Context().Value(UserID).(string)
+	events, err := c.gophermartService.SubscribeOrderEvents(r.Context(), userID, lastEventID)
+	if err != nil {
+		log.Error(fmt.Errorf("error during subscribing to order events: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", eventStreamContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", orderEventsRetry.Milliseconds())
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(orderEventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Error(fmt.Errorf("error during encoding order event: %w", err))
+				return
+			}
+			fmt.Fprintf(w, "id: %d\nevent: order\ndata: %s\n\n", event.ID, data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeOrderDetails(w http.ResponseWriter, order dto.OrderDetails, err error) {
+	if err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+		log.Error(fmt.Errorf("error during receiving order: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, order)
+}
+
+// parseOrderQuery reads the paging, filter and sort parameters of the orders list. Statuses can be given as a
+// comma separated list or by repeating the parameter, the uploaded_at range is [uploaded_from, uploaded_to).
+func parseOrderQuery(r *http.Request) (dto.OrderQuery, error) {
+	params := r.URL.Query()
+	query := dto.OrderQuery{Cursor: params.Get("cursor"), Sort: params.Get("sort")}
+
+	if limit := params.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 {
+			return dto.OrderQuery{}, fmt.Errorf("invalid limit %q", limit)
+		}
+		query.Limit = value
+	}
+	for _, statuses := range params["status"] {
+		for _, status := range strings.Split(statuses, ",") {
+			if status = strings.TrimSpace(status); status != "" {
+				query.Statuses = append(query.Statuses, strings.ToUpper(status))
+			}
+		}
+	}
+	for name, bound := range map[string]**time.Time{"uploaded_from": &query.UploadedFrom, "uploaded_to": &query.UploadedTo} {
+		value := params.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return dto.OrderQuery{}, fmt.Errorf("invalid %s %q, RFC 3339 time is expected", name, value)
+		}
+		*bound = &parsed
+	}
+	return query, nil
+}
+
 func (c *controller) getBalance(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(UserID).(string)
 	balance, err := c.gophermartService.GetBalanceByUserID(r.Context(), userID)
@@ -235,12 +1283,12 @@ func (c *controller) createWithdraw(w http.ResponseWriter, r *http.Request) {
 
 	err = c.gophermartService.CreateWithdraw(r.Context(), userID, withdraw)
 	if err != nil {
-		if errors.Is(service.ErrorInvalidOrderNumberFormat, err) {
+		if errors.Is(err, service.ErrorInvalidOrderNumberFormat) {
 			http.Error(w, "", http.StatusUnprocessableEntity)
 			return
 		}
 
-		if errors.Is(storage.ErrInsufficientFunds, err) {
+		if errors.Is(err, storage.ErrInsufficientFunds) {
 			http.Error(w, "", http.StatusPaymentRequired)
 			return
 		}
@@ -276,6 +1324,58 @@ func (c *controller) getWithdrawals(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (c *controller) getAccrualAdjustments(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(UserID).(string)
+
+	adjustments, err := c.gophermartService.GetAccrualAdjustments(r.Context(), userID)
+	if err != nil {
+		log.Error(fmt.Errorf("error during recieving accrual adjustments: %w", err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, adjustments)
+}
+
+func writeTokens(w http.ResponseWriter, tokens dto.TokenPair) {
+	w.Header().Add(authorizationHeaderKey, tokens.AccessToken)
+	w.Header().Add(refreshTokenHeaderKey, tokens.RefreshToken)
+	http.SetCookie(w, &http.Cookie{Name: authorizationHeaderKey, Value: fmt.Sprintf("Bearer %s", tokens.AccessToken)})
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshTokenHeaderKey,
+		Value:    tokens.RefreshToken,
+		Path:     refreshTokenCookiePath,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func (c *controller) getJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(c.gophermartService.GetJWKS()); err != nil {
+		log.Error(fmt.Errorf("error during encoding response: %w", err))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error(fmt.Errorf("error during encoding response: %w", err))
+	}
+}
+
+// clientInfo returns the client address and user agent, middleware.RealIP has already replaced the address with
+// the forwarded one if any.
+func clientInfo(r *http.Request) dto.ClientInfo {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return dto.ClientInfo{IP: host, UserAgent: r.UserAgent()}
+}
+
 func isValidContentType(r *http.Request, allowedTypes ...string) bool {
 	actualContentType := r.Header.Get("Content-Type")
 
@@ -287,6 +1387,45 @@ func isValidContentType(r *http.Request, allowedTypes ...string) bool {
 	return false
 }
 
+// errBodyTooLarge is returned for bodies longer than the limit, before or after gunzipping
+var errBodyTooLarge = errors.New("request body too large")
+
+// limitedReader fails with errBodyTooLarge once more than n bytes are read
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
+
+// extractBody reads the whole body, gunzipping it if needed. Bodies longer than limit are rejected with
+// errBodyTooLarge, the limit applies to the gunzipped body as well, since a small gzip body may expand to gigabytes.
+func extractBody(r *http.Request, limit int64) ([]byte, error) {
+	var reader io.Reader = &limitedReader{r: r.Body, n: limit}
+	if r.Header.Get(`Content-Encoding`) == `gzip` {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = &limitedReader{r: gz, n: limit}
+	}
+	return io.ReadAll(reader)
+}
+
 func extractJSONBody(r *http.Request, v interface{}) error {
 	var reader io.ReadCloser
 	if r.Header.Get(`Content-Encoding`) == `gzip` {
@@ -2,15 +2,24 @@ package httpserver
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
 	"github.com/apolsh/yapr-gophermart/internal/gophermart/mocks"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/service"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/golang/mock/gomock"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
@@ -21,9 +30,11 @@ type userCreds struct {
 }
 
 var (
-	login    = "login"
-	password = "password"
-	token    = "token"
+	login     = "login"
+	password  = "password"
+	token     = "token"
+	tokens    = dto.TokenPair{AccessToken: token, RefreshToken: "refreshToken"}
+	principal = dto.Principal{UserID: "userID", Roles: []string{dto.RoleUser}}
 )
 
 type RouterSuite struct {
@@ -49,7 +60,7 @@ func (s *RouterSuite) SetupTest() {
 }
 
 func (s *RouterSuite) TestRegisterUserSuccess() {
-	s.service.EXPECT().AddUser(gomock.Any(), login, password).Return(token, nil)
+	s.service.EXPECT().AddUser(gomock.Any(), login, password, gomock.Any()).Return(tokens, nil)
 
 	req := httptest.NewRequest(http.MethodPost, "/api/user/register", credsBody(login, password))
 	req.Header.Set("Content-Type", "application/json")
@@ -73,7 +84,7 @@ func (s *RouterSuite) TestRegisterUserWrongMimeType() {
 }
 
 func (s *RouterSuite) TestLoginUserSuccess() {
-	s.service.EXPECT().LoginUser(gomock.Any(), login, password).Return(token, nil)
+	s.service.EXPECT().LoginUser(gomock.Any(), login, password, dto.ClientInfo{IP: "192.0.2.1"}).Return(tokens, nil)
 
 	req := httptest.NewRequest(http.MethodPost, "/api/user/login", credsBody(login, password))
 	req.Header.Set("Content-Type", "application/json")
@@ -85,6 +96,67 @@ func (s *RouterSuite) TestLoginUserSuccess() {
 	assert.Equal(s.T(), http.StatusOK, resp.Code)
 }
 
+func (s *RouterSuite) TestLoginUserLocked() {
+	s.service.EXPECT().LoginUser(gomock.Any(), login, password, dto.ClientInfo{IP: "203.0.113.7"}).
+		Return(dto.TokenPair{}, &service.LoginLockedError{RetryAfter: 90 * time.Second})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/login", credsBody(login, password))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Real-IP", "203.0.113.7")
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusTooManyRequests, resp.Code)
+	assert.Equal(s.T(), "90", resp.Header().Get("Retry-After"))
+}
+
+func (s *RouterSuite) TestLoginUserRequiresTwoFactor() {
+	s.service.EXPECT().LoginUser(gomock.Any(), login, password, gomock.Any()).
+		Return(dto.TokenPair{}, &service.TwoFactorRequiredError{ChallengeToken: "challenge"})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/login", credsBody(login, password))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusAccepted, resp.Code)
+	assert.Empty(s.T(), resp.Header().Get("Authorization"))
+	var body TwoFactorChallengeResponse
+	assert.NoError(s.T(), json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(s.T(), "challenge", body.ChallengeToken)
+}
+
+func (s *RouterSuite) TestTwoFactorLogin() {
+	s.service.EXPECT().LoginTwoFactor(gomock.Any(), "challenge", "123456", dto.ClientInfo{IP: "192.0.2.1"}).Return(tokens, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/login/2fa", bytes.NewBufferString(`{"challenge_token":"challenge","code":"123456"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+	assert.Equal(s.T(), token, resp.Header().Get("Authorization"))
+}
+
+func (s *RouterSuite) TestTwoFactorLoginInvalidCode() {
+	s.service.EXPECT().LoginTwoFactor(gomock.Any(), "challenge", "000000", gomock.Any()).Return(dto.TokenPair{}, service.ErrorInvalidTOTPCode)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/login/2fa", bytes.NewBufferString(`{"challenge_token":"challenge","code":"000000"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusUnauthorized, resp.Code)
+}
+
 func (s *RouterSuite) TestLoginUserWrongMimeType() {
 	req := httptest.NewRequest(http.MethodPost, "/api/user/login", credsBody(login, password))
 	req.Header.Set("Content-Type", "text/plain")
@@ -96,7 +168,1039 @@ func (s *RouterSuite) TestLoginUserWrongMimeType() {
 	assert.Equal(s.T(), http.StatusBadRequest, resp.Code)
 }
 
+func (s *RouterSuite) TestRefreshTokensSuccess() {
+	s.service.EXPECT().RefreshTokens(gomock.Any(), tokens.RefreshToken).Return(tokens, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/token/refresh", bytes.NewBufferString(`{"refresh_token":"refreshToken"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+	assert.Equal(s.T(), token, resp.Header().Get("Authorization"))
+	assert.Equal(s.T(), tokens.RefreshToken, resp.Header().Get("Refresh-Token"))
+}
+
+func (s *RouterSuite) TestRefreshTokensReused() {
+	s.service.EXPECT().RefreshTokens(gomock.Any(), tokens.RefreshToken).Return(dto.TokenPair{}, service.ErrorRefreshTokenReused)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/token/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: "Refresh-Token", Value: tokens.RefreshToken})
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusUnauthorized, resp.Code)
+}
+
+func (s *RouterSuite) TestLogoutSuccess() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().Logout(gomock.Any(), token, tokens.RefreshToken).Return(nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "Authorization", Value: "Bearer " + token})
+	req.AddCookie(&http.Cookie{Name: "Refresh-Token", Value: tokens.RefreshToken})
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+}
+
+func (s *RouterSuite) TestLogoutUnauthorized() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(dto.Principal{}, service.ErrorTokenRevoked)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "Authorization", Value: "Bearer " + token})
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusUnauthorized, resp.Code)
+}
+
+func (s *RouterSuite) TestAuthWithBearerHeader() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().GetBalanceByUserID(gomock.Any(), "userID").Return(dto.Balance{}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/balance", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+}
+
+func (s *RouterSuite) TestAuthChallenges() {
+	tests := []struct {
+		name          string
+		header        string
+		parseErr      error
+		wantStatus    int
+		wantChallenge string
+	}{
+		{"missing", "", nil, http.StatusUnauthorized, `Bearer realm="gophermart"`},
+		{"malformed", "Basic abc", nil, http.StatusBadRequest, `Bearer realm="gophermart", error="invalid_request", error_description="malformed"`},
+		{"expired", "Bearer " + token, service.ErrorTokenExpired, http.StatusUnauthorized, `Bearer realm="gophermart", error="invalid_token", error_description="expired"`},
+		{"revoked", "Bearer " + token, service.ErrorTokenRevoked, http.StatusUnauthorized, `Bearer realm="gophermart", error="invalid_token", error_description="revoked"`},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			if tt.parseErr != nil {
+				s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(dto.Principal{}, tt.parseErr)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/user/balance", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			resp := httptest.NewRecorder()
+
+			s.handler.ServeHTTP(resp, req)
+
+			assert.Equal(s.T(), tt.wantStatus, resp.Code)
+			assert.Equal(s.T(), tt.wantChallenge, resp.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func (s *RouterSuite) TestGetJWKS() {
+	s.service.EXPECT().GetJWKS().Return(dto.JWKS{Keys: []dto.JWK{{KeyType: "OKP", KeyID: "kid", Algorithm: "EdDSA"}}})
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+	var jwks dto.JWKS
+	assert.NoError(s.T(), json.NewDecoder(resp.Body).Decode(&jwks))
+	assert.Equal(s.T(), "kid", jwks.Keys[0].KeyID)
+}
+
+func (s *RouterSuite) TestChangePasswordWeakPassword() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().ChangePassword(gomock.Any(), "userID", password, "qwerty", gomock.Any()).
+		Return(dto.TokenPair{}, &service.WeakPasswordError{Reason: "is too common"})
+
+	req := httptest.NewRequest(http.MethodPut, "/api/user/password", bytes.NewBufferString(`{"old_password":"password","new_password":"qwerty"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusBadRequest, resp.Code)
+}
+
+func (s *RouterSuite) TestChangePasswordLocked() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().ChangePassword(gomock.Any(), "userID", "wrongPassword", "Tr0ub4dor&3-horse", gomock.Any()).
+		Return(dto.TokenPair{}, &service.LoginLockedError{RetryAfter: 90 * time.Second})
+
+	req := httptest.NewRequest(http.MethodPut, "/api/user/password",
+		bytes.NewBufferString(`{"old_password":"wrongPassword","new_password":"Tr0ub4dor&3-horse"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusTooManyRequests, resp.Code)
+	assert.Equal(s.T(), "90", resp.Header().Get("Retry-After"))
+}
+
+func (s *RouterSuite) TestAdminRouteForbiddenForUser() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/admin/users/otherUserID/roles", bytes.NewBufferString(`{"roles":["admin"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusForbidden, resp.Code)
+}
+
+func (s *RouterSuite) TestAdminSetUserRoles() {
+	admin := dto.Principal{UserID: "adminID", Roles: []string{dto.RoleAdmin, dto.RoleUser}}
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(admin, nil)
+	s.service.EXPECT().SetUserRoles(gomock.Any(), "otherUserID", []string{dto.RoleSupport}).
+		Return([]string{dto.RoleSupport, dto.RoleUser}, nil)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/admin/users/otherUserID/roles", bytes.NewBufferString(`{"roles":["support"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+	var body UserRolesResponse
+	assert.NoError(s.T(), json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(s.T(), []string{dto.RoleSupport, dto.RoleUser}, body.Roles)
+}
+
+func (s *RouterSuite) TestAdminGetLoginLockouts() {
+	admin := dto.Principal{UserID: "adminID", Roles: []string{dto.RoleAdmin, dto.RoleUser}}
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(admin, nil)
+	lockedAt := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	s.service.EXPECT().GetLoginLockouts(gomock.Any(), 10).Return([]dto.LoginLockout{
+		{Kind: dto.LoginAttemptKindLogin, Key: "alice", Failures: 5, LockedAt: lockedAt, LockedUntil: lockedAt.Add(time.Minute)},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/lockouts?limit=10", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+	var body []dto.LoginLockout
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&body))
+	s.Require().Len(body, 1)
+	assert.Equal(s.T(), "alice", body[0].Key)
+	assert.True(s.T(), lockedAt.Add(time.Minute).Equal(body[0].LockedUntil))
+}
+
+func (s *RouterSuite) TestLoginLockoutsForbiddenForSupport() {
+	support := dto.Principal{UserID: "supportID", Roles: []string{dto.RoleSupport, dto.RoleUser}}
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(support, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/lockouts", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusForbidden, resp.Code)
+}
+
+func (s *RouterSuite) TestAdminGetLoginLockoutsWithInvalidLimit() {
+	admin := dto.Principal{UserID: "adminID", Roles: []string{dto.RoleAdmin, dto.RoleUser}}
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(admin, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/lockouts?limit=0", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusBadRequest, resp.Code)
+}
+
+func (s *RouterSuite) TestExportUserData() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().ExportUserData(gomock.Any(), "userID").
+		Return(dto.UserDataExport{Profile: dto.UserProfile{ID: "userID", Login: login}}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/export", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+	assert.Contains(s.T(), resp.Header().Get("Content-Disposition"), "attachment")
+	var export dto.UserDataExport
+	assert.NoError(s.T(), json.NewDecoder(resp.Body).Decode(&export))
+	assert.Equal(s.T(), login, export.Profile.Login)
+}
+
+func (s *RouterSuite) TestDeleteAccount() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().DeleteAccount(gomock.Any(), "userID", password, "").Return(nil)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user", credsBody(login, password))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+}
+
+func (s *RouterSuite) TestDeleteAccountWrongPassword() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().DeleteAccount(gomock.Any(), "userID", password, "").Return(service.ErrorInvalidPassword)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user", credsBody(login, password))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusForbidden, resp.Code)
+}
+
+func (s *RouterSuite) TestDeleteAccountLocked() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().DeleteAccount(gomock.Any(), "userID", password, "").
+		Return(&service.LoginLockedError{RetryAfter: 30 * time.Second})
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user", credsBody(login, password))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusTooManyRequests, resp.Code)
+	assert.Equal(s.T(), "30", resp.Header().Get("Retry-After"))
+}
+
+func (s *RouterSuite) TestGetSessions() {
+	current := dto.Principal{UserID: "userID", Roles: []string{dto.RoleUser}, SessionID: "sessionID"}
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(current, nil)
+	s.service.EXPECT().GetSessions(gomock.Any(), "userID", "sessionID").
+		Return([]dto.Session{{ID: "sessionID", UserAgent: "agent", Current: true}}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+	var sessions []dto.Session
+	assert.NoError(s.T(), json.NewDecoder(resp.Body).Decode(&sessions))
+	assert.True(s.T(), sessions[0].Current)
+}
+
+func (s *RouterSuite) TestRevokeUnknownSession() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().RevokeSession(gomock.Any(), "userID", "unknown").Return(storage.ErrItemNotFound)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user/sessions/unknown", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusNotFound, resp.Code)
+}
+
+func (s *RouterSuite) TestCreateAPIKey() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().CreateAPIKey(gomock.Any(), "userID", "ci", []string{dto.ScopeOrdersRead}).
+		Return(dto.CreatedAPIKey{APIKey: dto.APIKey{ID: "keyID", Name: "ci"}, Key: "gmk_key"}, nil)
+
+	body, _ := json.Marshal(CreateAPIKeyRequest{Name: "ci", Scopes: []string{dto.ScopeOrdersRead}})
+	req := httptest.NewRequest(http.MethodPost, "/api/user/api-keys", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusCreated, resp.Code)
+	var created dto.CreatedAPIKey
+	assert.NoError(s.T(), json.NewDecoder(resp.Body).Decode(&created))
+	assert.Equal(s.T(), "gmk_key", created.Key)
+}
+
+func (s *RouterSuite) TestAPIKeyScopes() {
+	apiKeyPrincipal := dto.Principal{UserID: "userID", Roles: []string{dto.RoleUser}, APIKeyID: "keyID",
+		Scopes: []string{dto.ScopeBalanceRead}}
+	s.service.EXPECT().ParseAPIKey(gomock.Any(), "gmk_key").Return(apiKeyPrincipal, nil).AnyTimes()
+	s.service.EXPECT().GetBalanceByUserID(gomock.Any(), "userID").Return(dto.Balance{}, nil)
+
+	tests := []struct {
+		method string
+		target string
+		want   int
+	}{
+		{http.MethodGet, "/api/user/balance", http.StatusOK},
+		{http.MethodGet, "/api/user/orders", http.StatusForbidden},
+		{http.MethodPost, "/api/user/balance/withdraw", http.StatusForbidden},
+		{http.MethodGet, "/api/user/sessions", http.StatusForbidden},
+		{http.MethodPost, "/api/user/api-keys", http.StatusForbidden},
+		{http.MethodPut, "/api/admin/users/userID/roles", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		req.Header.Set("X-API-Key", "gmk_key")
+
+		resp := httptest.NewRecorder()
+
+		s.handler.ServeHTTP(resp, req)
+
+		assert.Equal(s.T(), tt.want, resp.Code, tt.target)
+	}
+}
+
+func (s *RouterSuite) TestInvalidAPIKey() {
+	s.service.EXPECT().ParseAPIKey(gomock.Any(), "gmk_revoked").Return(dto.Principal{}, service.ErrorInvalidAPIKey)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/balance", nil)
+	req.Header.Set("X-API-Key", "gmk_revoked")
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusUnauthorized, resp.Code)
+}
+
+func (s *RouterSuite) TestOIDCLoginRedirects() {
+	s.service.EXPECT().StartOIDCLogin(gomock.Any()).
+		Return(dto.OIDCAuthorization{URL: "https://idp.test/authorize?state=state", StateToken: "stateToken"}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/oidc/login", nil)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusFound, resp.Code)
+	assert.Equal(s.T(), "https://idp.test/authorize?state=state", resp.Header().Get("Location"))
+	cookies := resp.Result().Cookies()
+	s.Require().Len(cookies, 1)
+	assert.Equal(s.T(), "stateToken", cookies[0].Value)
+	assert.True(s.T(), cookies[0].HttpOnly)
+}
+
+func (s *RouterSuite) TestOIDCCallback() {
+	s.service.EXPECT().FinishOIDCLogin(gomock.Any(), "stateToken", "state", "code", gomock.Any()).Return(tokens, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/oidc/callback?state=state&code=code", nil)
+	req.AddCookie(&http.Cookie{Name: oidcStateCookieName, Value: "stateToken"})
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+	assert.Equal(s.T(), token, resp.Header().Get("Authorization"))
+}
+
+func (s *RouterSuite) TestOIDCCallbackRequiresSecondFactor() {
+	s.service.EXPECT().FinishOIDCLogin(gomock.Any(), "stateToken", "state", "code", gomock.Any()).
+		Return(dto.TokenPair{}, &service.TwoFactorRequiredError{ChallengeToken: "challenge"})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/oidc/callback?state=state&code=code", nil)
+	req.AddCookie(&http.Cookie{Name: oidcStateCookieName, Value: "stateToken"})
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusAccepted, resp.Code)
+	assert.Empty(s.T(), resp.Header().Get("Authorization"))
+	var body TwoFactorChallengeResponse
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(s.T(), "challenge", body.ChallengeToken)
+}
+
+func (s *RouterSuite) TestOIDCCallbackWithInvalidState() {
+	s.service.EXPECT().FinishOIDCLogin(gomock.Any(), "", "state", "code", gomock.Any()).
+		Return(dto.TokenPair{}, service.ErrorInvalidOIDCState)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/oidc/callback?state=state&code=code", nil)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusUnauthorized, resp.Code)
+}
+
+func (s *RouterSuite) TestRegisterUserWithUnverifiedEmail() {
+	s.service.EXPECT().AddUser(gomock.Any(), login, password, gomock.Any()).Return(dto.TokenPair{}, service.ErrorEmailNotVerified)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/register", credsBody(login, password))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusAccepted, resp.Code)
+	assert.Empty(s.T(), resp.Header().Get("Authorization"))
+}
+
+func (s *RouterSuite) TestLoginUserWithUnverifiedEmail() {
+	s.service.EXPECT().LoginUser(gomock.Any(), login, password, gomock.Any()).Return(dto.TokenPair{}, service.ErrorEmailNotVerified)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/login", credsBody(login, password))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusForbidden, resp.Code)
+}
+
+func (s *RouterSuite) TestVerifyEmail() {
+	s.service.EXPECT().VerifyEmail(gomock.Any(), "verification").Return(nil)
+	s.service.EXPECT().VerifyEmail(gomock.Any(), "used").Return(service.ErrorTokenRevoked)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/email/verify?token=verification", nil)
+	resp := httptest.NewRecorder()
+	s.handler.ServeHTTP(resp, req)
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/user/email/verify?token=used", nil)
+	resp = httptest.NewRecorder()
+	s.handler.ServeHTTP(resp, req)
+	assert.Equal(s.T(), http.StatusBadRequest, resp.Code)
+}
+
+func (s *RouterSuite) TestPasswordResetRequest() {
+	s.service.EXPECT().RequestPasswordReset(gomock.Any(), login).Return(nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/password/reset-request", strings.NewReader(`{"login":"`+login+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusAccepted, resp.Code)
+}
+
+func (s *RouterSuite) TestPasswordResetRequestRateLimited() {
+	s.service.EXPECT().RequestPasswordReset(gomock.Any(), login).
+		Return(&service.RateLimitedError{RetryAfter: 30 * time.Second})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/password/reset-request", strings.NewReader(`{"login":"`+login+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusTooManyRequests, resp.Code)
+	assert.Equal(s.T(), "30", resp.Header().Get("Retry-After"))
+}
+
+func (s *RouterSuite) TestResetPassword() {
+	s.service.EXPECT().ResetPassword(gomock.Any(), "resetToken", "newPassword1").Return(nil)
+	s.service.EXPECT().ResetPassword(gomock.Any(), "usedToken", "newPassword1").Return(service.ErrorInvalidPasswordResetToken)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/password/reset",
+		strings.NewReader(`{"token":"resetToken","new_password":"newPassword1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	s.handler.ServeHTTP(resp, req)
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/user/password/reset",
+		strings.NewReader(`{"token":"usedToken","new_password":"newPassword1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	s.handler.ServeHTTP(resp, req)
+	assert.Equal(s.T(), http.StatusBadRequest, resp.Code)
+}
+
+func (s *RouterSuite) TestGetOrders() {
+	orders := []dto.Order{{Number: "12345678903", Status: dto.StatusNew, UploadedAt: time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)}}
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().GetOrdersByUser(gomock.Any(), "userID", dto.OrderQuery{}).Return(dto.OrderPage{Orders: orders}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+	assert.Empty(s.T(), resp.Header().Get("Link"))
+	var received []dto.Order
+	assert.NoError(s.T(), json.NewDecoder(resp.Body).Decode(&received))
+	assert.Equal(s.T(), orders[0].Number, received[0].Number)
+}
+
+func (s *RouterSuite) TestGetOrdersPage() {
+	from := time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().GetOrdersByUser(gomock.Any(), "userID", dto.OrderQuery{
+		Limit:        10,
+		Statuses:     []string{dto.StatusNew, dto.StatusProcessing},
+		UploadedFrom: &from,
+		Sort:         "accrual",
+	}).Return(dto.OrderPage{Orders: []dto.Order{{Number: "12345678903"}}, NextCursor: "next"}, nil)
+
+	req := httptest.NewRequest(http.MethodGet,
+		"/api/user/orders?limit=10&status=new,processing&uploaded_from=2022-09-01T00:00:00Z&sort=accrual", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+	assert.Equal(s.T(),
+		`</api/user/orders?cursor=next&limit=10&sort=accrual&status=new%2Cprocessing&uploaded_from=2022-09-01T00%3A00%3A00Z>; rel="next"`,
+		resp.Header().Get("Link"))
+}
+
+func (s *RouterSuite) TestGetOrdersWithInvalidQuery() {
+	for _, query := range []string{"limit=0", "limit=ten", "uploaded_to=yesterday"} {
+		s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/orders?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp := httptest.NewRecorder()
+
+		s.handler.ServeHTTP(resp, req)
+
+		assert.Equal(s.T(), http.StatusBadRequest, resp.Code, query)
+	}
+}
+
+func (s *RouterSuite) TestCreateOrder() {
+	for body, orderNum := range map[string]string{
+		"123456789012345678901234":     "123456789012345678901234",
+		"  001234567890123456789012\n": "001234567890123456789012",
+	} {
+		s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+		s.service.EXPECT().AddOrder(gomock.Any(), orderNum, "userID").Return(nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/user/orders", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "text/plain")
+
+		resp := httptest.NewRecorder()
+
+		s.handler.ServeHTTP(resp, req)
+
+		assert.Equal(s.T(), http.StatusAccepted, resp.Code, body)
+	}
+}
+
+func (s *RouterSuite) TestCreateOrderWithInvalidNumber() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil).Times(2)
+	s.service.EXPECT().AddOrder(gomock.Any(), "1234567890a", "userID").
+		Return(fmt.Errorf("error during validating order format: %w", service.ErrorInvalidOrderNumberFormat))
+
+	for body, code := range map[string]int{
+		"1234567890a": http.StatusUnprocessableEntity,
+		" \n":         http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/user/orders", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "text/plain")
+
+		resp := httptest.NewRecorder()
+
+		s.handler.ServeHTTP(resp, req)
+
+		assert.Equal(s.T(), code, resp.Code, body)
+	}
+}
+
+func (s *RouterSuite) TestCreateOrderWithTooLargeBody() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil).Times(2)
+
+	// a small gzip body expanding beyond the limit is rejected as well
+	for encoding, body := range map[string][]byte{
+		"":     bytes.Repeat([]byte("1"), maxOrderBodySize+1),
+		"gzip": gzipped(s.T(), bytes.Repeat([]byte("1"), maxOrderBodySize+1)),
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/user/orders", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Content-Encoding", encoding)
+
+		resp := httptest.NewRecorder()
+
+		s.handler.ServeHTTP(resp, req)
+
+		assert.Equal(s.T(), http.StatusRequestEntityTooLarge, resp.Code, encoding)
+	}
+}
+
+func (s *RouterSuite) TestGetOrderEvents() {
+	events := make(chan dto.OrderEvent, 1)
+	events <- dto.OrderEvent{ID: 6, Number: "12345678903", OrderStatusChange: dto.OrderStatusChange{Status: dto.StatusProcessed}}
+	close(events)
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().SubscribeOrderEvents(gomock.Any(), "userID", int64(5)).Return((<-chan dto.OrderEvent)(events), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/orders/events", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", "5")
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+	assert.Equal(s.T(), "text/event-stream", resp.Header().Get("Content-Type"))
+	body := resp.Body.String()
+	assert.True(s.T(), strings.HasPrefix(body, "retry: 3000\n\n"), body)
+	assert.Contains(s.T(), body, "id: 6\nevent: order\ndata: {\"number\":\"12345678903\",\"status\":\"PROCESSED\"")
+}
+
+func (s *RouterSuite) TestOnlyOrderEventsHaveNoTimeout() {
+	hasDeadline := func(ctx context.Context) bool {
+		_, ok := ctx.Deadline()
+		return ok
+	}
+	events := make(chan dto.OrderEvent)
+	close(events)
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil).Times(2)
+	s.service.EXPECT().GetBalanceByUserID(gomock.Any(), "userID").
+		DoAndReturn(func(ctx context.Context, _ string) (dto.Balance, error) {
+			assert.True(s.T(), hasDeadline(ctx))
+			return dto.Balance{}, nil
+		})
+	s.service.EXPECT().SubscribeOrderEvents(gomock.Any(), "userID", int64(0)).
+		DoAndReturn(func(ctx context.Context, _ string, _ int64) (<-chan dto.OrderEvent, error) {
+			assert.False(s.T(), hasDeadline(ctx))
+			return events, nil
+		})
+
+	// the header of the client does not lift the timeout of other routes
+	for _, path := range []string{"/api/user/balance", "/api/user/orders/events"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "text/event-stream")
+
+		resp := httptest.NewRecorder()
+
+		s.handler.ServeHTTP(resp, req)
+
+		assert.Equal(s.T(), http.StatusOK, resp.Code, path)
+	}
+}
+
+func (s *RouterSuite) TestGetOrderEventsHeartbeat() {
+	defer func(heartbeat time.Duration) { orderEventsHeartbeat = heartbeat }(orderEventsHeartbeat)
+	orderEventsHeartbeat = 10 * time.Millisecond
+
+	events := make(chan dto.OrderEvent)
+	time.AfterFunc(100*time.Millisecond, func() { close(events) })
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().SubscribeOrderEvents(gomock.Any(), "userID", int64(0)).Return((<-chan dto.OrderEvent)(events), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/orders/events", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Contains(s.T(), resp.Body.String(), ": heartbeat\n\n")
+}
+
+func (s *RouterSuite) TestGetOrderEventsWithInvalidLastEventID() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/orders/events", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Last-Event-ID", "last")
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusBadRequest, resp.Code)
+}
+
+func (s *RouterSuite) TestCreateOrders() {
+	results := []dto.OrderUploadResult{
+		{Number: "12345678903", Result: dto.OrderUploadAccepted},
+		{Number: "79927398713", Result: dto.OrderUploadAlreadyUploaded},
+	}
+	for contentType, body := range map[string]string{
+		"application/json": `["12345678903", 79927398713]`,
+		"text/plain":       "12345678903\r\n\n  79927398713\n",
+	} {
+		s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+		s.service.EXPECT().AddOrders(gomock.Any(), []string{"12345678903", "79927398713"}, "userID").Return(results, nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/user/orders/batch", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", contentType)
+
+		resp := httptest.NewRecorder()
+
+		s.handler.ServeHTTP(resp, req)
+
+		assert.Equal(s.T(), http.StatusOK, resp.Code, contentType)
+		var received []dto.OrderUploadResult
+		assert.NoError(s.T(), json.NewDecoder(resp.Body).Decode(&received))
+		assert.Equal(s.T(), results, received, contentType)
+	}
+}
+
+func (s *RouterSuite) TestCreateOrdersWithInvalidBatch() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil).Times(2)
+	s.service.EXPECT().AddOrders(gomock.Any(), []string{}, "userID").Return(nil, service.ErrorEmptyValue)
+
+	for _, body := range []string{`["12345678903"`, "\n"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/user/orders/batch", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp := httptest.NewRecorder()
+
+		s.handler.ServeHTTP(resp, req)
+
+		assert.Equal(s.T(), http.StatusBadRequest, resp.Code, body)
+	}
+}
+
+func (s *RouterSuite) TestCreateOrdersWithTooLargeBody() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+
+	body := gzipped(s.T(), bytes.Repeat([]byte("12345678903\n"), maxOrdersBatchBodySize/12+1))
+	s.Require().Less(len(body), maxOrdersBatchBodySize)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/orders/batch", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusRequestEntityTooLarge, resp.Code)
+}
+
+func (s *RouterSuite) TestGetOrder() {
+	order := dto.OrderDetails{
+		Order:   dto.Order{Number: "12345678903", Status: dto.StatusProcessing},
+		History: []dto.OrderStatusChange{{Status: dto.StatusNew}, {Status: dto.StatusProcessing, Attempt: 1}},
+	}
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().GetOrder(gomock.Any(), "userID", "12345678903").Return(order, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/orders/12345678903", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+	var received dto.OrderDetails
+	assert.NoError(s.T(), json.NewDecoder(resp.Body).Decode(&received))
+	assert.Equal(s.T(), "12345678903", received.Number)
+	assert.Len(s.T(), received.History, 2)
+	assert.Equal(s.T(), 1, received.History[1].Attempt)
+}
+
+func (s *RouterSuite) TestGetUnknownOrder() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().GetOrder(gomock.Any(), "userID", "12345678903").Return(dto.OrderDetails{}, storage.ErrItemNotFound)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/orders/12345678903", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusNotFound, resp.Code)
+}
+
+func (s *RouterSuite) TestRecheckOrder() {
+	checkedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().RecheckOrder(gomock.Any(), "userID", "12345678903").
+		Return(dto.Order{Number: "12345678903", Status: dto.StatusInvalid, LastCheckedAt: &checkedAt}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/orders/12345678903/recheck", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusAccepted, resp.Code)
+	var received map[string]interface{}
+	assert.NoError(s.T(), json.NewDecoder(resp.Body).Decode(&received))
+	assert.Equal(s.T(), float64(0), received["attempts"])
+	assert.Equal(s.T(), checkedAt.Format(time.RFC3339), received["last_checked_at"])
+}
+
+func (s *RouterSuite) TestRecheckOrderRateLimited() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().RecheckOrder(gomock.Any(), "userID", "12345678903").
+		Return(dto.Order{}, &service.RateLimitedError{RetryAfter: 30 * time.Second})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/orders/12345678903/recheck", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusTooManyRequests, resp.Code)
+	assert.Equal(s.T(), "30", resp.Header().Get("Retry-After"))
+}
+
+func (s *RouterSuite) TestSupportRechecksAnyOrder() {
+	support := dto.Principal{UserID: "supportID", Roles: []string{dto.RoleSupport, dto.RoleUser}}
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(support, nil).Times(2)
+	s.service.EXPECT().RecheckAnyOrder(gomock.Any(), "supportID", "12345678903").
+		Return(dto.Order{Number: "12345678903", Status: dto.StatusNew}, nil)
+	s.service.EXPECT().RecheckAnyOrder(gomock.Any(), "supportID", "79927398713").
+		Return(dto.Order{}, storage.ErrOrderAlreadyProcessed)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/orders/12345678903/recheck", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	s.handler.ServeHTTP(resp, req)
+	assert.Equal(s.T(), http.StatusAccepted, resp.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/admin/orders/79927398713/recheck", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp = httptest.NewRecorder()
+	s.handler.ServeHTTP(resp, req)
+	assert.Equal(s.T(), http.StatusConflict, resp.Code)
+}
+
+func (s *RouterSuite) TestSupportGetsAnyOrder() {
+	support := dto.Principal{UserID: "supportID", Roles: []string{dto.RoleSupport, dto.RoleUser}}
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(support, nil).Times(2)
+	s.service.EXPECT().GetAnyOrder(gomock.Any(), "12345678903").Return(dto.OrderDetails{Order: dto.Order{Number: "12345678903"}}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/orders/12345678903", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	s.handler.ServeHTTP(resp, req)
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+
+	req = httptest.NewRequest(http.MethodPut, "/api/admin/users/userID/roles", strings.NewReader(`{"roles":["admin"]}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	s.handler.ServeHTTP(resp, req)
+	assert.Equal(s.T(), http.StatusForbidden, resp.Code)
+}
+
+func (s *RouterSuite) TestCreateWebhook() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().CreateWebhook(gomock.Any(), "userID", "https://crm.example.com/hooks", []string{dto.WebhookEventOrderProcessed}).
+		Return(dto.CreatedWebhook{Webhook: dto.Webhook{ID: "webhookID", URL: "https://crm.example.com/hooks"}, Secret: "secret"}, nil)
+
+	body, _ := json.Marshal(CreateWebhookRequest{URL: "https://crm.example.com/hooks", EventTypes: []string{dto.WebhookEventOrderProcessed}})
+	req := httptest.NewRequest(http.MethodPost, "/api/user/webhooks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusCreated, resp.Code)
+	assert.Equal(s.T(), "no-store", resp.Header().Get("Cache-Control"))
+	var created dto.CreatedWebhook
+	assert.NoError(s.T(), json.NewDecoder(resp.Body).Decode(&created))
+	assert.Equal(s.T(), "secret", created.Secret)
+}
+
+func (s *RouterSuite) TestCreateWebhookWithUnknownEvent() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().CreateWebhook(gomock.Any(), "userID", "https://crm.example.com/hooks", []string{"order.created"}).
+		Return(dto.CreatedWebhook{}, service.ErrorUnknownWebhookEvent)
+
+	body, _ := json.Marshal(CreateWebhookRequest{URL: "https://crm.example.com/hooks", EventTypes: []string{"order.created"}})
+	req := httptest.NewRequest(http.MethodPost, "/api/user/webhooks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusBadRequest, resp.Code)
+}
+
+func (s *RouterSuite) TestGetWebhookDeliveries() {
+	status := http.StatusBadGateway
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().GetWebhookDeliveries(gomock.Any(), "userID", "webhookID").Return([]dto.WebhookDelivery{
+		{ID: 1, EventType: dto.WebhookEventOrderProcessed, Payload: json.RawMessage(`{}`), Status: dto.WebhookDeliveryPending,
+			Attempts: 1, ResponseStatus: &status, LastError: "unexpected response status 502", Secret: "secret"},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/webhooks/webhookID/deliveries", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+	assert.NotContains(s.T(), resp.Body.String(), "secret")
+	var deliveries []dto.WebhookDelivery
+	assert.NoError(s.T(), json.NewDecoder(resp.Body).Decode(&deliveries))
+	assert.Equal(s.T(), http.StatusBadGateway, *deliveries[0].ResponseStatus)
+}
+
+func (s *RouterSuite) TestDeleteUnknownWebhook() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().DeleteWebhook(gomock.Any(), "userID", "unknown").Return(storage.ErrItemNotFound)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user/webhooks/unknown", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusNotFound, resp.Code)
+}
+
+func (s *RouterSuite) TestGetAccrualAdjustments() {
+	s.service.EXPECT().ParseJWTToken(gomock.Any(), token).Return(principal, nil)
+	s.service.EXPECT().GetAccrualAdjustments(gomock.Any(), "userID").Return([]dto.AccrualAdjustment{
+		{Order: "12345678903", Amount: decimal.NewFromInt(-50), PreviousAccrual: decimal.NewFromInt(150), Accrual: decimal.NewFromInt(100)},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/balance/adjustments", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+
+	s.handler.ServeHTTP(resp, req)
+
+	assert.Equal(s.T(), http.StatusOK, resp.Code)
+	var adjustments []dto.AccrualAdjustment
+	assert.NoError(s.T(), json.NewDecoder(resp.Body).Decode(&adjustments))
+	assert.True(s.T(), adjustments[0].Amount.Equal(decimal.NewFromInt(-50)))
+}
+
 func credsBody(login, password string) io.Reader {
 	creds, _ := json.Marshal(userCreds{login, password})
 	return bytes.NewBuffer(creds)
 }
+
+func gzipped(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+	return buf.Bytes()
+}
@@ -0,0 +1,14 @@
+package dto
+
+import "time"
+
+// AccrualJob is the polling of the accrual of an order. A job is leased by one instance at a time, NextAttemptAt
+// is nil once its attempts are exhausted.
+type AccrualJob struct {
+	OrderNumber   string
+	Attempts      int
+	NextAttemptAt *time.Time
+	LastError     string
+	LeaseOwner    string
+	LeaseUntil    *time.Time
+}
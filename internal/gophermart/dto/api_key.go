@@ -0,0 +1,26 @@
+package dto
+
+import "time"
+
+const (
+	ScopeOrdersWrite = "orders:write"
+	ScopeOrdersRead  = "orders:read"
+	ScopeBalanceRead = "balance:read"
+	ScopeWithdraw    = "withdraw"
+)
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CreatedAPIKey holds the plain key, which is returned only once on creation.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -1,8 +1,25 @@
 package dto
 
-import "github.com/shopspring/decimal"
+import (
+	"time"
 
+	"github.com/shopspring/decimal"
+)
+
+// Balance of a user. Debt is the part of the revoked accruals which exceeded the current balance, it is repaid
+// from the next accruals.
 type Balance struct {
 	Current   decimal.Decimal `json:"current"`
 	Withdrawn decimal.Decimal `json:"withdrawn"`
+	Debt      decimal.Decimal `json:"debt"`
+}
+
+// AccrualAdjustment is a change of the accrual of an already processed order found by the reconciliation, a
+// negative Amount is a clawback.
+type AccrualAdjustment struct {
+	Order           string          `json:"order"`
+	Amount          decimal.Decimal `json:"amount"`
+	PreviousAccrual decimal.Decimal `json:"previous_accrual"`
+	Accrual         decimal.Decimal `json:"accrual"`
+	CreatedAt       time.Time       `json:"created_at"`
 }
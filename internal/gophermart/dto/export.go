@@ -0,0 +1,20 @@
+package dto
+
+import "time"
+
+type UserProfile struct {
+	ID               string   `json:"id"`
+	Login            string   `json:"login"`
+	Roles            []string `json:"roles"`
+	TwoFactorEnabled bool     `json:"two_factor_enabled"`
+}
+
+// UserDataExport is everything stored about a user, returned on a personal data export request.
+type UserDataExport struct {
+	Profile            UserProfile         `json:"profile"`
+	Balance            Balance             `json:"balance"`
+	Orders             []Order             `json:"orders"`
+	Withdrawals        []Withdraw          `json:"withdrawals"`
+	AccrualAdjustments []AccrualAdjustment `json:"accrual_adjustments"`
+	ExportedAt         time.Time           `json:"exported_at"`
+}
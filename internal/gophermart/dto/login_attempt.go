@@ -0,0 +1,25 @@
+package dto
+
+import "time"
+
+const (
+	LoginAttemptKindLogin = "login"
+	LoginAttemptKindIP    = "ip"
+	// LoginAttemptKindChangePassword counts wrong current passwords given by a user changing the password
+	LoginAttemptKindChangePassword = "change_password"
+	// LoginAttemptKindDeleteAccount counts wrong passwords and codes given by a user deleting the account
+	LoginAttemptKindDeleteAccount = "delete_account"
+)
+
+type LoginAttempts struct {
+	Failures int
+	Lockouts int
+}
+
+type LoginLockout struct {
+	Kind        string    `json:"kind"`
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	LockedAt    time.Time `json:"locked_at"`
+	LockedUntil time.Time `json:"locked_until"`
+}
@@ -14,12 +14,24 @@ const (
 	StatusRegistered = "REGISTERED"
 )
 
+// Results of uploading an order in a batch.
+const (
+	OrderUploadAccepted         = "accepted"
+	OrderUploadAlreadyUploaded  = "already_uploaded"
+	OrderUploadOwnedByOtherUser = "owned_by_other_user"
+	OrderUploadInvalidFormat    = "invalid_format"
+)
+
+// Order is an uploaded order. Attempts is the number of polling attempts since the upload or the last recheck,
+// LastCheckedAt is the time of the last of them.
 type Order struct {
-	Number     string          `json:"number"`
-	Status     string          `json:"status"`
-	Accrual    decimal.Decimal `json:"accrual,omitempty"`
-	UploadedAt time.Time       `json:"uploaded_at"`
-	UserID     string          `json:"-"`
+	Number        string          `json:"number"`
+	Status        string          `json:"status"`
+	Accrual       decimal.Decimal `json:"accrual,omitempty"`
+	UploadedAt    time.Time       `json:"uploaded_at"`
+	Attempts      int             `json:"attempts"`
+	LastCheckedAt *time.Time      `json:"last_checked_at,omitempty"`
+	UserID        string          `json:"-"`
 }
 
 func NewOrder(number string, userID string) Order {
@@ -30,3 +42,74 @@ func NewOrder(number string, userID string) Order {
 		Status:     StatusNew,
 	}
 }
+
+const (
+	OrderSortUploadedAt = "uploaded_at"
+	OrderSortAccrual    = "accrual"
+)
+
+// OrderQuery is a request for a page of orders. Sort is a sort field, prefixed with "-" for the descending order.
+// Cursor is the opaque cursor of the previous page. A zero Limit returns all remaining orders.
+type OrderQuery struct {
+	Limit        int
+	Cursor       string
+	Statuses     []string
+	UploadedFrom *time.Time
+	UploadedTo   *time.Time
+	Sort         string
+}
+
+// OrderFilter selects orders in storage. Orders are sorted by SortBy and then by number, After is the last order
+// of the previous page.
+type OrderFilter struct {
+	Statuses     []string
+	UploadedFrom *time.Time
+	UploadedTo   *time.Time
+	SortBy       string
+	Descending   bool
+	After        *Order
+	Limit        int
+}
+
+type OrderUploadResult struct {
+	Number string `json:"number"`
+	Result string `json:"result"`
+}
+
+type OrderPage struct {
+	Orders     []Order
+	NextCursor string
+}
+
+// OrderStatusChange is a status of an order observed by the accrual synchronizer. Attempt is the number of the
+// polling attempt which observed it, zero for the status the order is uploaded with and for the corrections found
+// by the reconciliation.
+type OrderStatusChange struct {
+	Status     string          `json:"status"`
+	Accrual    decimal.Decimal `json:"accrual"`
+	Attempt    int             `json:"attempt"`
+	ObservedAt time.Time       `json:"observed_at"`
+}
+
+// OrderEvent is an entry of the status history of an order, it is pushed to the owner of the order. The ID grows
+// with every entry, so that a client can resume after the last event it has received.
+type OrderEvent struct {
+	ID     int64  `json:"-"`
+	UserID string `json:"-"`
+	Number string `json:"number"`
+	OrderStatusChange
+}
+
+// OrderRecheck is a manual restart of the polling of an order. TriggeredBy is the user who requested it, ByAdmin
+// tells whether it was requested for an order of another user.
+type OrderRecheck struct {
+	OrderNumber string
+	TriggeredBy string
+	ByAdmin     bool
+	CreatedAt   time.Time
+}
+
+type OrderDetails struct {
+	Order
+	History []OrderStatusChange `json:"history"`
+}
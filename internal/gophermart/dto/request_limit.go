@@ -0,0 +1,15 @@
+package dto
+
+import "time"
+
+const (
+	// RequestLimitKindPasswordReset counts password reset requests of a login
+	RequestLimitKindPasswordReset = "password_reset"
+	// RequestLimitKindOrderRecheck counts order rechecks of a user
+	RequestLimitKindOrderRecheck = "order_recheck"
+)
+
+type RequestCount struct {
+	Requests        int
+	WindowStartedAt time.Time
+}
@@ -0,0 +1,20 @@
+package dto
+
+import "time"
+
+// ClientInfo describes the client a request came from.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// Session is a login on one device, it lives as long as its refresh token family.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"-"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
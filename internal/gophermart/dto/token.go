@@ -0,0 +1,25 @@
+package dto
+
+import "time"
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type RefreshToken struct {
+	TokenHash string
+	FamilyID  string
+	UserID    string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	Revoked   bool
+}
+
+type PasswordResetToken struct {
+	TokenHash string
+	UserID    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
@@ -1,7 +1,77 @@
 package dto
 
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
 type User struct {
 	ID             string
 	Login          string
 	HashedPassword string
+	Roles          []string
+	TOTPSecret     []byte
+	TOTPEnabled    bool
+	EmailVerified  bool
+}
+
+func (u User) HasRole(role string) bool {
+	return hasRole(u.Roles, role)
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID    string
+	Roles     []string
+	SessionID string
+	// APIKeyID and Scopes are set if the caller authenticated with an API key
+	APIKeyID string
+	Scopes   []string
+}
+
+func (p Principal) HasRole(role string) bool {
+	return hasRole(p.Roles, role)
+}
+
+// HasScope reports whether the caller may use an API scope. Access tokens are not limited by scopes.
+func (p Principal) HasScope(scope string) bool {
+	return p.APIKeyID == "" || contains(p.Scopes, scope)
+}
+
+func hasRole(roles []string, role string) bool {
+	return contains(roles, role)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// UserIdentity links an account of an external OpenID Connect provider to a user.
+type UserIdentity struct {
+	Issuer  string
+	Subject string
+	Email   string
+}
+
+type OIDCAuthorization struct {
+	URL        string
+	StateToken string
+}
+
+// LoginCollision is a user whose login could not be normalized because another user has the normalized login.
+type LoginCollision struct {
+	UserID          string
+	Login           string
+	NormalizedLogin string
 }
@@ -0,0 +1,75 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	WebhookEventOrderProcessed   = "order.processed"
+	WebhookEventOrderInvalid     = "order.invalid"
+	WebhookEventBalanceWithdrawn = "balance.withdrawn"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// OrderStatusWebhookEvents maps the final statuses of orders to the events they are published as.
+var OrderStatusWebhookEvents = map[string]string{
+	StatusProcessed: WebhookEventOrderProcessed,
+	StatusInvalid:   WebhookEventOrderInvalid,
+}
+
+type Webhook struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"-"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CreatedWebhook holds the signing secret, which is returned only once on creation.
+type CreatedWebhook struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhookEvent is the payload posted to the webhooks subscribed to its type.
+type WebhookEvent struct {
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookDelivery is an event queued for a webhook together with the outcome of the latest attempt.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      string          `json:"-"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	// the webhook is set for claimed deliveries only, Secret is the plain secret of a webhook created before the
+	// secrets were encrypted
+	UserID          string `json:"-"`
+	URL             string `json:"-"`
+	Secret          string `json:"-"`
+	EncryptedSecret []byte `json:"-"`
+}
+
+// WebhookSecret is a secret of a webhook which is not encrypted with the webhook key yet. Secret is a plain secret,
+// EncryptedSecret is a secret encrypted with the TOTP key before the webhooks had a key of their own.
+type WebhookSecret struct {
+	WebhookID       string
+	UserID          string
+	Secret          string
+	EncryptedSecret []byte
+}
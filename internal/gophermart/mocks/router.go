@@ -49,19 +49,49 @@ func (mr *MockGophermartServiceMockRecorder) AddOrder(arg0, arg1, arg2 interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrder", reflect.TypeOf((*MockGophermartService)(nil).AddOrder), arg0, arg1, arg2)
 }
 
+// AddOrders mocks base method.
+func (m *MockGophermartService) AddOrders(arg0 context.Context, arg1 []string, arg2 string) ([]dto.OrderUploadResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddOrders", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]dto.OrderUploadResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddOrders indicates an expected call of AddOrders.
+func (mr *MockGophermartServiceMockRecorder) AddOrders(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrders", reflect.TypeOf((*MockGophermartService)(nil).AddOrders), arg0, arg1, arg2)
+}
+
 // AddUser mocks base method.
-func (m *MockGophermartService) AddUser(arg0 context.Context, arg1, arg2 string) (string, error) {
+func (m *MockGophermartService) AddUser(arg0 context.Context, arg1, arg2 string, arg3 dto.ClientInfo) (dto.TokenPair, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddUser", arg0, arg1, arg2)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "AddUser", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(dto.TokenPair)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddUser indicates an expected call of AddUser.
-func (mr *MockGophermartServiceMockRecorder) AddUser(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockGophermartServiceMockRecorder) AddUser(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockGophermartService)(nil).AddUser), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockGophermartService)(nil).AddUser), arg0, arg1, arg2, arg3)
+}
+
+// ChangePassword mocks base method.
+func (m *MockGophermartService) ChangePassword(arg0 context.Context, arg1, arg2, arg3 string, arg4 dto.ClientInfo) (dto.TokenPair, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(dto.TokenPair)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockGophermartServiceMockRecorder) ChangePassword(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockGophermartService)(nil).ChangePassword), arg0, arg1, arg2, arg3, arg4)
 }
 
 // Close mocks base method.
@@ -76,6 +106,36 @@ func (mr *MockGophermartServiceMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockGophermartService)(nil).Close))
 }
 
+// CreateAPIKey mocks base method.
+func (m *MockGophermartService) CreateAPIKey(arg0 context.Context, arg1, arg2 string, arg3 []string) (dto.CreatedAPIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(dto.CreatedAPIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockGophermartServiceMockRecorder) CreateAPIKey(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockGophermartService)(nil).CreateAPIKey), arg0, arg1, arg2, arg3)
+}
+
+// CreateWebhook mocks base method.
+func (m *MockGophermartService) CreateWebhook(arg0 context.Context, arg1, arg2 string, arg3 []string) (dto.CreatedWebhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(dto.CreatedWebhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockGophermartServiceMockRecorder) CreateWebhook(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockGophermartService)(nil).CreateWebhook), arg0, arg1, arg2, arg3)
+}
+
 // CreateWithdraw mocks base method.
 func (m *MockGophermartService) CreateWithdraw(arg0 context.Context, arg1 string, arg2 dto.Withdraw) error {
 	m.ctrl.T.Helper()
@@ -90,6 +150,124 @@ func (mr *MockGophermartServiceMockRecorder) CreateWithdraw(arg0, arg1, arg2 int
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWithdraw", reflect.TypeOf((*MockGophermartService)(nil).CreateWithdraw), arg0, arg1, arg2)
 }
 
+// DeleteAccount mocks base method.
+func (m *MockGophermartService) DeleteAccount(arg0 context.Context, arg1, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAccount", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAccount indicates an expected call of DeleteAccount.
+func (mr *MockGophermartServiceMockRecorder) DeleteAccount(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockGophermartService)(nil).DeleteAccount), arg0, arg1, arg2, arg3)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockGophermartService) DeleteWebhook(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockGophermartServiceMockRecorder) DeleteWebhook(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockGophermartService)(nil).DeleteWebhook), arg0, arg1, arg2)
+}
+
+// EnrollTOTP mocks base method.
+func (m *MockGophermartService) EnrollTOTP(arg0 context.Context, arg1 string) (dto.TOTPEnrollment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnrollTOTP", arg0, arg1)
+	ret0, _ := ret[0].(dto.TOTPEnrollment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnrollTOTP indicates an expected call of EnrollTOTP.
+func (mr *MockGophermartServiceMockRecorder) EnrollTOTP(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnrollTOTP", reflect.TypeOf((*MockGophermartService)(nil).EnrollTOTP), arg0, arg1)
+}
+
+// ExportUserData mocks base method.
+func (m *MockGophermartService) ExportUserData(arg0 context.Context, arg1 string) (dto.UserDataExport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportUserData", arg0, arg1)
+	ret0, _ := ret[0].(dto.UserDataExport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExportUserData indicates an expected call of ExportUserData.
+func (mr *MockGophermartServiceMockRecorder) ExportUserData(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportUserData", reflect.TypeOf((*MockGophermartService)(nil).ExportUserData), arg0, arg1)
+}
+
+// FinishOIDCLogin mocks base method.
+func (m *MockGophermartService) FinishOIDCLogin(arg0 context.Context, arg1, arg2, arg3 string, arg4 dto.ClientInfo) (dto.TokenPair, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishOIDCLogin", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(dto.TokenPair)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FinishOIDCLogin indicates an expected call of FinishOIDCLogin.
+func (mr *MockGophermartServiceMockRecorder) FinishOIDCLogin(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishOIDCLogin", reflect.TypeOf((*MockGophermartService)(nil).FinishOIDCLogin), arg0, arg1, arg2, arg3, arg4)
+}
+
+// GetAPIKeys mocks base method.
+func (m *MockGophermartService) GetAPIKeys(arg0 context.Context, arg1 string) ([]dto.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", arg0, arg1)
+	ret0, _ := ret[0].([]dto.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys.
+func (mr *MockGophermartServiceMockRecorder) GetAPIKeys(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockGophermartService)(nil).GetAPIKeys), arg0, arg1)
+}
+
+// GetAccrualAdjustments mocks base method.
+func (m *MockGophermartService) GetAccrualAdjustments(arg0 context.Context, arg1 string) ([]dto.AccrualAdjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccrualAdjustments", arg0, arg1)
+	ret0, _ := ret[0].([]dto.AccrualAdjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccrualAdjustments indicates an expected call of GetAccrualAdjustments.
+func (mr *MockGophermartServiceMockRecorder) GetAccrualAdjustments(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccrualAdjustments", reflect.TypeOf((*MockGophermartService)(nil).GetAccrualAdjustments), arg0, arg1)
+}
+
+// GetAnyOrder mocks base method.
+func (m *MockGophermartService) GetAnyOrder(arg0 context.Context, arg1 string) (dto.OrderDetails, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAnyOrder", arg0, arg1)
+	ret0, _ := ret[0].(dto.OrderDetails)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAnyOrder indicates an expected call of GetAnyOrder.
+func (mr *MockGophermartServiceMockRecorder) GetAnyOrder(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAnyOrder", reflect.TypeOf((*MockGophermartService)(nil).GetAnyOrder), arg0, arg1)
+}
+
 // GetBalanceByUserID mocks base method.
 func (m *MockGophermartService) GetBalanceByUserID(arg0 context.Context, arg1 string) (dto.Balance, error) {
 	m.ctrl.T.Helper()
@@ -105,19 +283,108 @@ func (mr *MockGophermartServiceMockRecorder) GetBalanceByUserID(arg0, arg1 inter
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceByUserID", reflect.TypeOf((*MockGophermartService)(nil).GetBalanceByUserID), arg0, arg1)
 }
 
+// GetJWKS mocks base method.
+func (m *MockGophermartService) GetJWKS() dto.JWKS {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJWKS")
+	ret0, _ := ret[0].(dto.JWKS)
+	return ret0
+}
+
+// GetJWKS indicates an expected call of GetJWKS.
+func (mr *MockGophermartServiceMockRecorder) GetJWKS() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJWKS", reflect.TypeOf((*MockGophermartService)(nil).GetJWKS))
+}
+
+// GetLoginLockouts mocks base method.
+func (m *MockGophermartService) GetLoginLockouts(arg0 context.Context, arg1 int) ([]dto.LoginLockout, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginLockouts", arg0, arg1)
+	ret0, _ := ret[0].([]dto.LoginLockout)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginLockouts indicates an expected call of GetLoginLockouts.
+func (mr *MockGophermartServiceMockRecorder) GetLoginLockouts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginLockouts", reflect.TypeOf((*MockGophermartService)(nil).GetLoginLockouts), arg0, arg1)
+}
+
+// GetOrder mocks base method.
+func (m *MockGophermartService) GetOrder(arg0 context.Context, arg1, arg2 string) (dto.OrderDetails, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrder", arg0, arg1, arg2)
+	ret0, _ := ret[0].(dto.OrderDetails)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrder indicates an expected call of GetOrder.
+func (mr *MockGophermartServiceMockRecorder) GetOrder(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockGophermartService)(nil).GetOrder), arg0, arg1, arg2)
+}
+
 // GetOrdersByUser mocks base method.
-func (m *MockGophermartService) GetOrdersByUser(arg0 context.Context, arg1 string) ([]dto.Order, error) {
+func (m *MockGophermartService) GetOrdersByUser(arg0 context.Context, arg1 string, arg2 dto.OrderQuery) (dto.OrderPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrdersByUser", arg0, arg1)
-	ret0, _ := ret[0].([]dto.Order)
+	ret := m.ctrl.Call(m, "GetOrdersByUser", arg0, arg1, arg2)
+	ret0, _ := ret[0].(dto.OrderPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOrdersByUser indicates an expected call of GetOrdersByUser.
-func (mr *MockGophermartServiceMockRecorder) GetOrdersByUser(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockGophermartServiceMockRecorder) GetOrdersByUser(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByUser", reflect.TypeOf((*MockGophermartService)(nil).GetOrdersByUser), arg0, arg1, arg2)
+}
+
+// GetSessions mocks base method.
+func (m *MockGophermartService) GetSessions(arg0 context.Context, arg1, arg2 string) ([]dto.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessions", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]dto.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessions indicates an expected call of GetSessions.
+func (mr *MockGophermartServiceMockRecorder) GetSessions(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByUser", reflect.TypeOf((*MockGophermartService)(nil).GetOrdersByUser), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessions", reflect.TypeOf((*MockGophermartService)(nil).GetSessions), arg0, arg1, arg2)
+}
+
+// GetWebhookDeliveries mocks base method.
+func (m *MockGophermartService) GetWebhookDeliveries(arg0 context.Context, arg1, arg2 string) ([]dto.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveries", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]dto.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveries indicates an expected call of GetWebhookDeliveries.
+func (mr *MockGophermartServiceMockRecorder) GetWebhookDeliveries(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveries", reflect.TypeOf((*MockGophermartService)(nil).GetWebhookDeliveries), arg0, arg1, arg2)
+}
+
+// GetWebhooks mocks base method.
+func (m *MockGophermartService) GetWebhooks(arg0 context.Context, arg1 string) ([]dto.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhooks", arg0, arg1)
+	ret0, _ := ret[0].([]dto.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhooks indicates an expected call of GetWebhooks.
+func (mr *MockGophermartServiceMockRecorder) GetWebhooks(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhooks", reflect.TypeOf((*MockGophermartService)(nil).GetWebhooks), arg0, arg1)
 }
 
 // GetWithdrawalsByUserID mocks base method.
@@ -135,34 +402,194 @@ func (mr *MockGophermartServiceMockRecorder) GetWithdrawalsByUserID(arg0, arg1 i
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawalsByUserID", reflect.TypeOf((*MockGophermartService)(nil).GetWithdrawalsByUserID), arg0, arg1)
 }
 
+// LoginTwoFactor mocks base method.
+func (m *MockGophermartService) LoginTwoFactor(arg0 context.Context, arg1, arg2 string, arg3 dto.ClientInfo) (dto.TokenPair, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoginTwoFactor", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(dto.TokenPair)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoginTwoFactor indicates an expected call of LoginTwoFactor.
+func (mr *MockGophermartServiceMockRecorder) LoginTwoFactor(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginTwoFactor", reflect.TypeOf((*MockGophermartService)(nil).LoginTwoFactor), arg0, arg1, arg2, arg3)
+}
+
 // LoginUser mocks base method.
-func (m *MockGophermartService) LoginUser(arg0 context.Context, arg1, arg2 string) (string, error) {
+func (m *MockGophermartService) LoginUser(arg0 context.Context, arg1, arg2 string, arg3 dto.ClientInfo) (dto.TokenPair, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "LoginUser", arg0, arg1, arg2)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "LoginUser", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(dto.TokenPair)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // LoginUser indicates an expected call of LoginUser.
-func (mr *MockGophermartServiceMockRecorder) LoginUser(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockGophermartServiceMockRecorder) LoginUser(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginUser", reflect.TypeOf((*MockGophermartService)(nil).LoginUser), arg0, arg1, arg2, arg3)
+}
+
+// Logout mocks base method.
+func (m *MockGophermartService) Logout(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logout", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Logout indicates an expected call of Logout.
+func (mr *MockGophermartServiceMockRecorder) Logout(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockGophermartService)(nil).Logout), arg0, arg1, arg2)
+}
+
+// ParseAPIKey mocks base method.
+func (m *MockGophermartService) ParseAPIKey(arg0 context.Context, arg1 string) (dto.Principal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ParseAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(dto.Principal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ParseAPIKey indicates an expected call of ParseAPIKey.
+func (mr *MockGophermartServiceMockRecorder) ParseAPIKey(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginUser", reflect.TypeOf((*MockGophermartService)(nil).LoginUser), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseAPIKey", reflect.TypeOf((*MockGophermartService)(nil).ParseAPIKey), arg0, arg1)
 }
 
 // ParseJWTToken mocks base method.
-func (m *MockGophermartService) ParseJWTToken(arg0 string) (string, error) {
+func (m *MockGophermartService) ParseJWTToken(arg0 context.Context, arg1 string) (dto.Principal, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ParseJWTToken", arg0)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "ParseJWTToken", arg0, arg1)
+	ret0, _ := ret[0].(dto.Principal)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ParseJWTToken indicates an expected call of ParseJWTToken.
-func (mr *MockGophermartServiceMockRecorder) ParseJWTToken(arg0 interface{}) *gomock.Call {
+func (mr *MockGophermartServiceMockRecorder) ParseJWTToken(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseJWTToken", reflect.TypeOf((*MockGophermartService)(nil).ParseJWTToken), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseJWTToken", reflect.TypeOf((*MockGophermartService)(nil).ParseJWTToken), arg0, arg1)
+}
+
+// RecheckAnyOrder mocks base method.
+func (m *MockGophermartService) RecheckAnyOrder(arg0 context.Context, arg1, arg2 string) (dto.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecheckAnyOrder", arg0, arg1, arg2)
+	ret0, _ := ret[0].(dto.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecheckAnyOrder indicates an expected call of RecheckAnyOrder.
+func (mr *MockGophermartServiceMockRecorder) RecheckAnyOrder(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecheckAnyOrder", reflect.TypeOf((*MockGophermartService)(nil).RecheckAnyOrder), arg0, arg1, arg2)
+}
+
+// RecheckOrder mocks base method.
+func (m *MockGophermartService) RecheckOrder(arg0 context.Context, arg1, arg2 string) (dto.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecheckOrder", arg0, arg1, arg2)
+	ret0, _ := ret[0].(dto.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecheckOrder indicates an expected call of RecheckOrder.
+func (mr *MockGophermartServiceMockRecorder) RecheckOrder(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecheckOrder", reflect.TypeOf((*MockGophermartService)(nil).RecheckOrder), arg0, arg1, arg2)
+}
+
+// RefreshTokens mocks base method.
+func (m *MockGophermartService) RefreshTokens(arg0 context.Context, arg1 string) (dto.TokenPair, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshTokens", arg0, arg1)
+	ret0, _ := ret[0].(dto.TokenPair)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshTokens indicates an expected call of RefreshTokens.
+func (mr *MockGophermartServiceMockRecorder) RefreshTokens(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshTokens", reflect.TypeOf((*MockGophermartService)(nil).RefreshTokens), arg0, arg1)
+}
+
+// RequestPasswordReset mocks base method.
+func (m *MockGophermartService) RequestPasswordReset(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestPasswordReset", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequestPasswordReset indicates an expected call of RequestPasswordReset.
+func (mr *MockGophermartServiceMockRecorder) RequestPasswordReset(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestPasswordReset", reflect.TypeOf((*MockGophermartService)(nil).RequestPasswordReset), arg0, arg1)
+}
+
+// ResetPassword mocks base method.
+func (m *MockGophermartService) ResetPassword(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockGophermartServiceMockRecorder) ResetPassword(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockGophermartService)(nil).ResetPassword), arg0, arg1, arg2)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockGophermartService) RevokeAPIKey(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockGophermartServiceMockRecorder) RevokeAPIKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockGophermartService)(nil).RevokeAPIKey), arg0, arg1, arg2)
+}
+
+// RevokeSession mocks base method.
+func (m *MockGophermartService) RevokeSession(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockGophermartServiceMockRecorder) RevokeSession(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockGophermartService)(nil).RevokeSession), arg0, arg1, arg2)
+}
+
+// SetUserRoles mocks base method.
+func (m *MockGophermartService) SetUserRoles(arg0 context.Context, arg1 string, arg2 []string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserRoles", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetUserRoles indicates an expected call of SetUserRoles.
+func (mr *MockGophermartServiceMockRecorder) SetUserRoles(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserRoles", reflect.TypeOf((*MockGophermartService)(nil).SetUserRoles), arg0, arg1, arg2)
 }
 
 // StartAccrualInfoSynchronizer mocks base method.
@@ -178,3 +605,62 @@ func (mr *MockGophermartServiceMockRecorder) StartAccrualInfoSynchronizer(arg0,
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartAccrualInfoSynchronizer", reflect.TypeOf((*MockGophermartService)(nil).StartAccrualInfoSynchronizer), arg0, arg1)
 }
+
+// StartOIDCLogin mocks base method.
+func (m *MockGophermartService) StartOIDCLogin(arg0 context.Context) (dto.OIDCAuthorization, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartOIDCLogin", arg0)
+	ret0, _ := ret[0].(dto.OIDCAuthorization)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StartOIDCLogin indicates an expected call of StartOIDCLogin.
+func (mr *MockGophermartServiceMockRecorder) StartOIDCLogin(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartOIDCLogin", reflect.TypeOf((*MockGophermartService)(nil).StartOIDCLogin), arg0)
+}
+
+// SubscribeOrderEvents mocks base method.
+func (m *MockGophermartService) SubscribeOrderEvents(arg0 context.Context, arg1 string, arg2 int64) (<-chan dto.OrderEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeOrderEvents", arg0, arg1, arg2)
+	ret0, _ := ret[0].(<-chan dto.OrderEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeOrderEvents indicates an expected call of SubscribeOrderEvents.
+func (mr *MockGophermartServiceMockRecorder) SubscribeOrderEvents(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeOrderEvents", reflect.TypeOf((*MockGophermartService)(nil).SubscribeOrderEvents), arg0, arg1, arg2)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockGophermartService) VerifyEmail(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockGophermartServiceMockRecorder) VerifyEmail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockGophermartService)(nil).VerifyEmail), arg0, arg1)
+}
+
+// VerifyTOTP mocks base method.
+func (m *MockGophermartService) VerifyTOTP(arg0 context.Context, arg1, arg2 string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyTOTP", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyTOTP indicates an expected call of VerifyTOTP.
+func (mr *MockGophermartServiceMockRecorder) VerifyTOTP(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyTOTP", reflect.TypeOf((*MockGophermartService)(nil).VerifyTOTP), arg0, arg1, arg2)
+}
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/apolsh/yapr-gophermart/internal/gophermart/service (interfaces: UserStorage,OrderStorage)
+// Source: github.com/apolsh/yapr-gophermart/internal/gophermart/service (interfaces: UserStorage,OrderStorage,RefreshTokenStorage,RevokedTokenStorage,LoginAttemptStorage,RequestLimitStorage,SessionStorage,APIKeyStorage,PasswordResetStorage,WebhookStorage,AccrualJobStorage)
 
 // Package mocks is a generated GoMock package.
 package mocks
@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	dto "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
 	gomock "github.com/golang/mock/gomock"
@@ -36,6 +37,34 @@ func (m *MockUserStorage) EXPECT() *MockUserStorageMockRecorder {
 	return m.recorder
 }
 
+// DeleteUser mocks base method.
+func (m *MockUserStorage) DeleteUser(arg0 context.Context, arg1, arg2 string, arg3 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockUserStorageMockRecorder) DeleteUser(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserStorage)(nil).DeleteUser), arg0, arg1, arg2, arg3)
+}
+
+// EnableTOTP mocks base method.
+func (m *MockUserStorage) EnableTOTP(arg0 context.Context, arg1 string, arg2 int64, arg3 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableTOTP", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnableTOTP indicates an expected call of EnableTOTP.
+func (mr *MockUserStorageMockRecorder) EnableTOTP(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableTOTP", reflect.TypeOf((*MockUserStorage)(nil).EnableTOTP), arg0, arg1, arg2, arg3)
+}
+
 // Get mocks base method.
 func (m *MockUserStorage) Get(arg0 context.Context, arg1 string) (dto.User, error) {
 	m.ctrl.T.Helper()
@@ -51,6 +80,94 @@ func (mr *MockUserStorageMockRecorder) Get(arg0, arg1 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockUserStorage)(nil).Get), arg0, arg1)
 }
 
+// GetByID mocks base method.
+func (m *MockUserStorage) GetByID(arg0 context.Context, arg1 string) (dto.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByID", arg0, arg1)
+	ret0, _ := ret[0].(dto.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByID indicates an expected call of GetByID.
+func (mr *MockUserStorageMockRecorder) GetByID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockUserStorage)(nil).GetByID), arg0, arg1)
+}
+
+// GetByIdentity mocks base method.
+func (m *MockUserStorage) GetByIdentity(arg0 context.Context, arg1, arg2 string) (dto.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByIdentity", arg0, arg1, arg2)
+	ret0, _ := ret[0].(dto.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByIdentity indicates an expected call of GetByIdentity.
+func (mr *MockUserStorageMockRecorder) GetByIdentity(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByIdentity", reflect.TypeOf((*MockUserStorage)(nil).GetByIdentity), arg0, arg1, arg2)
+}
+
+// GetLogins mocks base method.
+func (m *MockUserStorage) GetLogins(arg0 context.Context) ([]dto.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLogins", arg0)
+	ret0, _ := ret[0].([]dto.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLogins indicates an expected call of GetLogins.
+func (mr *MockUserStorageMockRecorder) GetLogins(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLogins", reflect.TypeOf((*MockUserStorage)(nil).GetLogins), arg0)
+}
+
+// GetLoginsToNormalize mocks base method.
+func (m *MockUserStorage) GetLoginsToNormalize(arg0 context.Context) ([]dto.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginsToNormalize", arg0)
+	ret0, _ := ret[0].([]dto.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginsToNormalize indicates an expected call of GetLoginsToNormalize.
+func (mr *MockUserStorageMockRecorder) GetLoginsToNormalize(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginsToNormalize", reflect.TypeOf((*MockUserStorage)(nil).GetLoginsToNormalize), arg0)
+}
+
+// MarkEmailVerified mocks base method.
+func (m *MockUserStorage) MarkEmailVerified(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkEmailVerified", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkEmailVerified indicates an expected call of MarkEmailVerified.
+func (mr *MockUserStorageMockRecorder) MarkEmailVerified(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkEmailVerified", reflect.TypeOf((*MockUserStorage)(nil).MarkEmailVerified), arg0, arg1)
+}
+
+// MarkTOTPStepUsed mocks base method.
+func (m *MockUserStorage) MarkTOTPStepUsed(arg0 context.Context, arg1 string, arg2 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkTOTPStepUsed", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkTOTPStepUsed indicates an expected call of MarkTOTPStepUsed.
+func (mr *MockUserStorageMockRecorder) MarkTOTPStepUsed(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkTOTPStepUsed", reflect.TypeOf((*MockUserStorage)(nil).MarkTOTPStepUsed), arg0, arg1, arg2)
+}
+
 // NewUser mocks base method.
 func (m *MockUserStorage) NewUser(arg0 context.Context, arg1, arg2 string) (string, error) {
 	m.ctrl.T.Helper()
@@ -66,6 +183,134 @@ func (mr *MockUserStorageMockRecorder) NewUser(arg0, arg1, arg2 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewUser", reflect.TypeOf((*MockUserStorage)(nil).NewUser), arg0, arg1, arg2)
 }
 
+// NewUserWithIdentity mocks base method.
+func (m *MockUserStorage) NewUserWithIdentity(arg0 context.Context, arg1 string, arg2 dto.UserIdentity) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewUserWithIdentity", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NewUserWithIdentity indicates an expected call of NewUserWithIdentity.
+func (mr *MockUserStorageMockRecorder) NewUserWithIdentity(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewUserWithIdentity", reflect.TypeOf((*MockUserStorage)(nil).NewUserWithIdentity), arg0, arg1, arg2)
+}
+
+// PurgeDeletedUsers mocks base method.
+func (m *MockUserStorage) PurgeDeletedUsers(arg0 context.Context, arg1 time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletedUsers", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeletedUsers indicates an expected call of PurgeDeletedUsers.
+func (mr *MockUserStorageMockRecorder) PurgeDeletedUsers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletedUsers", reflect.TypeOf((*MockUserStorage)(nil).PurgeDeletedUsers), arg0, arg1)
+}
+
+// SaveLoginCollision mocks base method.
+func (m *MockUserStorage) SaveLoginCollision(arg0 context.Context, arg1 dto.LoginCollision) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveLoginCollision", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveLoginCollision indicates an expected call of SaveLoginCollision.
+func (mr *MockUserStorageMockRecorder) SaveLoginCollision(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveLoginCollision", reflect.TypeOf((*MockUserStorage)(nil).SaveLoginCollision), arg0, arg1)
+}
+
+// SetTOTPSecret mocks base method.
+func (m *MockUserStorage) SetTOTPSecret(arg0 context.Context, arg1 string, arg2 []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTOTPSecret", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTOTPSecret indicates an expected call of SetTOTPSecret.
+func (mr *MockUserStorageMockRecorder) SetTOTPSecret(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTOTPSecret", reflect.TypeOf((*MockUserStorage)(nil).SetTOTPSecret), arg0, arg1, arg2)
+}
+
+// UpdateLogin mocks base method.
+func (m *MockUserStorage) UpdateLogin(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateLogin", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateLogin indicates an expected call of UpdateLogin.
+func (mr *MockUserStorageMockRecorder) UpdateLogin(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLogin", reflect.TypeOf((*MockUserStorage)(nil).UpdateLogin), arg0, arg1, arg2)
+}
+
+// UpdatePassword mocks base method.
+func (m *MockUserStorage) UpdatePassword(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassword", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockUserStorageMockRecorder) UpdatePassword(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockUserStorage)(nil).UpdatePassword), arg0, arg1, arg2)
+}
+
+// UpdatePasswordHash mocks base method.
+func (m *MockUserStorage) UpdatePasswordHash(arg0 context.Context, arg1, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePasswordHash", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePasswordHash indicates an expected call of UpdatePasswordHash.
+func (mr *MockUserStorageMockRecorder) UpdatePasswordHash(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePasswordHash", reflect.TypeOf((*MockUserStorage)(nil).UpdatePasswordHash), arg0, arg1, arg2, arg3)
+}
+
+// UpdateRoles mocks base method.
+func (m *MockUserStorage) UpdateRoles(arg0 context.Context, arg1 string, arg2 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRoles", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRoles indicates an expected call of UpdateRoles.
+func (mr *MockUserStorageMockRecorder) UpdateRoles(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRoles", reflect.TypeOf((*MockUserStorage)(nil).UpdateRoles), arg0, arg1, arg2)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockUserStorage) UseRecoveryCode(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockUserStorageMockRecorder) UseRecoveryCode(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockUserStorage)(nil).UseRecoveryCode), arg0, arg1, arg2)
+}
+
 // MockOrderStorage is a mock of OrderStorage interface.
 type MockOrderStorage struct {
 	ctrl     *gomock.Controller
@@ -103,19 +348,19 @@ func (mr *MockOrderStorageMockRecorder) CreateWithdraw(arg0, arg1, arg2 interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWithdraw", reflect.TypeOf((*MockOrderStorage)(nil).CreateWithdraw), arg0, arg1, arg2)
 }
 
-// GetAllUnfinishedAccrualOrderNums mocks base method.
-func (m *MockOrderStorage) GetAllUnfinishedAccrualOrderNums(arg0 context.Context) ([]string, error) {
+// GetAccrualAdjustments mocks base method.
+func (m *MockOrderStorage) GetAccrualAdjustments(arg0 context.Context, arg1 string) ([]dto.AccrualAdjustment, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllUnfinishedAccrualOrderNums", arg0)
-	ret0, _ := ret[0].([]string)
+	ret := m.ctrl.Call(m, "GetAccrualAdjustments", arg0, arg1)
+	ret0, _ := ret[0].([]dto.AccrualAdjustment)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAllUnfinishedAccrualOrderNums indicates an expected call of GetAllUnfinishedAccrualOrderNums.
-func (mr *MockOrderStorageMockRecorder) GetAllUnfinishedAccrualOrderNums(arg0 interface{}) *gomock.Call {
+// GetAccrualAdjustments indicates an expected call of GetAccrualAdjustments.
+func (mr *MockOrderStorageMockRecorder) GetAccrualAdjustments(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllUnfinishedAccrualOrderNums", reflect.TypeOf((*MockOrderStorage)(nil).GetAllUnfinishedAccrualOrderNums), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccrualAdjustments", reflect.TypeOf((*MockOrderStorage)(nil).GetAccrualAdjustments), arg0, arg1)
 }
 
 // GetBalanceByUserID mocks base method.
@@ -133,19 +378,94 @@ func (mr *MockOrderStorageMockRecorder) GetBalanceByUserID(arg0, arg1 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceByUserID", reflect.TypeOf((*MockOrderStorage)(nil).GetBalanceByUserID), arg0, arg1)
 }
 
+// GetOrder mocks base method.
+func (m *MockOrderStorage) GetOrder(arg0 context.Context, arg1 string) (dto.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrder", arg0, arg1)
+	ret0, _ := ret[0].(dto.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrder indicates an expected call of GetOrder.
+func (mr *MockOrderStorageMockRecorder) GetOrder(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockOrderStorage)(nil).GetOrder), arg0, arg1)
+}
+
+// GetOrderEvents mocks base method.
+func (m *MockOrderStorage) GetOrderEvents(arg0 context.Context, arg1 string, arg2 int64, arg3 int) ([]dto.OrderEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderEvents", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]dto.OrderEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderEvents indicates an expected call of GetOrderEvents.
+func (mr *MockOrderStorageMockRecorder) GetOrderEvents(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderEvents", reflect.TypeOf((*MockOrderStorage)(nil).GetOrderEvents), arg0, arg1, arg2, arg3)
+}
+
+// GetOrderEventsReplayStart mocks base method.
+func (m *MockOrderStorage) GetOrderEventsReplayStart(arg0 context.Context, arg1 string, arg2 int64, arg3 time.Duration) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderEventsReplayStart", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderEventsReplayStart indicates an expected call of GetOrderEventsReplayStart.
+func (mr *MockOrderStorageMockRecorder) GetOrderEventsReplayStart(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderEventsReplayStart", reflect.TypeOf((*MockOrderStorage)(nil).GetOrderEventsReplayStart), arg0, arg1, arg2, arg3)
+}
+
+// GetOrderNumsToReconcile mocks base method.
+func (m *MockOrderStorage) GetOrderNumsToReconcile(arg0 context.Context, arg1 time.Time) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderNumsToReconcile", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderNumsToReconcile indicates an expected call of GetOrderNumsToReconcile.
+func (mr *MockOrderStorageMockRecorder) GetOrderNumsToReconcile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderNumsToReconcile", reflect.TypeOf((*MockOrderStorage)(nil).GetOrderNumsToReconcile), arg0, arg1)
+}
+
+// GetOrderStatusHistory mocks base method.
+func (m *MockOrderStorage) GetOrderStatusHistory(arg0 context.Context, arg1 string) ([]dto.OrderStatusChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderStatusHistory", arg0, arg1)
+	ret0, _ := ret[0].([]dto.OrderStatusChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderStatusHistory indicates an expected call of GetOrderStatusHistory.
+func (mr *MockOrderStorageMockRecorder) GetOrderStatusHistory(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderStatusHistory", reflect.TypeOf((*MockOrderStorage)(nil).GetOrderStatusHistory), arg0, arg1)
+}
+
 // GetOrdersByID mocks base method.
-func (m *MockOrderStorage) GetOrdersByID(arg0 context.Context, arg1 string) ([]dto.Order, error) {
+func (m *MockOrderStorage) GetOrdersByID(arg0 context.Context, arg1 string, arg2 dto.OrderFilter) ([]dto.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrdersByID", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetOrdersByID", arg0, arg1, arg2)
 	ret0, _ := ret[0].([]dto.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOrdersByID indicates an expected call of GetOrdersByID.
-func (mr *MockOrderStorageMockRecorder) GetOrdersByID(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockOrderStorageMockRecorder) GetOrdersByID(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByID", reflect.TypeOf((*MockOrderStorage)(nil).GetOrdersByID), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByID", reflect.TypeOf((*MockOrderStorage)(nil).GetOrdersByID), arg0, arg1, arg2)
 }
 
 // GetWithdrawalsByUserID mocks base method.
@@ -163,6 +483,20 @@ func (mr *MockOrderStorageMockRecorder) GetWithdrawalsByUserID(arg0, arg1 interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawalsByUserID", reflect.TypeOf((*MockOrderStorage)(nil).GetWithdrawalsByUserID), arg0, arg1)
 }
 
+// ListenOrderEvents mocks base method.
+func (m *MockOrderStorage) ListenOrderEvents(arg0 context.Context, arg1 func(), arg2 func(dto.OrderEvent)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListenOrderEvents", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListenOrderEvents indicates an expected call of ListenOrderEvents.
+func (mr *MockOrderStorageMockRecorder) ListenOrderEvents(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListenOrderEvents", reflect.TypeOf((*MockOrderStorage)(nil).ListenOrderEvents), arg0, arg1, arg2)
+}
+
 // SaveNewOrder mocks base method.
 func (m *MockOrderStorage) SaveNewOrder(arg0 context.Context, arg1, arg2 string) error {
 	m.ctrl.T.Helper()
@@ -177,16 +511,847 @@ func (mr *MockOrderStorageMockRecorder) SaveNewOrder(arg0, arg1, arg2 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveNewOrder", reflect.TypeOf((*MockOrderStorage)(nil).SaveNewOrder), arg0, arg1, arg2)
 }
 
+// SaveNewOrders mocks base method.
+func (m *MockOrderStorage) SaveNewOrders(arg0 context.Context, arg1 []string, arg2 string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveNewOrders", arg0, arg1, arg2)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveNewOrders indicates an expected call of SaveNewOrders.
+func (mr *MockOrderStorageMockRecorder) SaveNewOrders(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveNewOrders", reflect.TypeOf((*MockOrderStorage)(nil).SaveNewOrders), arg0, arg1, arg2)
+}
+
+// SaveOrderCheck mocks base method.
+func (m *MockOrderStorage) SaveOrderCheck(arg0 context.Context, arg1 string, arg2 int, arg3 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveOrderCheck", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveOrderCheck indicates an expected call of SaveOrderCheck.
+func (mr *MockOrderStorageMockRecorder) SaveOrderCheck(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrderCheck", reflect.TypeOf((*MockOrderStorage)(nil).SaveOrderCheck), arg0, arg1, arg2, arg3)
+}
+
+// SaveOrderRecheck mocks base method.
+func (m *MockOrderStorage) SaveOrderRecheck(arg0 context.Context, arg1 dto.OrderRecheck) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveOrderRecheck", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveOrderRecheck indicates an expected call of SaveOrderRecheck.
+func (mr *MockOrderStorageMockRecorder) SaveOrderRecheck(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrderRecheck", reflect.TypeOf((*MockOrderStorage)(nil).SaveOrderRecheck), arg0, arg1)
+}
+
+// TryLockAccrualReconciliation mocks base method.
+func (m *MockOrderStorage) TryLockAccrualReconciliation(arg0 context.Context) (func(), bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TryLockAccrualReconciliation", arg0)
+	ret0, _ := ret[0].(func())
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// TryLockAccrualReconciliation indicates an expected call of TryLockAccrualReconciliation.
+func (mr *MockOrderStorageMockRecorder) TryLockAccrualReconciliation(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TryLockAccrualReconciliation", reflect.TypeOf((*MockOrderStorage)(nil).TryLockAccrualReconciliation), arg0)
+}
+
 // UpdateOrder mocks base method.
-func (m *MockOrderStorage) UpdateOrder(arg0 context.Context, arg1, arg2 string, arg3 decimal.Decimal) error {
+func (m *MockOrderStorage) UpdateOrder(arg0 context.Context, arg1, arg2 string, arg3 decimal.Decimal, arg4 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateOrder", arg0, arg1, arg2, arg3)
+	ret := m.ctrl.Call(m, "UpdateOrder", arg0, arg1, arg2, arg3, arg4)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateOrder indicates an expected call of UpdateOrder.
-func (mr *MockOrderStorageMockRecorder) UpdateOrder(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+func (mr *MockOrderStorageMockRecorder) UpdateOrder(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrder", reflect.TypeOf((*MockOrderStorage)(nil).UpdateOrder), arg0, arg1, arg2, arg3, arg4)
+}
+
+// MockRefreshTokenStorage is a mock of RefreshTokenStorage interface.
+type MockRefreshTokenStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockRefreshTokenStorageMockRecorder
+}
+
+// MockRefreshTokenStorageMockRecorder is the mock recorder for MockRefreshTokenStorage.
+type MockRefreshTokenStorageMockRecorder struct {
+	mock *MockRefreshTokenStorage
+}
+
+// NewMockRefreshTokenStorage creates a new mock instance.
+func NewMockRefreshTokenStorage(ctrl *gomock.Controller) *MockRefreshTokenStorage {
+	mock := &MockRefreshTokenStorage{ctrl: ctrl}
+	mock.recorder = &MockRefreshTokenStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRefreshTokenStorage) EXPECT() *MockRefreshTokenStorageMockRecorder {
+	return m.recorder
+}
+
+// GetRefreshToken mocks base method.
+func (m *MockRefreshTokenStorage) GetRefreshToken(arg0 context.Context, arg1 string) (dto.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefreshToken", arg0, arg1)
+	ret0, _ := ret[0].(dto.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefreshToken indicates an expected call of GetRefreshToken.
+func (mr *MockRefreshTokenStorageMockRecorder) GetRefreshToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefreshToken", reflect.TypeOf((*MockRefreshTokenStorage)(nil).GetRefreshToken), arg0, arg1)
+}
+
+// MarkRefreshTokenUsed mocks base method.
+func (m *MockRefreshTokenStorage) MarkRefreshTokenUsed(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkRefreshTokenUsed", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkRefreshTokenUsed indicates an expected call of MarkRefreshTokenUsed.
+func (mr *MockRefreshTokenStorageMockRecorder) MarkRefreshTokenUsed(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkRefreshTokenUsed", reflect.TypeOf((*MockRefreshTokenStorage)(nil).MarkRefreshTokenUsed), arg0, arg1)
+}
+
+// RevokeRefreshTokenFamily mocks base method.
+func (m *MockRefreshTokenStorage) RevokeRefreshTokenFamily(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRefreshTokenFamily", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRefreshTokenFamily indicates an expected call of RevokeRefreshTokenFamily.
+func (mr *MockRefreshTokenStorageMockRecorder) RevokeRefreshTokenFamily(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRefreshTokenFamily", reflect.TypeOf((*MockRefreshTokenStorage)(nil).RevokeRefreshTokenFamily), arg0, arg1)
+}
+
+// RevokeUserRefreshTokens mocks base method.
+func (m *MockRefreshTokenStorage) RevokeUserRefreshTokens(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserRefreshTokens", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserRefreshTokens indicates an expected call of RevokeUserRefreshTokens.
+func (mr *MockRefreshTokenStorageMockRecorder) RevokeUserRefreshTokens(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserRefreshTokens", reflect.TypeOf((*MockRefreshTokenStorage)(nil).RevokeUserRefreshTokens), arg0, arg1)
+}
+
+// SaveRefreshToken mocks base method.
+func (m *MockRefreshTokenStorage) SaveRefreshToken(arg0 context.Context, arg1 dto.RefreshToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveRefreshToken", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveRefreshToken indicates an expected call of SaveRefreshToken.
+func (mr *MockRefreshTokenStorageMockRecorder) SaveRefreshToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveRefreshToken", reflect.TypeOf((*MockRefreshTokenStorage)(nil).SaveRefreshToken), arg0, arg1)
+}
+
+// MockRevokedTokenStorage is a mock of RevokedTokenStorage interface.
+type MockRevokedTokenStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockRevokedTokenStorageMockRecorder
+}
+
+// MockRevokedTokenStorageMockRecorder is the mock recorder for MockRevokedTokenStorage.
+type MockRevokedTokenStorageMockRecorder struct {
+	mock *MockRevokedTokenStorage
+}
+
+// NewMockRevokedTokenStorage creates a new mock instance.
+func NewMockRevokedTokenStorage(ctrl *gomock.Controller) *MockRevokedTokenStorage {
+	mock := &MockRevokedTokenStorage{ctrl: ctrl}
+	mock.recorder = &MockRevokedTokenStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRevokedTokenStorage) EXPECT() *MockRevokedTokenStorageMockRecorder {
+	return m.recorder
+}
+
+// GetUserTokensRevokedBefore mocks base method.
+func (m *MockRevokedTokenStorage) GetUserTokensRevokedBefore(arg0 context.Context, arg1 string) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserTokensRevokedBefore", arg0, arg1)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserTokensRevokedBefore indicates an expected call of GetUserTokensRevokedBefore.
+func (mr *MockRevokedTokenStorageMockRecorder) GetUserTokensRevokedBefore(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserTokensRevokedBefore", reflect.TypeOf((*MockRevokedTokenStorage)(nil).GetUserTokensRevokedBefore), arg0, arg1)
+}
+
+// IsTokenRevoked mocks base method.
+func (m *MockRevokedTokenStorage) IsTokenRevoked(arg0 context.Context, arg1 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsTokenRevoked", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsTokenRevoked indicates an expected call of IsTokenRevoked.
+func (mr *MockRevokedTokenStorageMockRecorder) IsTokenRevoked(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsTokenRevoked", reflect.TypeOf((*MockRevokedTokenStorage)(nil).IsTokenRevoked), arg0, arg1)
+}
+
+// RevokeToken mocks base method.
+func (m *MockRevokedTokenStorage) RevokeToken(arg0 context.Context, arg1 string, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeToken", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeToken indicates an expected call of RevokeToken.
+func (mr *MockRevokedTokenStorageMockRecorder) RevokeToken(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeToken", reflect.TypeOf((*MockRevokedTokenStorage)(nil).RevokeToken), arg0, arg1, arg2)
+}
+
+// RevokeUserTokens mocks base method.
+func (m *MockRevokedTokenStorage) RevokeUserTokens(arg0 context.Context, arg1 string, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserTokens", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserTokens indicates an expected call of RevokeUserTokens.
+func (mr *MockRevokedTokenStorageMockRecorder) RevokeUserTokens(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserTokens", reflect.TypeOf((*MockRevokedTokenStorage)(nil).RevokeUserTokens), arg0, arg1, arg2)
+}
+
+// MockLoginAttemptStorage is a mock of LoginAttemptStorage interface.
+type MockLoginAttemptStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginAttemptStorageMockRecorder
+}
+
+// MockLoginAttemptStorageMockRecorder is the mock recorder for MockLoginAttemptStorage.
+type MockLoginAttemptStorageMockRecorder struct {
+	mock *MockLoginAttemptStorage
+}
+
+// NewMockLoginAttemptStorage creates a new mock instance.
+func NewMockLoginAttemptStorage(ctrl *gomock.Controller) *MockLoginAttemptStorage {
+	mock := &MockLoginAttemptStorage{ctrl: ctrl}
+	mock.recorder = &MockLoginAttemptStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginAttemptStorage) EXPECT() *MockLoginAttemptStorageMockRecorder {
+	return m.recorder
+}
+
+// GetLockedUntil mocks base method.
+func (m *MockLoginAttemptStorage) GetLockedUntil(arg0 context.Context, arg1, arg2 string) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLockedUntil", arg0, arg1, arg2)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLockedUntil indicates an expected call of GetLockedUntil.
+func (mr *MockLoginAttemptStorageMockRecorder) GetLockedUntil(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLockedUntil", reflect.TypeOf((*MockLoginAttemptStorage)(nil).GetLockedUntil), arg0, arg1, arg2)
+}
+
+// GetLoginLockouts mocks base method.
+func (m *MockLoginAttemptStorage) GetLoginLockouts(arg0 context.Context, arg1 int) ([]dto.LoginLockout, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginLockouts", arg0, arg1)
+	ret0, _ := ret[0].([]dto.LoginLockout)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginLockouts indicates an expected call of GetLoginLockouts.
+func (mr *MockLoginAttemptStorageMockRecorder) GetLoginLockouts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginLockouts", reflect.TypeOf((*MockLoginAttemptStorage)(nil).GetLoginLockouts), arg0, arg1)
+}
+
+// LockLogin mocks base method.
+func (m *MockLoginAttemptStorage) LockLogin(arg0 context.Context, arg1 dto.LoginLockout) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockLogin", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockLogin indicates an expected call of LockLogin.
+func (mr *MockLoginAttemptStorageMockRecorder) LockLogin(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockLogin", reflect.TypeOf((*MockLoginAttemptStorage)(nil).LockLogin), arg0, arg1)
+}
+
+// RegisterFailedAttempt mocks base method.
+func (m *MockLoginAttemptStorage) RegisterFailedAttempt(arg0 context.Context, arg1, arg2 string, arg3, arg4 time.Time) (dto.LoginAttempts, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterFailedAttempt", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(dto.LoginAttempts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegisterFailedAttempt indicates an expected call of RegisterFailedAttempt.
+func (mr *MockLoginAttemptStorageMockRecorder) RegisterFailedAttempt(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterFailedAttempt", reflect.TypeOf((*MockLoginAttemptStorage)(nil).RegisterFailedAttempt), arg0, arg1, arg2, arg3, arg4)
+}
+
+// ResetFailedAttempts mocks base method.
+func (m *MockLoginAttemptStorage) ResetFailedAttempts(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetFailedAttempts", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetFailedAttempts indicates an expected call of ResetFailedAttempts.
+func (mr *MockLoginAttemptStorageMockRecorder) ResetFailedAttempts(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetFailedAttempts", reflect.TypeOf((*MockLoginAttemptStorage)(nil).ResetFailedAttempts), arg0, arg1, arg2)
+}
+
+// MockRequestLimitStorage is a mock of RequestLimitStorage interface.
+type MockRequestLimitStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockRequestLimitStorageMockRecorder
+}
+
+// MockRequestLimitStorageMockRecorder is the mock recorder for MockRequestLimitStorage.
+type MockRequestLimitStorageMockRecorder struct {
+	mock *MockRequestLimitStorage
+}
+
+// NewMockRequestLimitStorage creates a new mock instance.
+func NewMockRequestLimitStorage(ctrl *gomock.Controller) *MockRequestLimitStorage {
+	mock := &MockRequestLimitStorage{ctrl: ctrl}
+	mock.recorder = &MockRequestLimitStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRequestLimitStorage) EXPECT() *MockRequestLimitStorageMockRecorder {
+	return m.recorder
+}
+
+// RegisterRequest mocks base method.
+func (m *MockRequestLimitStorage) RegisterRequest(arg0 context.Context, arg1, arg2 string, arg3, arg4 time.Time) (dto.RequestCount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterRequest", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(dto.RequestCount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegisterRequest indicates an expected call of RegisterRequest.
+func (mr *MockRequestLimitStorageMockRecorder) RegisterRequest(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterRequest", reflect.TypeOf((*MockRequestLimitStorage)(nil).RegisterRequest), arg0, arg1, arg2, arg3, arg4)
+}
+
+// MockSessionStorage is a mock of SessionStorage interface.
+type MockSessionStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockSessionStorageMockRecorder
+}
+
+// MockSessionStorageMockRecorder is the mock recorder for MockSessionStorage.
+type MockSessionStorageMockRecorder struct {
+	mock *MockSessionStorage
+}
+
+// NewMockSessionStorage creates a new mock instance.
+func NewMockSessionStorage(ctrl *gomock.Controller) *MockSessionStorage {
+	mock := &MockSessionStorage{ctrl: ctrl}
+	mock.recorder = &MockSessionStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSessionStorage) EXPECT() *MockSessionStorageMockRecorder {
+	return m.recorder
+}
+
+// CreateSession mocks base method.
+func (m *MockSessionStorage) CreateSession(arg0 context.Context, arg1 dto.Session) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockSessionStorageMockRecorder) CreateSession(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockSessionStorage)(nil).CreateSession), arg0, arg1)
+}
+
+// GetActiveSessions mocks base method.
+func (m *MockSessionStorage) GetActiveSessions(arg0 context.Context, arg1 string, arg2 time.Time) ([]dto.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetActiveSessions", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]dto.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetActiveSessions indicates an expected call of GetActiveSessions.
+func (mr *MockSessionStorageMockRecorder) GetActiveSessions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActiveSessions", reflect.TypeOf((*MockSessionStorage)(nil).GetActiveSessions), arg0, arg1, arg2)
+}
+
+// RevokeSession mocks base method.
+func (m *MockSessionStorage) RevokeSession(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockSessionStorageMockRecorder) RevokeSession(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockSessionStorage)(nil).RevokeSession), arg0, arg1, arg2)
+}
+
+// RevokeUserSessions mocks base method.
+func (m *MockSessionStorage) RevokeUserSessions(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserSessions", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserSessions indicates an expected call of RevokeUserSessions.
+func (mr *MockSessionStorageMockRecorder) RevokeUserSessions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserSessions", reflect.TypeOf((*MockSessionStorage)(nil).RevokeUserSessions), arg0, arg1)
+}
+
+// TouchSession mocks base method.
+func (m *MockSessionStorage) TouchSession(arg0 context.Context, arg1 string, arg2 time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchSession", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TouchSession indicates an expected call of TouchSession.
+func (mr *MockSessionStorageMockRecorder) TouchSession(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchSession", reflect.TypeOf((*MockSessionStorage)(nil).TouchSession), arg0, arg1, arg2)
+}
+
+// MockAPIKeyStorage is a mock of APIKeyStorage interface.
+type MockAPIKeyStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeyStorageMockRecorder
+}
+
+// MockAPIKeyStorageMockRecorder is the mock recorder for MockAPIKeyStorage.
+type MockAPIKeyStorageMockRecorder struct {
+	mock *MockAPIKeyStorage
+}
+
+// NewMockAPIKeyStorage creates a new mock instance.
+func NewMockAPIKeyStorage(ctrl *gomock.Controller) *MockAPIKeyStorage {
+	mock := &MockAPIKeyStorage{ctrl: ctrl}
+	mock.recorder = &MockAPIKeyStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPIKeyStorage) EXPECT() *MockAPIKeyStorageMockRecorder {
+	return m.recorder
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockAPIKeyStorage) CreateAPIKey(arg0 context.Context, arg1 dto.APIKey, arg2 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockAPIKeyStorageMockRecorder) CreateAPIKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockAPIKeyStorage)(nil).CreateAPIKey), arg0, arg1, arg2)
+}
+
+// GetAPIKeys mocks base method.
+func (m *MockAPIKeyStorage) GetAPIKeys(arg0 context.Context, arg1 string) ([]dto.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", arg0, arg1)
+	ret0, _ := ret[0].([]dto.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys.
+func (mr *MockAPIKeyStorageMockRecorder) GetAPIKeys(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockAPIKeyStorage)(nil).GetAPIKeys), arg0, arg1)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockAPIKeyStorage) RevokeAPIKey(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockAPIKeyStorageMockRecorder) RevokeAPIKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockAPIKeyStorage)(nil).RevokeAPIKey), arg0, arg1, arg2)
+}
+
+// UseAPIKey mocks base method.
+func (m *MockAPIKeyStorage) UseAPIKey(arg0 context.Context, arg1 string, arg2 time.Time) (dto.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseAPIKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(dto.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseAPIKey indicates an expected call of UseAPIKey.
+func (mr *MockAPIKeyStorageMockRecorder) UseAPIKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseAPIKey", reflect.TypeOf((*MockAPIKeyStorage)(nil).UseAPIKey), arg0, arg1, arg2)
+}
+
+// MockPasswordResetStorage is a mock of PasswordResetStorage interface.
+type MockPasswordResetStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockPasswordResetStorageMockRecorder
+}
+
+// MockPasswordResetStorageMockRecorder is the mock recorder for MockPasswordResetStorage.
+type MockPasswordResetStorageMockRecorder struct {
+	mock *MockPasswordResetStorage
+}
+
+// NewMockPasswordResetStorage creates a new mock instance.
+func NewMockPasswordResetStorage(ctrl *gomock.Controller) *MockPasswordResetStorage {
+	mock := &MockPasswordResetStorage{ctrl: ctrl}
+	mock.recorder = &MockPasswordResetStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPasswordResetStorage) EXPECT() *MockPasswordResetStorageMockRecorder {
+	return m.recorder
+}
+
+// GetPasswordResetToken mocks base method.
+func (m *MockPasswordResetStorage) GetPasswordResetToken(arg0 context.Context, arg1 string) (dto.PasswordResetToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordResetToken", arg0, arg1)
+	ret0, _ := ret[0].(dto.PasswordResetToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPasswordResetToken indicates an expected call of GetPasswordResetToken.
+func (mr *MockPasswordResetStorageMockRecorder) GetPasswordResetToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordResetToken", reflect.TypeOf((*MockPasswordResetStorage)(nil).GetPasswordResetToken), arg0, arg1)
+}
+
+// ResetPassword mocks base method.
+func (m *MockPasswordResetStorage) ResetPassword(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockPasswordResetStorageMockRecorder) ResetPassword(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockPasswordResetStorage)(nil).ResetPassword), arg0, arg1, arg2)
+}
+
+// SavePasswordResetToken mocks base method.
+func (m *MockPasswordResetStorage) SavePasswordResetToken(arg0 context.Context, arg1 dto.PasswordResetToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SavePasswordResetToken", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SavePasswordResetToken indicates an expected call of SavePasswordResetToken.
+func (mr *MockPasswordResetStorageMockRecorder) SavePasswordResetToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SavePasswordResetToken", reflect.TypeOf((*MockPasswordResetStorage)(nil).SavePasswordResetToken), arg0, arg1)
+}
+
+// MockWebhookStorage is a mock of WebhookStorage interface.
+type MockWebhookStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhookStorageMockRecorder
+}
+
+// MockWebhookStorageMockRecorder is the mock recorder for MockWebhookStorage.
+type MockWebhookStorageMockRecorder struct {
+	mock *MockWebhookStorage
+}
+
+// NewMockWebhookStorage creates a new mock instance.
+func NewMockWebhookStorage(ctrl *gomock.Controller) *MockWebhookStorage {
+	mock := &MockWebhookStorage{ctrl: ctrl}
+	mock.recorder = &MockWebhookStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhookStorage) EXPECT() *MockWebhookStorageMockRecorder {
+	return m.recorder
+}
+
+// ClaimWebhookDeliveries mocks base method.
+func (m *MockWebhookStorage) ClaimWebhookDeliveries(arg0 context.Context, arg1, arg2 time.Time, arg3 int) ([]dto.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimWebhookDeliveries", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]dto.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimWebhookDeliveries indicates an expected call of ClaimWebhookDeliveries.
+func (mr *MockWebhookStorageMockRecorder) ClaimWebhookDeliveries(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimWebhookDeliveries", reflect.TypeOf((*MockWebhookStorage)(nil).ClaimWebhookDeliveries), arg0, arg1, arg2, arg3)
+}
+
+// CreateWebhook mocks base method.
+func (m *MockWebhookStorage) CreateWebhook(arg0 context.Context, arg1 dto.Webhook, arg2 []byte) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockWebhookStorageMockRecorder) CreateWebhook(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockWebhookStorage)(nil).CreateWebhook), arg0, arg1, arg2)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockWebhookStorage) DeleteWebhook(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockWebhookStorageMockRecorder) DeleteWebhook(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockWebhookStorage)(nil).DeleteWebhook), arg0, arg1, arg2)
+}
+
+// GetWebhookDeliveries mocks base method.
+func (m *MockWebhookStorage) GetWebhookDeliveries(arg0 context.Context, arg1, arg2 string, arg3 int) ([]dto.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveries", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]dto.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveries indicates an expected call of GetWebhookDeliveries.
+func (mr *MockWebhookStorageMockRecorder) GetWebhookDeliveries(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveries", reflect.TypeOf((*MockWebhookStorage)(nil).GetWebhookDeliveries), arg0, arg1, arg2, arg3)
+}
+
+// GetWebhookSecretsToEncrypt mocks base method.
+func (m *MockWebhookStorage) GetWebhookSecretsToEncrypt(arg0 context.Context) ([]dto.WebhookSecret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookSecretsToEncrypt", arg0)
+	ret0, _ := ret[0].([]dto.WebhookSecret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookSecretsToEncrypt indicates an expected call of GetWebhookSecretsToEncrypt.
+func (mr *MockWebhookStorageMockRecorder) GetWebhookSecretsToEncrypt(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookSecretsToEncrypt", reflect.TypeOf((*MockWebhookStorage)(nil).GetWebhookSecretsToEncrypt), arg0)
+}
+
+// GetWebhooks mocks base method.
+func (m *MockWebhookStorage) GetWebhooks(arg0 context.Context, arg1 string) ([]dto.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhooks", arg0, arg1)
+	ret0, _ := ret[0].([]dto.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhooks indicates an expected call of GetWebhooks.
+func (mr *MockWebhookStorageMockRecorder) GetWebhooks(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhooks", reflect.TypeOf((*MockWebhookStorage)(nil).GetWebhooks), arg0, arg1)
+}
+
+// SaveEncryptedWebhookSecret mocks base method.
+func (m *MockWebhookStorage) SaveEncryptedWebhookSecret(arg0 context.Context, arg1 string, arg2 []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveEncryptedWebhookSecret", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveEncryptedWebhookSecret indicates an expected call of SaveEncryptedWebhookSecret.
+func (mr *MockWebhookStorageMockRecorder) SaveEncryptedWebhookSecret(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveEncryptedWebhookSecret", reflect.TypeOf((*MockWebhookStorage)(nil).SaveEncryptedWebhookSecret), arg0, arg1, arg2)
+}
+
+// SaveWebhookDeliveryAttempt mocks base method.
+func (m *MockWebhookStorage) SaveWebhookDeliveryAttempt(arg0 context.Context, arg1 dto.WebhookDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveWebhookDeliveryAttempt", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveWebhookDeliveryAttempt indicates an expected call of SaveWebhookDeliveryAttempt.
+func (mr *MockWebhookStorageMockRecorder) SaveWebhookDeliveryAttempt(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveWebhookDeliveryAttempt", reflect.TypeOf((*MockWebhookStorage)(nil).SaveWebhookDeliveryAttempt), arg0, arg1)
+}
+
+// MockAccrualJobStorage is a mock of AccrualJobStorage interface.
+type MockAccrualJobStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockAccrualJobStorageMockRecorder
+}
+
+// MockAccrualJobStorageMockRecorder is the mock recorder for MockAccrualJobStorage.
+type MockAccrualJobStorageMockRecorder struct {
+	mock *MockAccrualJobStorage
+}
+
+// NewMockAccrualJobStorage creates a new mock instance.
+func NewMockAccrualJobStorage(ctrl *gomock.Controller) *MockAccrualJobStorage {
+	mock := &MockAccrualJobStorage{ctrl: ctrl}
+	mock.recorder = &MockAccrualJobStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAccrualJobStorage) EXPECT() *MockAccrualJobStorageMockRecorder {
+	return m.recorder
+}
+
+// ClaimAccrualJobs mocks base method.
+func (m *MockAccrualJobStorage) ClaimAccrualJobs(arg0 context.Context, arg1 string, arg2, arg3 time.Time, arg4 int) ([]dto.AccrualJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimAccrualJobs", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].([]dto.AccrualJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimAccrualJobs indicates an expected call of ClaimAccrualJobs.
+func (mr *MockAccrualJobStorageMockRecorder) ClaimAccrualJobs(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimAccrualJobs", reflect.TypeOf((*MockAccrualJobStorage)(nil).ClaimAccrualJobs), arg0, arg1, arg2, arg3, arg4)
+}
+
+// CompleteAccrualJob mocks base method.
+func (m *MockAccrualJobStorage) CompleteAccrualJob(arg0 context.Context, arg1 dto.AccrualJob) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteAccrualJob", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteAccrualJob indicates an expected call of CompleteAccrualJob.
+func (mr *MockAccrualJobStorageMockRecorder) CompleteAccrualJob(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteAccrualJob", reflect.TypeOf((*MockAccrualJobStorage)(nil).CompleteAccrualJob), arg0, arg1)
+}
+
+// ExtendAccrualJobLeases mocks base method.
+func (m *MockAccrualJobStorage) ExtendAccrualJobLeases(arg0 context.Context, arg1 string, arg2 []string, arg3 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExtendAccrualJobLeases", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExtendAccrualJobLeases indicates an expected call of ExtendAccrualJobLeases.
+func (mr *MockAccrualJobStorageMockRecorder) ExtendAccrualJobLeases(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtendAccrualJobLeases", reflect.TypeOf((*MockAccrualJobStorage)(nil).ExtendAccrualJobLeases), arg0, arg1, arg2, arg3)
+}
+
+// RescheduleAccrualJob mocks base method.
+func (m *MockAccrualJobStorage) RescheduleAccrualJob(arg0 context.Context, arg1 dto.AccrualJob) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RescheduleAccrualJob", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RescheduleAccrualJob indicates an expected call of RescheduleAccrualJob.
+func (mr *MockAccrualJobStorageMockRecorder) RescheduleAccrualJob(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrder", reflect.TypeOf((*MockOrderStorage)(nil).UpdateOrder), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RescheduleAccrualJob", reflect.TypeOf((*MockAccrualJobStorage)(nil).RescheduleAccrualJob), arg0, arg1)
 }
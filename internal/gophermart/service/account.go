@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+)
+
+const (
+	deletedUserLoginPrefix   = "deleted-"
+	deletedUserPurgeInterval = 1 * time.Hour
+)
+
+// ExportUserData collects all data stored about the user.
+func (g *GophermartServiceImpl) ExportUserData(ctx context.Context, userID string) (entity.UserDataExport, error) {
+	user, err := g.userStorage.GetByID(ctx, userID)
+	if err != nil {
+		return entity.UserDataExport{}, fmt.Errorf("error during recieving user: %s, cause: %w", userID, err)
+	}
+	balance, err := g.GetBalanceByUserID(ctx, userID)
+	if err != nil {
+		return entity.UserDataExport{}, err
+	}
+	orders, err := g.GetOrdersByUser(ctx, userID, entity.OrderQuery{})
+	if err != nil {
+		return entity.UserDataExport{}, err
+	}
+	withdrawals, err := g.GetWithdrawalsByUserID(ctx, userID)
+	if err != nil {
+		return entity.UserDataExport{}, err
+	}
+	adjustments, err := g.GetAccrualAdjustments(ctx, userID)
+	if err != nil {
+		return entity.UserDataExport{}, err
+	}
+
+	return entity.UserDataExport{
+		Profile: entity.UserProfile{
+			ID:               user.ID,
+			Login:            user.Login,
+			Roles:            user.Roles,
+			TwoFactorEnabled: user.TOTPEnabled,
+		},
+		Balance:            balance,
+		Orders:             orders.Orders,
+		Withdrawals:        withdrawals,
+		AccrualAdjustments: adjustments,
+		ExportedAt:         time.Now(),
+	}, nil
+}
+
+// DeleteAccount deletes the account after checking the password and, if enabled, the second factor. Wrong ones
+// lock the deletion out like failed logins, users created through an OpenID Connect provider have no password. The login is anonymized right away, orders and withdrawals are kept
+// for the data retention period.
+func (g *GophermartServiceImpl) DeleteAccount(ctx context.Context, userID, password, code string) error {
+	// a stolen token must not allow guessing the password or the code
+	keys := []loginAttemptKey{{entity.LoginAttemptKindDeleteAccount, userID, g.loginThrottle.MaxFailuresPerLogin}}
+	if err := g.checkLoginLockout(ctx, keys); err != nil {
+		return err
+	}
+
+	user, err := g.userStorage.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("error during recieving user: %s, cause: %w", userID, err)
+	}
+	if err := g.verifyCurrentPassword(ctx, user, password, keys); err != nil {
+		return err
+	}
+	if user.TOTPEnabled {
+		if code == "" {
+			return ErrorTwoFactorRequired
+		}
+		valid, err := g.verifySecondFactor(ctx, user, code)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			if err := g.registerFailedLogin(ctx, keys); err != nil {
+				return err
+			}
+			return ErrorInvalidTOTPCode
+		}
+	}
+
+	err = g.userStorage.DeleteUser(ctx, userID, deletedUserLoginPrefix+userID, time.Now().Add(g.dataRetention))
+	if err != nil {
+		return fmt.Errorf("error during deleting user %s, cause: %w", userID, err)
+	}
+	if err := g.revokeUserTokens(ctx, userID); err != nil {
+		return err
+	}
+	serviceLogger.Info("user %s is deleted", userID)
+	return nil
+}
+
+// StartDeletedUserPurger periodically removes deleted users whose retention period is over, until ctx is done.
+func (g *GophermartServiceImpl) StartDeletedUserPurger(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(deletedUserPurgeInterval)
+		defer ticker.Stop()
+		for {
+			purged, err := g.userStorage.PurgeDeletedUsers(ctx, time.Now())
+			if err != nil {
+				serviceLogger.Error(fmt.Errorf("failed to purge deleted users: %w", err))
+			} else if purged > 0 {
+				serviceLogger.Info("purged %d deleted users", purged)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	loyaltyHTTPClient "github.com/apolsh/yapr-gophermart/internal/gophermart/client"
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+)
+
+const (
+	accrualJobLease        = time.Minute
+	accrualJobLeaseRenewal = accrualJobLease / 3
+	accrualPollInterval    = time.Second
+	accrualRetryDelay      = 15 * time.Second
+	accrualPollerStopWait  = 5 * time.Second
+)
+
+// accrualPoller runs the accrual jobs leased by this instance. The jobs are stored, so a restart loses nothing and
+// several instances share the work. A job may be polled twice if a lease runs out, the updates are idempotent.
+type accrualPoller struct {
+	owner   string
+	wakeup  chan struct{}
+	mu      sync.Mutex
+	running map[string]bool
+	wg      sync.WaitGroup
+}
+
+func newAccrualPoller() *accrualPoller {
+	hostname, _ := os.Hostname()
+	suffix, _ := generateRandomToken(8)
+	return &accrualPoller{
+		owner:   hostname + "-" + suffix,
+		wakeup:  make(chan struct{}, 1),
+		running: make(map[string]bool),
+	}
+}
+
+// wake makes the poller look for due jobs right away, e.g. after an order is uploaded.
+func (p *accrualPoller) wake() {
+	select {
+	case p.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (p *accrualPoller) start(orderNum string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running[orderNum] = true
+	p.wg.Add(1)
+}
+
+func (p *accrualPoller) finish(orderNum string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.running, orderNum)
+	p.wg.Done()
+}
+
+// stop waits a while for the running attempts, which end soon after the context of the poller is done.
+func (p *accrualPoller) stop() {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(accrualPollerStopWait):
+		serviceLogger.Info("accrual poller graceful shutdown timeout exceeded")
+	}
+}
+
+func (p *accrualPoller) runningJobs() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	orderNums := make([]string, 0, len(p.running))
+	for orderNum := range p.running {
+		orderNums = append(orderNums, orderNum)
+	}
+	return orderNums
+}
+
+// StartAccrualInfoSynchronizer polls the accrual system about the orders with due jobs until ctx is done, with at
+// most workers requests at a time. The requests are spread further by the rate limit of the accrual system.
+func (g *GophermartServiceImpl) StartAccrualInfoSynchronizer(ctx context.Context, workers int) error {
+	if workers < 1 {
+		return ErrorInvalidAccrualWorkers
+	}
+	go g.renewAccrualJobLeases(ctx)
+	go func() {
+		ticker := time.NewTicker(accrualPollInterval)
+		defer ticker.Stop()
+		for {
+			free := workers - len(g.accrualPoller.runningJobs())
+			if free > 0 {
+				now := time.Now()
+				jobs, err := g.accrualJobStorage.ClaimAccrualJobs(ctx, g.accrualPoller.owner, now, now.Add(accrualJobLease), free)
+				if err != nil && ctx.Err() == nil {
+					serviceLogger.Error(fmt.Errorf("failed to claim accrual jobs: %w", err))
+				}
+				for _, job := range jobs {
+					g.accrualPoller.start(job.OrderNumber)
+					go func(job entity.AccrualJob) {
+						defer g.accrualPoller.finish(job.OrderNumber)
+						g.pollAccrual(ctx, job)
+					}(job)
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-g.accrualPoller.wakeup:
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+func (g *GophermartServiceImpl) renewAccrualJobLeases(ctx context.Context) {
+	ticker := time.NewTicker(accrualJobLeaseRenewal)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		orderNums := g.accrualPoller.runningJobs()
+		if len(orderNums) == 0 {
+			continue
+		}
+		err := g.accrualJobStorage.ExtendAccrualJobLeases(ctx, g.accrualPoller.owner, orderNums, time.Now().Add(accrualJobLease))
+		if err != nil && ctx.Err() == nil {
+			serviceLogger.Error(fmt.Errorf("failed to extend accrual job leases: %w", err))
+		}
+	}
+}
+
+// pollAccrual makes one attempt of the job. The job is completed on a final status, otherwise it is retried
+// until its attempts are exhausted.
+func (g *GophermartServiceImpl) pollAccrual(ctx context.Context, job entity.AccrualJob) {
+	orderNum := job.OrderNumber
+	loyaltyInfo, err := g.loyaltyService.GetLoyaltyPoints(ctx, orderNum)
+	if ctx.Err() != nil {
+		// the instance is stopping, the job is released for the next one without counting the attempt
+		now := time.Now()
+		job.NextAttemptAt = &now
+		g.saveAccrualJob(job, false)
+		return
+	}
+	var tooManyRequests *loyaltyHTTPClient.TooManyRequestsError
+	if errors.As(err, &tooManyRequests) {
+		// the whole polling is paused meanwhile, the job is retried as soon as the pause is over and the attempt
+		// is not counted, so that throttling does not exhaust the job
+		nextAttemptAt := time.Now().Add(tooManyRequests.RetryAfter)
+		job.NextAttemptAt = &nextAttemptAt
+		job.LastError = err.Error()
+		g.saveAccrualJob(job, false)
+		return
+	}
+
+	now := time.Now()
+	job.Attempts++
+	job.LastError = ""
+	// the results are saved even if the instance is stopping meanwhile
+	if checkErr := g.orderStorage.SaveOrderCheck(context.Background(), orderNum, job.Attempts, now); checkErr != nil {
+		serviceLogger.Error(fmt.Errorf("failed to save check of order: %s, cause: %w", orderNum, checkErr))
+	}
+
+	if err != nil {
+		job.LastError = err.Error()
+	} else {
+		err = g.orderStorage.UpdateOrder(context.Background(), orderNum, loyaltyInfo.Status, loyaltyInfo.Accrual, job.Attempts)
+		if err != nil {
+			job.LastError = err.Error()
+			serviceLogger.Error(fmt.Errorf("failed to update order: %s, cause: %w", orderNum, err))
+		} else if loyaltyInfo.Status == entity.StatusProcessed || loyaltyInfo.Status == entity.StatusInvalid {
+			g.saveAccrualJob(job, true)
+			return
+		}
+	}
+
+	if job.Attempts > g.accrualMaxTries {
+		serviceLogger.Error(fmt.Errorf("maximum number of attempts to retrieve accrual info of order %s has been exceeded, it has to be rechecked", orderNum))
+		job.NextAttemptAt = nil
+	} else {
+		nextAttemptAt := now.Add(accrualRetryDelay)
+		job.NextAttemptAt = &nextAttemptAt
+	}
+	g.saveAccrualJob(job, false)
+}
+
+func (g *GophermartServiceImpl) saveAccrualJob(job entity.AccrualJob, completed bool) {
+	var err error
+	if completed {
+		err = g.accrualJobStorage.CompleteAccrualJob(context.Background(), job)
+	} else {
+		err = g.accrualJobStorage.RescheduleAccrualJob(context.Background(), job)
+	}
+	if err != nil {
+		serviceLogger.Error(fmt.Errorf("failed to save accrual job of order: %s, cause: %w", job.OrderNumber, err))
+	}
+}
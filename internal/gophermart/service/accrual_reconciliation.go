@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	loyaltyHTTPClient "github.com/apolsh/yapr-gophermart/internal/gophermart/client"
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/shopspring/decimal"
+)
+
+// StartAccrualReconciler periodically asks the accrual system again about the orders processed or rejected within
+// the window, because its answer may change. A changed accrual is applied as an adjustment of the balance. Only
+// one instance reconciles at a time. A zero window disables the reconciliation.
+func (g *GophermartServiceImpl) StartAccrualReconciler(ctx context.Context, interval, window time.Duration) {
+	if window <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			adjusted, err := g.reconcileAccruals(ctx, time.Now().Add(-window))
+			if err != nil && ctx.Err() == nil {
+				serviceLogger.Error(fmt.Errorf("failed to reconcile accruals: %w", err))
+			}
+			if adjusted > 0 {
+				serviceLogger.Info("adjusted accruals of %d orders", adjusted)
+			}
+		}
+	}()
+}
+
+// reconcileAccruals checks the processed and invalid orders one by one and returns the number of adjusted ones.
+// The round is skipped while another instance reconciles. It stops when the accrual system asks to slow down, the
+// rest is checked in the next round.
+func (g *GophermartServiceImpl) reconcileAccruals(ctx context.Context, uploadedSince time.Time) (int, error) {
+	unlock, ok, err := g.orderStorage.TryLockAccrualReconciliation(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock accrual reconciliation: %w", err)
+	}
+	if !ok {
+		return 0, nil
+	}
+	defer unlock()
+
+	orders, err := g.orderStorage.GetOrderNumsToReconcile(ctx, uploadedSince)
+	if err != nil {
+		return 0, fmt.Errorf("failed to recieve order nums to reconcile: %w", err)
+	}
+
+	adjusted := 0
+	for _, orderNum := range orders {
+		if ctx.Err() != nil {
+			return adjusted, ctx.Err()
+		}
+		loyaltyInfo, err := g.loyaltyService.GetLoyaltyPoints(ctx, orderNum)
+		if errors.Is(err, loyaltyHTTPClient.ErrTooManyRequests) {
+			return adjusted, err
+		}
+		if err != nil {
+			serviceLogger.Error(fmt.Errorf("failed to reconcile accrual of order %s: %w", orderNum, err))
+			continue
+		}
+
+		// a revoked order keeps nothing, orders being recalculated are checked in the next round
+		accrual := loyaltyInfo.Accrual
+		switch loyaltyInfo.Status {
+		case loyaltyHTTPClient.StatusProcessed:
+		case loyaltyHTTPClient.StatusInvalid:
+			accrual = decimal.Zero
+		default:
+			continue
+		}
+		order, err := g.orderStorage.GetOrder(ctx, orderNum)
+		if err != nil {
+			serviceLogger.Error(fmt.Errorf("failed to reconcile accrual of order %s: %w", orderNum, err))
+			continue
+		}
+		if order.Status == loyaltyInfo.Status && order.Accrual.Equal(accrual) {
+			continue
+		}
+
+		err = g.orderStorage.UpdateOrder(ctx, orderNum, loyaltyInfo.Status, accrual, 0)
+		if err != nil {
+			serviceLogger.Error(fmt.Errorf("failed to reconcile accrual of order %s: %w", orderNum, err))
+			continue
+		}
+		adjusted++
+	}
+	return adjusted, nil
+}
+
+// GetAccrualAdjustments returns the adjustments of the accruals of the user, the most recent first.
+func (g *GophermartServiceImpl) GetAccrualAdjustments(ctx context.Context, id string) ([]entity.AccrualAdjustment, error) {
+	adjustments, err := g.orderStorage.GetAccrualAdjustments(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error during recieving accrual adjustments of user: %s, cause %w", id, err)
+	}
+	return adjustments, nil
+}
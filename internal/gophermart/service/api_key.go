@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+)
+
+const (
+	apiKeyPrefix = "gmk_"
+	// apiKeyDisplayLength is the length of the key part that is stored in plain text to tell the keys apart
+	apiKeyDisplayLength = len(apiKeyPrefix) + 8
+	maxAPIKeyNameLength = 100
+)
+
+var knownScopes = map[string]bool{
+	entity.ScopeOrdersWrite: true,
+	entity.ScopeOrdersRead:  true,
+	entity.ScopeBalanceRead: true,
+	entity.ScopeWithdraw:    true,
+}
+
+// CreateAPIKey creates a key for machine clients limited to the given scopes. Only the hash of the key is stored,
+// the key itself is returned once.
+func (g *GophermartServiceImpl) CreateAPIKey(ctx context.Context, userID, name string, scopes []string) (entity.CreatedAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxAPIKeyNameLength {
+		return entity.CreatedAPIKey{}, ErrorInvalidAPIKeyName
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return entity.CreatedAPIKey{}, err
+	}
+
+	secret, err := generateRandomToken(32)
+	if err != nil {
+		return entity.CreatedAPIKey{}, fmt.Errorf("error during generating api key for user %s, cause: %w", userID, err)
+	}
+	key := apiKeyPrefix + secret
+
+	apiKey := entity.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    key[:apiKeyDisplayLength],
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	apiKey.ID, err = g.apiKeyStorage.CreateAPIKey(ctx, apiKey, hashToken(key))
+	if err != nil {
+		return entity.CreatedAPIKey{}, fmt.Errorf("error during saving api key of user %s, cause: %w", userID, err)
+	}
+	return entity.CreatedAPIKey{APIKey: apiKey, Key: key}, nil
+}
+
+func (g *GophermartServiceImpl) GetAPIKeys(ctx context.Context, userID string) ([]entity.APIKey, error) {
+	keys, err := g.apiKeyStorage.GetAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error during recieving api keys of user %s, cause: %w", userID, err)
+	}
+	return keys, nil
+}
+
+func (g *GophermartServiceImpl) RevokeAPIKey(ctx context.Context, userID, keyID string) error {
+	err := g.apiKeyStorage.RevokeAPIKey(ctx, userID, keyID)
+	if err != nil {
+		return fmt.Errorf("error during revoking api key %s, cause: %w", keyID, err)
+	}
+	return nil
+}
+
+// ParseAPIKey authenticates a machine client. The principal has the user role only, whatever roles the owner has,
+// and is limited to the scopes of the key.
+func (g *GophermartServiceImpl) ParseAPIKey(ctx context.Context, key string) (entity.Principal, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return entity.Principal{}, ErrorInvalidAPIKey
+	}
+
+	apiKey, err := g.apiKeyStorage.UseAPIKey(ctx, hashToken(key), time.Now())
+	if err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			return entity.Principal{}, ErrorInvalidAPIKey
+		}
+		return entity.Principal{}, fmt.Errorf("error during checking api key, cause: %w", err)
+	}
+	return entity.Principal{
+		UserID:   apiKey.UserID,
+		Roles:    []string{entity.RoleUser},
+		APIKeyID: apiKey.ID,
+		Scopes:   apiKey.Scopes,
+	}, nil
+}
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, ErrorEmptyValue
+	}
+	unique := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		if !knownScopes[scope] {
+			return nil, fmt.Errorf("%w: %s", ErrorUnknownScope, scope)
+		}
+		unique[scope] = true
+	}
+
+	normalized := make([]string, 0, len(unique))
+	for scope := range unique {
+		normalized = append(normalized, scope)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
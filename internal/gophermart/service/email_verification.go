@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"time"
+
+	loyaltyHTTPClient "github.com/apolsh/yapr-gophermart/internal/gophermart/client"
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const emailVerificationTokenPurpose = "email"
+
+// EmailVerificationOptions configures the email login mode. If Required is set, logins must be email addresses
+// and users can log in with a password only after following the link sent to the address.
+type EmailVerificationOptions struct {
+	Required bool
+	Mailer   loyaltyHTTPClient.Mailer
+	// LinkURL is the public URL of the verification endpoint, the token is appended as a query parameter
+	LinkURL string
+	TTL     time.Duration
+}
+
+// VerifyEmail completes the verification started by the link, each link can be used once.
+func (g *GophermartServiceImpl) VerifyEmail(ctx context.Context, verificationToken string) error {
+	if verificationToken == "" {
+		return ErrorEmptyValue
+	}
+
+	claims, err := g.parseClaimsWithPurpose(verificationToken, emailVerificationTokenPurpose)
+	if err != nil {
+		return err
+	}
+	revoked, err := g.isTokenRevoked(ctx, claims)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrorTokenRevoked
+	}
+
+	err = g.userStorage.MarkEmailVerified(ctx, claims.UserID)
+	if err != nil {
+		return fmt.Errorf("error during marking email of user %s as verified, cause: %w", claims.UserID, err)
+	}
+	err = g.revokedTokenStorage.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return fmt.Errorf("error during revoking email verification token of user %s, cause: %w", claims.UserID, err)
+	}
+	g.revocationCache.setRevoked(claims.ID, claims.ExpiresAt.Time)
+	return nil
+}
+
+// sendEmailVerification mails a signed one-time link to the login of the user.
+func (g *GophermartServiceImpl) sendEmailVerification(ctx context.Context, user entity.User) error {
+	now := time.Now()
+	jti, err := generateRandomToken(16)
+	if err != nil {
+		return err
+	}
+	verificationToken, err := g.tokenKeys.sign(&jwtTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(g.emailVerification.TTL)),
+			Audience:  tokenAudience(emailVerificationTokenPurpose),
+		},
+		UserID:  user.ID,
+		Purpose: emailVerificationTokenPurpose,
+	})
+	if err != nil {
+		return fmt.Errorf("error during generating email verification token for user %s, cause: %w", user.ID, err)
+	}
+
+	link, err := url.Parse(g.emailVerification.LinkURL)
+	if err != nil {
+		return fmt.Errorf("error during building email verification link, cause: %w", err)
+	}
+	query := link.Query()
+	query.Set("token", verificationToken)
+	link.RawQuery = query.Encode()
+
+	err = g.emailVerification.Mailer.Send(ctx, loyaltyHTTPClient.Mail{
+		To:      user.Login,
+		Subject: "Confirm your Gophermart email address",
+		Body: fmt.Sprintf("Follow the link to confirm your email address:\n\n%s\n\nThe link is valid for %s.\n",
+			link, g.emailVerification.TTL),
+	})
+	if err != nil {
+		return fmt.Errorf("error during sending email verification to user %s, cause: %w", user.ID, err)
+	}
+	return nil
+}
+
+// validateEmailLogin accepts a bare address only, display names and comments are not allowed.
+func validateEmailLogin(login string) error {
+	address, err := mail.ParseAddress(login)
+	if err != nil || address.Name != "" || address.Address != login {
+		return ErrorInvalidEmailLogin
+	}
+	return nil
+}
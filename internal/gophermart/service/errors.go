@@ -5,7 +5,37 @@ import (
 )
 
 var (
-	ErrorEmptyValue               = errors.New("empty values is not allowed")
-	ErrorInvalidPassword          = errors.New("invalid password")
-	ErrorInvalidOrderNumberFormat = errors.New("invalid order number format")
+	ErrorEmptyValue                 = errors.New("empty values is not allowed")
+	ErrorInvalidPassword            = errors.New("invalid password")
+	ErrorInvalidOrderNumberFormat   = errors.New("invalid order number format")
+	ErrorInvalidOrderQuery          = errors.New("invalid order query")
+	ErrorOrdersBatchTooLarge        = errors.New("too many orders in the batch")
+	ErrorInvalidRefreshToken        = errors.New("invalid refresh token")
+	ErrorRefreshTokenReused         = errors.New("refresh token reuse detected")
+	ErrorTokenRevoked               = errors.New("token is revoked")
+	ErrorTokenExpired               = errors.New("token is expired")
+	ErrorInvalidToken               = errors.New("invalid token")
+	ErrorLoginLocked                = errors.New("login is temporarily locked")
+	ErrorWeakPassword               = errors.New("password does not satisfy the password policy")
+	ErrorUnknownRole                = errors.New("unknown role")
+	ErrorTwoFactorRequired          = errors.New("two-factor authentication is required")
+	ErrorTwoFactorNotConfigured     = errors.New("two-factor authentication is not configured")
+	ErrorTwoFactorAlreadyEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrorTwoFactorNotEnrolled       = errors.New("two-factor authentication is not enrolled")
+	ErrorInvalidTOTPCode            = errors.New("invalid two-factor authentication code")
+	ErrorUnknownScope               = errors.New("unknown api key scope")
+	ErrorInvalidAPIKeyName          = errors.New("api key name must be 1 to 100 characters long")
+	ErrorInvalidAPIKey              = errors.New("invalid api key")
+	ErrorOIDCNotConfigured          = errors.New("openid connect login is not configured")
+	ErrorInvalidOIDCState           = errors.New("invalid openid connect login state")
+	ErrorOIDCLoginFailed            = errors.New("openid connect login failed")
+	ErrorInvalidEmailLogin          = errors.New("login must be an email address")
+	ErrorPasswordResetNotConfigured = errors.New("password reset is not configured")
+	ErrorInvalidPasswordResetToken  = errors.New("invalid or expired password reset token")
+	ErrorEmailNotVerified           = errors.New("email address is not verified, a verification link has been sent")
+	ErrorInvalidWebhookURL          = errors.New("webhook url must be an absolute http or https url")
+	ErrorUnknownWebhookEvent        = errors.New("unknown webhook event type")
+	ErrorInvalidAccrualWorkers      = errors.New("number of accrual workers must be positive")
+	ErrorWebhooksNotConfigured      = errors.New("webhooks are not configured")
+	ErrorRateLimited                = errors.New("too many requests")
 )
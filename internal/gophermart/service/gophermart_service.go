@@ -1,134 +1,508 @@
-//go:generate mockgen -destination=../mocks/service.go -package=mocks github.com/apolsh/yapr-gophermart/internal/gophermart/service UserStorage,OrderStorage
+//go:generate mockgen -destination=../mocks/service.go -package=mocks github.com/apolsh/yapr-gophermart/internal/gophermart/service UserStorage,OrderStorage,RefreshTokenStorage,RevokedTokenStorage,LoginAttemptStorage,RequestLimitStorage,SessionStorage,APIKeyStorage,PasswordResetStorage,WebhookStorage,AccrualJobStorage
 package service
 
 import (
 	"context"
 	"errors"
 	"fmt"
-	"strconv"
 	"time"
 
 	loyaltyHTTPClient "github.com/apolsh/yapr-gophermart/internal/gophermart/client"
 	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
 	"github.com/apolsh/yapr-gophermart/internal/logger"
 	"github.com/golang-jwt/jwt/v4"
-	"github.com/rs/zerolog/log"
 	"github.com/shopspring/decimal"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var serviceLogger = logger.LoggerOfComponent("gophmarket_service_logger")
 
+const (
+	maxOrdersBatchSize   = 1000
+	maxOrderNumberLength = 255
+)
+
 type (
 	UserStorage interface {
 		NewUser(ctx context.Context, login, hashedPassword string) (string, error)
 		Get(ctx context.Context, login string) (entity.User, error)
+		GetByID(ctx context.Context, id string) (entity.User, error)
+		NewUserWithIdentity(ctx context.Context, login string, identity entity.UserIdentity) (string, error)
+		GetByIdentity(ctx context.Context, issuer, subject string) (entity.User, error)
+		UpdatePassword(ctx context.Context, id, hashedPassword string) error
+		UpdatePasswordHash(ctx context.Context, id, oldHashedPassword, newHashedPassword string) error
+		UpdateRoles(ctx context.Context, id string, roles []string) error
+		MarkEmailVerified(ctx context.Context, id string) error
+		GetLoginsToNormalize(ctx context.Context) ([]entity.User, error)
+		GetLogins(ctx context.Context) ([]entity.User, error)
+		UpdateLogin(ctx context.Context, id, login string) error
+		SaveLoginCollision(ctx context.Context, collision entity.LoginCollision) error
+		SetTOTPSecret(ctx context.Context, id string, encryptedSecret []byte) error
+		EnableTOTP(ctx context.Context, id string, step int64, recoveryCodeHashes []string) error
+		MarkTOTPStepUsed(ctx context.Context, id string, step int64) error
+		UseRecoveryCode(ctx context.Context, id, codeHash string) error
+		DeleteUser(ctx context.Context, id, anonymizedLogin string, retainUntil time.Time) error
+		PurgeDeletedUsers(ctx context.Context, now time.Time) (int64, error)
 	}
 
 	OrderStorage interface {
 		SaveNewOrder(ctx context.Context, orderNum string, userID string) error
-		UpdateOrder(ctx context.Context, orderNum string, status string, accrual decimal.Decimal) error
-		GetOrdersByID(ctx context.Context, id string) ([]entity.Order, error)
+		SaveNewOrders(ctx context.Context, orderNums []string, userID string) (map[string]string, error)
+		UpdateOrder(ctx context.Context, orderNum string, status string, accrual decimal.Decimal, attempt int) error
+		SaveOrderCheck(ctx context.Context, orderNum string, attempt int, checkedAt time.Time) error
+		SaveOrderRecheck(ctx context.Context, recheck entity.OrderRecheck) error
+		GetOrder(ctx context.Context, orderNum string) (entity.Order, error)
+		GetOrderStatusHistory(ctx context.Context, orderNum string) ([]entity.OrderStatusChange, error)
+		GetOrderEventsReplayStart(ctx context.Context, userID string, lastEventID int64, window time.Duration) (int64, error)
+		GetOrderEvents(ctx context.Context, userID string, afterID int64, limit int) ([]entity.OrderEvent, error)
+		ListenOrderEvents(ctx context.Context, listening func(), handle func(entity.OrderEvent)) error
+		GetOrdersByID(ctx context.Context, id string, filter entity.OrderFilter) ([]entity.Order, error)
 		GetBalanceByUserID(ctx context.Context, id string) (entity.Balance, error)
 		CreateWithdraw(ctx context.Context, id string, withdraw entity.Withdraw) error
 		GetWithdrawalsByUserID(ctx context.Context, id string) ([]entity.Withdraw, error)
-		GetAllUnfinishedAccrualOrderNums(ctx context.Context) ([]string, error)
+		TryLockAccrualReconciliation(ctx context.Context) (unlock func(), ok bool, err error)
+		GetOrderNumsToReconcile(ctx context.Context, uploadedSince time.Time) ([]string, error)
+		GetAccrualAdjustments(ctx context.Context, id string) ([]entity.AccrualAdjustment, error)
+	}
+
+	RefreshTokenStorage interface {
+		SaveRefreshToken(ctx context.Context, token entity.RefreshToken) error
+		GetRefreshToken(ctx context.Context, tokenHash string) (entity.RefreshToken, error)
+		MarkRefreshTokenUsed(ctx context.Context, tokenHash string) error
+		RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+		RevokeUserRefreshTokens(ctx context.Context, userID string) error
+	}
+
+	RevokedTokenStorage interface {
+		RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+		IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+		RevokeUserTokens(ctx context.Context, userID string, revokedBefore time.Time) error
+		GetUserTokensRevokedBefore(ctx context.Context, userID string) (time.Time, error)
+	}
+
+	LoginAttemptStorage interface {
+		GetLockedUntil(ctx context.Context, kind, key string) (time.Time, error)
+		RegisterFailedAttempt(ctx context.Context, kind, key string, now, windowStart time.Time) (entity.LoginAttempts, error)
+		LockLogin(ctx context.Context, lockout entity.LoginLockout) error
+		GetLoginLockouts(ctx context.Context, limit int) ([]entity.LoginLockout, error)
+		ResetFailedAttempts(ctx context.Context, kind, key string) error
+	}
+
+	RequestLimitStorage interface {
+		RegisterRequest(ctx context.Context, kind, key string, now, windowStart time.Time) (entity.RequestCount, error)
+	}
+
+	SessionStorage interface {
+		CreateSession(ctx context.Context, session entity.Session) error
+		GetActiveSessions(ctx context.Context, userID string, activeSince time.Time) ([]entity.Session, error)
+		TouchSession(ctx context.Context, id string, lastSeenAt time.Time) (bool, error)
+		RevokeSession(ctx context.Context, userID, id string) error
+		RevokeUserSessions(ctx context.Context, userID string) error
+	}
+
+	APIKeyStorage interface {
+		CreateAPIKey(ctx context.Context, key entity.APIKey, keyHash string) (string, error)
+		GetAPIKeys(ctx context.Context, userID string) ([]entity.APIKey, error)
+		UseAPIKey(ctx context.Context, keyHash string, usedAt time.Time) (entity.APIKey, error)
+		RevokeAPIKey(ctx context.Context, userID, id string) error
+	}
+
+	PasswordResetStorage interface {
+		SavePasswordResetToken(ctx context.Context, token entity.PasswordResetToken) error
+		GetPasswordResetToken(ctx context.Context, tokenHash string) (entity.PasswordResetToken, error)
+		ResetPassword(ctx context.Context, tokenHash, hashedPassword string) error
+	}
+
+	WebhookStorage interface {
+		CreateWebhook(ctx context.Context, webhook entity.Webhook, encryptedSecret []byte) (string, error)
+		GetWebhooks(ctx context.Context, userID string) ([]entity.Webhook, error)
+		DeleteWebhook(ctx context.Context, userID, id string) error
+		GetWebhookDeliveries(ctx context.Context, userID, webhookID string, limit int) ([]entity.WebhookDelivery, error)
+		ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]entity.WebhookDelivery, error)
+		SaveWebhookDeliveryAttempt(ctx context.Context, delivery entity.WebhookDelivery) error
+		GetWebhookSecretsToEncrypt(ctx context.Context) ([]entity.WebhookSecret, error)
+		SaveEncryptedWebhookSecret(ctx context.Context, webhookID string, encryptedSecret []byte) error
+	}
+
+	AccrualJobStorage interface {
+		ClaimAccrualJobs(ctx context.Context, owner string, now, leaseUntil time.Time, limit int) ([]entity.AccrualJob, error)
+		ExtendAccrualJobLeases(ctx context.Context, owner string, orderNums []string, leaseUntil time.Time) error
+		RescheduleAccrualJob(ctx context.Context, job entity.AccrualJob) error
+		CompleteAccrualJob(ctx context.Context, job entity.AccrualJob) error
 	}
 )
 
 type GophermartServiceImpl struct {
-	jwtSecretKey        string
-	userStorage         UserStorage
-	orderStorage        OrderStorage
-	loyaltyService      loyaltyHTTPClient.LoyaltyService
-	asyncWorker         *AsyncWorker
-	AsyncWorkerMaxTries int
+	tokenKeys            *TokenKeySet
+	accessTokenTTL       time.Duration
+	refreshTokenTTL      time.Duration
+	userStorage          UserStorage
+	orderStorage         OrderStorage
+	refreshTokenStorage  RefreshTokenStorage
+	revokedTokenStorage  RevokedTokenStorage
+	revocationCache      *revocationCache
+	loginAttemptStorage  LoginAttemptStorage
+	sessionStorage       SessionStorage
+	apiKeyStorage        APIKeyStorage
+	passwordResetStorage PasswordResetStorage
+	webhookStorage       WebhookStorage
+	accrualJobStorage    AccrualJobStorage
+	requestLimitStorage  RequestLimitStorage
+	loginThrottle        LoginThrottlePolicy
+	requestLimitWindow   time.Duration
+	passwordPolicy       PasswordPolicy
+	passwordHasher       PasswordHasher
+	twoFactor            TwoFactorOptions
+	dataRetention        time.Duration
+	oidcProvider         loyaltyHTTPClient.OIDCProvider
+	emailVerification    EmailVerificationOptions
+	passwordReset        PasswordResetOptions
+	webhooks             WebhookOptions
+	orderEvents          *orderEventBroker
+	loyaltyService       loyaltyHTTPClient.LoyaltyService
+	accrualPoller        *accrualPoller
+	accrualMaxTries      int
+	maxOrderRechecks     int
 }
 
 func (g *GophermartServiceImpl) Close() {
-	g.asyncWorker.Close()
+	g.accrualPoller.stop()
+}
+
+// accessTokenAudience is the audience of access tokens, verifiers using the published keys must require it. The
+// tokens issued for other purposes have an audience of their own, so that they are never taken for access tokens.
+const accessTokenAudience = "gophermart"
+
+// tokenAudience returns the audience of the tokens issued for the purpose, access tokens have no purpose.
+func tokenAudience(purpose string) jwt.ClaimStrings {
+	if purpose == "" {
+		return jwt.ClaimStrings{accessTokenAudience}
+	}
+	return jwt.ClaimStrings{accessTokenAudience + ":" + purpose}
 }
 
 type jwtTokenClaims struct {
 	jwt.RegisteredClaims
-	UserID string `json:"user_id"`
+	UserID    string   `json:"user_id"`
+	Roles     []string `json:"roles,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
+	Purpose   string   `json:"purpose,omitempty"`
+	// Nonce and CodeVerifier are only set in the state of an OpenID Connect login
+	Nonce        string `json:"nonce,omitempty"`
+	CodeVerifier string `json:"code_verifier,omitempty"`
 }
 
 func NewGophermartServiceImpl(
-	jwtSecretKey string,
-	asyncWorkerMaxTries int,
+	tokenKeys *TokenKeySet,
+	accessTokenTTL time.Duration,
+	refreshTokenTTL time.Duration,
+	accrualMaxTries int,
+	maxOrderRechecks int,
 	accrualSystemAddress string,
+	accrualRateLimit int,
+	loginThrottle LoginThrottlePolicy,
+	requestLimitWindow time.Duration,
+	passwordPolicy PasswordPolicy,
+	passwordHasher PasswordHasher,
+	twoFactor TwoFactorOptions,
+	dataRetention time.Duration,
+	oidcProvider loyaltyHTTPClient.OIDCProvider,
+	emailVerification EmailVerificationOptions,
+	passwordReset PasswordResetOptions,
+	webhooks WebhookOptions,
 	userStorage UserStorage,
-	orderStorage OrderStorage) (*GophermartServiceImpl, error) {
-
-	if userStorage == nil || orderStorage == nil {
+	orderStorage OrderStorage,
+	refreshTokenStorage RefreshTokenStorage,
+	revokedTokenStorage RevokedTokenStorage,
+	loginAttemptStorage LoginAttemptStorage,
+	requestLimitStorage RequestLimitStorage,
+	sessionStorage SessionStorage,
+	apiKeyStorage APIKeyStorage,
+	passwordResetStorage PasswordResetStorage,
+	webhookStorage WebhookStorage,
+	accrualJobStorage AccrualJobStorage) (*GophermartServiceImpl, error) {
+
+	if tokenKeys == nil || passwordHasher == nil {
+		return nil, errors.New("token keys or password hasher were not initialized")
+	}
+	if emailVerification.Required && (emailVerification.Mailer == nil || emailVerification.LinkURL == "") {
+		return nil, errors.New("email login requires a mailer and a verification link url")
+	}
+
+	if userStorage == nil || orderStorage == nil || refreshTokenStorage == nil || revokedTokenStorage == nil ||
+		loginAttemptStorage == nil || requestLimitStorage == nil || sessionStorage == nil || apiKeyStorage == nil ||
+		passwordResetStorage == nil || webhookStorage == nil || accrualJobStorage == nil {
 		return nil, errors.New("not all storages were initialized")
 	}
 
-	loyaltyService, err := loyaltyHTTPClient.NewLoyaltyServiceImpl(accrualSystemAddress)
+	if accrualRateLimit < 1 {
+		return nil, errors.New("accrual system rate limit must be positive")
+	}
+	loyaltyService, err := loyaltyHTTPClient.NewLoyaltyServiceImpl(accrualSystemAddress, loyaltyHTTPClient.NewRateLimiter(accrualRateLimit))
 	if err != nil {
 		return nil, fmt.Errorf("failed during loyalty client for gophmart service init: %w", err)
 	}
 
 	return &GophermartServiceImpl{
-		jwtSecretKey:        jwtSecretKey,
-		userStorage:         userStorage,
-		orderStorage:        orderStorage,
-		loyaltyService:      loyaltyService,
-		AsyncWorkerMaxTries: asyncWorkerMaxTries,
+		tokenKeys:            tokenKeys,
+		accessTokenTTL:       accessTokenTTL,
+		refreshTokenTTL:      refreshTokenTTL,
+		userStorage:          userStorage,
+		orderStorage:         orderStorage,
+		refreshTokenStorage:  refreshTokenStorage,
+		revokedTokenStorage:  revokedTokenStorage,
+		revocationCache:      newRevocationCache(),
+		orderEvents:          newOrderEventBroker(),
+		loginAttemptStorage:  loginAttemptStorage,
+		sessionStorage:       sessionStorage,
+		apiKeyStorage:        apiKeyStorage,
+		passwordResetStorage: passwordResetStorage,
+		webhookStorage:       webhookStorage,
+		accrualJobStorage:    accrualJobStorage,
+		requestLimitStorage:  requestLimitStorage,
+		loginThrottle:        loginThrottle,
+		requestLimitWindow:   requestLimitWindow,
+		passwordPolicy:       passwordPolicy,
+		passwordHasher:       passwordHasher,
+		twoFactor:            twoFactor,
+		dataRetention:        dataRetention,
+		oidcProvider:         oidcProvider,
+		emailVerification:    emailVerification,
+		passwordReset:        passwordReset,
+		webhooks:             webhooks,
+		loyaltyService:       loyaltyService,
+		accrualPoller:        newAccrualPoller(),
+		accrualMaxTries:      accrualMaxTries,
+		maxOrderRechecks:     maxOrderRechecks,
 	}, nil
 }
 
-func (g *GophermartServiceImpl) AddUser(ctx context.Context, login, password string) (string, error) {
+// AddUser registers a user and logs it in. In the email login mode the user gets a verification link instead and
+// ErrorEmailNotVerified is returned.
+func (g *GophermartServiceImpl) AddUser(ctx context.Context, login, password string, client entity.ClientInfo) (entity.TokenPair, error) {
+	login = normalizeLogin(login)
 	if login == "" || password == "" {
-		return "", ErrorEmptyValue
+		return entity.TokenPair{}, ErrorEmptyValue
+	}
+	if g.emailVerification.Required {
+		if err := validateEmailLogin(login); err != nil {
+			return entity.TokenPair{}, err
+		}
+	}
+	if err := g.passwordPolicy.Validate(login, password); err != nil {
+		return entity.TokenPair{}, err
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := g.passwordHasher.Hash(password)
 	if err != nil {
-		return "", fmt.Errorf("error during generating hashed password for user %s, cause: %w", login, err)
+		return entity.TokenPair{}, fmt.Errorf("error during generating hashed password for user %s, cause: %w", login, err)
 	}
 
-	id, err := g.userStorage.NewUser(ctx, login, string(hashedPassword))
+	id, err := g.userStorage.NewUser(ctx, login, hashedPassword)
 	if err != nil {
-		return "", fmt.Errorf("error during saving new user: %s, cause: %w", login, err)
+		return entity.TokenPair{}, fmt.Errorf("error during saving new user: %s, cause: %w", login, err)
+	}
+	user := entity.User{ID: id, Login: login, Roles: []string{entity.RoleUser}}
+
+	if g.emailVerification.Required {
+		// the link is sent again on the next login with the password
+		if err := g.sendEmailVerification(ctx, user); err != nil {
+			serviceLogger.Error(err)
+		}
+		return entity.TokenPair{}, ErrorEmailNotVerified
 	}
-	return g.generateToken(id)
+	return g.startSession(ctx, user, client)
 }
 
-func (g *GophermartServiceImpl) LoginUser(ctx context.Context, login, password string) (string, error) {
+func (g *GophermartServiceImpl) LoginUser(ctx context.Context, login, password string, client entity.ClientInfo) (entity.TokenPair, error) {
 	if login == "" || password == "" {
-		return "", ErrorEmptyValue
+		return entity.TokenPair{}, ErrorEmptyValue
 	}
 
-	user, err := g.userStorage.Get(ctx, login)
+	user, err := g.getUserByLogin(ctx, login)
+	if err != nil && !errors.Is(err, storage.ErrItemNotFound) {
+		return entity.TokenPair{}, fmt.Errorf("error during recieving user: %s, cause: %w", login, err)
+	}
+	// the attempts count against the login of the user found, so that users with colliding logins are throttled
+	// separately
+	throttledLogin := normalizeLogin(login)
+	if err == nil {
+		throttledLogin = user.Login
+	}
+	attemptKeys := g.loginAttemptKeys(throttledLogin, client.IP)
+	if err := g.checkLoginLockout(ctx, attemptKeys); err != nil {
+		return entity.TokenPair{}, err
+	}
 	if err != nil {
-		return "", fmt.Errorf("error during recieving user: %s, cause: %w", login, err)
+		if err := g.registerFailedLogin(ctx, attemptKeys); err != nil {
+			return entity.TokenPair{}, err
+		}
+		return entity.TokenPair{}, fmt.Errorf("error during recieving user: %s, cause: %w", login, err)
 	}
-	err = bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(password))
+	valid, err := g.passwordHasher.Verify(user.HashedPassword, password)
 	if err != nil {
-		return "", ErrorInvalidPassword
+		return entity.TokenPair{}, fmt.Errorf("error during verifying password of user: %s, cause: %w", login, err)
+	}
+	if !valid {
+		if err := g.registerFailedLogin(ctx, attemptKeys); err != nil {
+			return entity.TokenPair{}, err
+		}
+		return entity.TokenPair{}, ErrorInvalidPassword
+	}
+	if g.passwordHasher.NeedsRehash(user.HashedPassword) {
+		g.rehashPassword(ctx, user, password)
+	}
+
+	if g.emailVerification.Required && !user.EmailVerified {
+		if err := g.sendEmailVerification(ctx, user); err != nil {
+			return entity.TokenPair{}, err
+		}
+		return entity.TokenPair{}, ErrorEmailNotVerified
+	}
+
+	if user.TOTPEnabled {
+		// failed attempts are reset only after the second factor, otherwise the password alone would allow
+		// guessing TOTP codes without ever being locked out
+		challengeToken, err := g.generateChallengeToken(user)
+		if err != nil {
+			return entity.TokenPair{}, fmt.Errorf("error during generating challenge token for user %s, cause: %w", user.ID, err)
+		}
+		return entity.TokenPair{}, &TwoFactorRequiredError{ChallengeToken: challengeToken}
+	}
+	if err := g.resetFailedLogins(ctx, user.Login); err != nil {
+		return entity.TokenPair{}, err
 	}
 
-	return g.generateToken(user.ID)
+	return g.startSession(ctx, user, client)
 }
 
-func (g *GophermartServiceImpl) ParseJWTToken(tokenString string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwtTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
+// rehashPassword replaces a hash made with outdated algorithm or parameters. Failures are only logged,
+// the user is already authenticated and the hash will be upgraded on the next login.
+func (g *GophermartServiceImpl) rehashPassword(ctx context.Context, user entity.User, password string) {
+	hashedPassword, err := g.passwordHasher.Hash(password)
+	if err != nil {
+		serviceLogger.Error(fmt.Errorf("error during rehashing password of user %s, cause: %w", user.ID, err))
+		return
+	}
+	err = g.userStorage.UpdatePasswordHash(ctx, user.ID, user.HashedPassword, hashedPassword)
+	if err != nil {
+		serviceLogger.Error(fmt.Errorf("error during updating password hash of user %s, cause: %w", user.ID, err))
+	}
+}
+
+func (g *GophermartServiceImpl) ParseJWTToken(ctx context.Context, tokenString string) (entity.Principal, error) {
+	claims, err := g.parseClaims(tokenString)
+	if err != nil {
+		return entity.Principal{}, err
+	}
+
+	checks := []func(context.Context, *jwtTokenClaims) (bool, error){g.isTokenRevoked, g.isRevokedForUser, g.isSessionRevoked}
+	for _, isRevoked := range checks {
+		revoked, err := isRevoked(ctx, claims)
+		if err != nil {
+			return entity.Principal{}, err
 		}
-		return []byte(g.jwtSecretKey), nil
-	})
+		if revoked {
+			return entity.Principal{}, ErrorTokenRevoked
+		}
+	}
+	return entity.Principal{UserID: claims.UserID, Roles: claims.Roles, SessionID: claims.SessionID}, nil
+}
+
+// ChangePassword sets a new password and revokes all tokens of the user, the caller gets a new token pair. Wrong
+// current passwords lock the change out like failed logins, so that a stolen token can't be used to guess it. A
+// user created through an OpenID Connect provider sets the first password without a current one.
+func (g *GophermartServiceImpl) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string, client entity.ClientInfo) (entity.TokenPair, error) {
+	if newPassword == "" {
+		return entity.TokenPair{}, ErrorEmptyValue
+	}
+	keys := []loginAttemptKey{{entity.LoginAttemptKindChangePassword, userID, g.loginThrottle.MaxFailuresPerLogin}}
+	if err := g.checkLoginLockout(ctx, keys); err != nil {
+		return entity.TokenPair{}, err
+	}
+
+	user, err := g.userStorage.GetByID(ctx, userID)
+	if err != nil {
+		return entity.TokenPair{}, fmt.Errorf("error during recieving user: %s, cause: %w", userID, err)
+	}
+	if err := g.verifyCurrentPassword(ctx, user, oldPassword, keys); err != nil {
+		return entity.TokenPair{}, err
+	}
+	if err := g.loginAttemptStorage.ResetFailedAttempts(ctx, entity.LoginAttemptKindChangePassword, userID); err != nil {
+		return entity.TokenPair{}, fmt.Errorf("error during resetting failed password changes, cause: %w", err)
+	}
+	if oldPassword == newPassword {
+		return entity.TokenPair{}, &WeakPasswordError{Reason: "must differ from the current password"}
+	}
+	if err := g.passwordPolicy.Validate(user.Login, newPassword); err != nil {
+		return entity.TokenPair{}, err
+	}
+
+	hashedPassword, err := g.passwordHasher.Hash(newPassword)
 	if err != nil {
-		return "", fmt.Errorf("error during parsing jwt token %w", err)
+		return entity.TokenPair{}, fmt.Errorf("error during generating hashed password for user %s, cause: %w", userID, err)
+	}
+	err = g.userStorage.UpdatePassword(ctx, userID, hashedPassword)
+	if err != nil {
+		return entity.TokenPair{}, fmt.Errorf("error during updating password of user %s, cause: %w", userID, err)
+	}
+
+	if err := g.revokeUserTokens(ctx, userID); err != nil {
+		return entity.TokenPair{}, err
+	}
+	return g.startSession(ctx, user, client)
+}
+
+// verifyCurrentPassword checks the password of a user who is already authenticated by a token, wrong ones count
+// against keys. Users created through an OpenID Connect provider have no password, the token is enough for them.
+func (g *GophermartServiceImpl) verifyCurrentPassword(ctx context.Context, user entity.User, password string, keys []loginAttemptKey) error {
+	if user.HashedPassword == "" {
+		return nil
+	}
+	if password == "" {
+		return ErrorEmptyValue
+	}
+	valid, err := g.passwordHasher.Verify(user.HashedPassword, password)
+	if err != nil {
+		return fmt.Errorf("error during verifying password of user: %s, cause: %w", user.ID, err)
+	}
+	if !valid {
+		if err := g.registerFailedLogin(ctx, keys); err != nil {
+			return err
+		}
+		return ErrorInvalidPassword
+	}
+	return nil
+}
+
+func (g *GophermartServiceImpl) parseClaims(tokenString string) (*jwtTokenClaims, error) {
+	return g.parseClaimsWithPurpose(tokenString, "")
+}
+
+// parseClaimsWithPurpose parses a token issued for the purpose, access tokens have no purpose. Both the audience and
+// the purpose are checked, this keeps tokens like 2FA challenges from being used as access tokens. Access tokens
+// issued before the audience was introduced have none and are still accepted until they expire.
+func (g *GophermartServiceImpl) parseClaimsWithPurpose(tokenString, purpose string) (*jwtTokenClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &jwtTokenClaims{}, g.tokenKeys.keyFunc)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrorTokenExpired
+		}
+		return nil, fmt.Errorf("%w: error during parsing jwt token %s", ErrorInvalidToken, err)
 	}
 
 	claims, ok := token.Claims.(*jwtTokenClaims)
 	if !ok {
-		return "", errors.New("invalid token claims type")
+		return nil, fmt.Errorf("%w: invalid token claims type", ErrorInvalidToken)
+	}
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("%w: token has no id or expiration time", ErrorInvalidToken)
 	}
-	return claims.UserID, nil
+	legacyAccessToken := purpose == "" && len(claims.Audience) == 0
+	if !legacyAccessToken && !claims.VerifyAudience(tokenAudience(purpose)[0], true) || claims.Purpose != purpose {
+		return nil, fmt.Errorf("%w: token is issued for another purpose", ErrorInvalidToken)
+	}
+	return claims, nil
 }
 
 func (g *GophermartServiceImpl) AddOrder(ctx context.Context, orderNum string, userID string) error {
@@ -142,13 +516,56 @@ func (g *GophermartServiceImpl) AddOrder(ctx context.Context, orderNum string, u
 		return fmt.Errorf("error during saving new order: %w", err)
 	}
 
-	if g.asyncWorker != nil {
-		g.asyncWorker.ExecuteTask(func() {
-			g.getAccrualAsync(0, orderNum)
-		})
+	g.accrualPoller.wake()
+	return nil
+}
+
+// AddOrders uploads a batch of orders in one transaction and reports the result of every number in the order of
+// the batch. A number repeated in the batch is accepted only once.
+func (g *GophermartServiceImpl) AddOrders(ctx context.Context, orderNums []string, userID string) ([]entity.OrderUploadResult, error) {
+	if len(orderNums) == 0 {
+		return nil, ErrorEmptyValue
+	}
+	if len(orderNums) > maxOrdersBatchSize {
+		return nil, ErrorOrdersBatchTooLarge
 	}
 
-	return nil
+	valid := make([]string, 0, len(orderNums))
+	seen := make(map[string]bool, len(orderNums))
+	for _, orderNum := range orderNums {
+		if validateOrderFormat(orderNum) == nil && !seen[orderNum] {
+			valid = append(valid, orderNum)
+			seen[orderNum] = true
+		}
+	}
+
+	saved := map[string]string{}
+	if len(valid) > 0 {
+		var err error
+		saved, err = g.orderStorage.SaveNewOrders(ctx, valid, userID)
+		if err != nil {
+			return nil, fmt.Errorf("error during saving new orders: %w", err)
+		}
+	}
+
+	results := make([]entity.OrderUploadResult, 0, len(orderNums))
+	reported := make(map[string]bool, len(orderNums))
+	for _, orderNum := range orderNums {
+		result := entity.OrderUploadResult{Number: orderNum, Result: entity.OrderUploadInvalidFormat}
+		if savedResult, ok := saved[orderNum]; ok {
+			result.Result = savedResult
+		}
+		if reported[orderNum] && result.Result == entity.OrderUploadAccepted {
+			result.Result = entity.OrderUploadAlreadyUploaded
+		}
+		reported[orderNum] = true
+		results = append(results, result)
+	}
+
+	if len(saved) > 0 {
+		g.accrualPoller.wake()
+	}
+	return results, nil
 }
 
 func (g *GophermartServiceImpl) GetBalanceByUserID(ctx context.Context, id string) (entity.Balance, error) {
@@ -179,52 +596,27 @@ func (g *GophermartServiceImpl) GetWithdrawalsByUserID(ctx context.Context, id s
 	return withdraw, nil
 }
 
-func (g *GophermartServiceImpl) GetOrdersByUser(ctx context.Context, id string) ([]entity.Order, error) {
-	orders, err := g.orderStorage.GetOrdersByID(ctx, id)
-	if err != nil {
-		return []entity.Order{}, fmt.Errorf("error during recieving orders of user: %s, cause %w", id, err)
-	}
-	return orders, nil
-}
-
-func (g *GophermartServiceImpl) StartAccrualInfoSynchronizer(ctx context.Context, loyaltyServiceRateLimit int) error {
-	asyncWorker, err := NewAsyncWorker(loyaltyServiceRateLimit)
-	if err != nil {
-		return fmt.Errorf("failed during async worker for gophmart service init: %w", err)
-	}
-	g.asyncWorker = asyncWorker
-
-	orders, err := g.orderStorage.GetAllUnfinishedAccrualOrderNums(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to recieve unfinished accrual order nums: %w", err)
-	}
-
-	for _, order := range orders {
-		g.asyncWorker.ExecuteTask(func() {
-			g.getAccrualAsync(0, order)
-		})
-	}
-	return nil
-}
-
-func validateOrderFormat(orderNumAsString string) error {
-	orderNum, err := strconv.Atoi(orderNumAsString)
-	if err != nil {
+// validateOrderFormat checks the Luhn checksum of an order number. The number is a string of digits of any length
+// up to the size of the column, so it is never converted to an integer.
+func validateOrderFormat(orderNum string) error {
+	if orderNum == "" || len(orderNum) > maxOrderNumberLength {
 		return ErrorInvalidOrderNumberFormat
 	}
 
 	var checksum int
 
-	for i := 1; orderNum > 0; i++ {
-		num := orderNum % 10
-		if i%2 == 0 {
+	for i := len(orderNum) - 1; i >= 0; i-- {
+		if orderNum[i] < '0' || orderNum[i] > '9' {
+			return ErrorInvalidOrderNumberFormat
+		}
+		num := int(orderNum[i] - '0')
+		if (len(orderNum)-i)%2 == 0 {
 			num = num * 2
 			if num > 9 {
 				num = num%10 + num/10
 			}
 		}
 		checksum += num
-		orderNum = orderNum / 10
 	}
 	if checksum%10 != 0 {
 		return ErrorInvalidOrderNumberFormat
@@ -232,57 +624,27 @@ func validateOrderFormat(orderNumAsString string) error {
 	return nil
 }
 
-func (g *GophermartServiceImpl) generateToken(id string) (string, error) {
+func (g *GophermartServiceImpl) generateToken(user entity.User, sessionID string) (string, error) {
 	now := time.Now()
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwtTokenClaims{
-		jwt.RegisteredClaims{
+	jti, err := generateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	return g.tokenKeys.sign(&jwtTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(g.accessTokenTTL)),
+			Audience:  tokenAudience(""),
 		},
-		id,
+		UserID:    user.ID,
+		Roles:     user.Roles,
+		SessionID: sessionID,
 	})
-
-	return token.SignedString([]byte(g.jwtSecretKey))
 }
 
-func (g *GophermartServiceImpl) getAccrualAsync(numOfTry int, orderNum string) {
-	if numOfTry > g.AsyncWorkerMaxTries {
-		log.Err(fmt.Errorf("maximum number of attempts to retrieve accrual info of order %s has been exceeded", orderNum))
-		return
-	}
-	numOfTry++
-	loyaltyInfo, err := g.loyaltyService.GetLoyaltyPoints(context.Background(), orderNum)
-	if errors.Is(loyaltyHTTPClient.ErrTooManyRequests, err) {
-		time.AfterFunc(1*time.Minute, func() {
-			g.asyncWorker.ExecuteTask(func() {
-				g.getAccrualAsync(numOfTry, orderNum)
-			})
-		})
-	}
-	if errors.Is(loyaltyHTTPClient.ErrOrderIsNotRegisteredYet, err) {
-		time.AfterFunc(15*time.Second, func() {
-			g.asyncWorker.ExecuteTask(func() {
-				g.getAccrualAsync(numOfTry, orderNum)
-			})
-		})
-	}
-
-	if err != nil {
-		serviceLogger.Error(fmt.Errorf("failed to recieve loyality points info: %w", err))
-		return
-	}
-
-	if loyaltyInfo.Status == loyaltyHTTPClient.StatusProcessing || loyaltyInfo.Status == loyaltyHTTPClient.StatusRegistered {
-		time.AfterFunc(15*time.Second, func() {
-			g.asyncWorker.ExecuteTask(func() {
-				g.getAccrualAsync(numOfTry, orderNum)
-			})
-		})
-	}
-	err = g.orderStorage.UpdateOrder(context.Background(), orderNum, loyaltyInfo.Status, loyaltyInfo.Accrual)
-	if err != nil {
-		serviceLogger.Error(fmt.Errorf("failed to update order: %s, cause: %w", orderNum, err))
-		return
-	}
+func (g *GophermartServiceImpl) GetJWKS() entity.JWKS {
+	return g.tokenKeys.JWKS()
 }
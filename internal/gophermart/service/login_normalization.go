@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+var loginFolder = cases.Fold()
+
+// normalizeLogin maps logins that look the same to one form: compatibility characters are decomposed with NFKC and
+// the case is folded. Folding may produce characters that are not NFKC normalized, so it is applied once more.
+func normalizeLogin(login string) string {
+	return norm.NFKC.String(loginFolder.String(norm.NFKC.String(login)))
+}
+
+// getUserByLogin looks the user up by the exact login first and by the normalized login only if that misses. Users
+// whose login collided with the login of another user keep their login, the exact lookup keeps them from being
+// shadowed by the user with the normalized login.
+func (g *GophermartServiceImpl) getUserByLogin(ctx context.Context, login string) (entity.User, error) {
+	user, err := g.userStorage.Get(ctx, login)
+	if normalized := normalizeLogin(login); errors.Is(err, storage.ErrItemNotFound) && normalized != login {
+		return g.userStorage.Get(ctx, normalized)
+	}
+	return user, err
+}
+
+// MigrateLogins normalizes logins stored before the normalization was introduced. The collisions are detected in a
+// single pass over all logins before any login is changed, with the same normalization as the logins are looked up
+// with: the migration of the database only approximates the case folding with lower(), which e.g. keeps "ß" apart
+// from "ss". A login whose normalized form is the login of another user, or the normalized form of another login, is
+// not changed and is recorded as a collision. It is safe to run on every start, normalized logins and known
+// collisions are skipped.
+func (g *GophermartServiceImpl) MigrateLogins(ctx context.Context) error {
+	users, err := g.userStorage.GetLoginsToNormalize(ctx)
+	if err != nil {
+		return fmt.Errorf("error during recieving logins to normalize, cause: %w", err)
+	}
+	if len(users) == 0 {
+		return nil
+	}
+	allUsers, err := g.userStorage.GetLogins(ctx)
+	if err != nil {
+		return fmt.Errorf("error during recieving logins, cause: %w", err)
+	}
+
+	owners := make(map[string][]string)
+	for _, user := range allUsers {
+		normalized := normalizeLogin(user.Login)
+		owners[normalized] = append(owners[normalized], user.ID)
+	}
+	collides := func(user entity.User, normalized string) bool {
+		for _, id := range owners[normalized] {
+			if id != user.ID {
+				return true
+			}
+		}
+		return false
+	}
+
+	collisions := make([]entity.LoginCollision, 0)
+	updates := make([]entity.LoginCollision, 0)
+	for _, user := range users {
+		normalized := normalizeLogin(user.Login)
+		if normalized == user.Login {
+			continue
+		}
+		change := entity.LoginCollision{UserID: user.ID, Login: user.Login, NormalizedLogin: normalized}
+		if collides(user, normalized) {
+			collisions = append(collisions, change)
+		} else {
+			updates = append(updates, change)
+		}
+	}
+
+	for _, collision := range collisions {
+		if err := g.saveLoginCollision(ctx, collision); err != nil {
+			return err
+		}
+	}
+	for _, update := range updates {
+		err := g.userStorage.UpdateLogin(ctx, update.UserID, update.NormalizedLogin)
+		// a user may have registered with the normalized login meanwhile
+		if errors.Is(err, storage.ErrorLoginIsAlreadyUsed) {
+			err = g.saveLoginCollision(ctx, update)
+		} else if err != nil {
+			err = fmt.Errorf("error during normalizing login of user %s, cause: %w", update.UserID, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GophermartServiceImpl) saveLoginCollision(ctx context.Context, collision entity.LoginCollision) error {
+	serviceLogger.Warn("login %s of user %s collides with another login after normalization to %s",
+		collision.Login, collision.UserID, collision.NormalizedLogin)
+	if err := g.userStorage.SaveLoginCollision(ctx, collision); err != nil {
+		return fmt.Errorf("error during saving login collision of user %s, cause: %w", collision.UserID, err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+)
+
+const (
+	loginLockoutsDefaultLimit = 100
+	loginLockoutsMaxLimit     = 1000
+)
+
+// LoginThrottlePolicy describes when failed logins lock out a login or a client IP. Every consecutive lockout of the
+// same key doubles the lockout duration, up to MaxLockout. Counters are reset after FailureWindow without failures.
+type LoginThrottlePolicy struct {
+	MaxFailuresPerLogin int
+	MaxFailuresPerIP    int
+	FailureWindow       time.Duration
+	BaseLockout         time.Duration
+	MaxLockout          time.Duration
+}
+
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry after %s", e.RetryAfter)
+}
+
+func (e *LoginLockedError) Is(target error) bool {
+	return target == ErrorLoginLocked
+}
+
+type loginAttemptKey struct {
+	kind        string
+	key         string
+	maxFailures int
+}
+
+func (g *GophermartServiceImpl) loginAttemptKeys(login, clientIP string) []loginAttemptKey {
+	keys := []loginAttemptKey{{entity.LoginAttemptKindLogin, login, g.loginThrottle.MaxFailuresPerLogin}}
+	if clientIP != "" {
+		keys = append(keys, loginAttemptKey{entity.LoginAttemptKindIP, clientIP, g.loginThrottle.MaxFailuresPerIP})
+	}
+	return keys
+}
+
+func (g *GophermartServiceImpl) checkLoginLockout(ctx context.Context, keys []loginAttemptKey) error {
+	now := time.Now()
+	for _, k := range keys {
+		lockedUntil, err := g.loginAttemptStorage.GetLockedUntil(ctx, k.kind, k.key)
+		if err != nil {
+			return fmt.Errorf("error during checking login lockout, cause: %w", err)
+		}
+		if lockedUntil.After(now) {
+			return &LoginLockedError{RetryAfter: lockedUntil.Sub(now)}
+		}
+	}
+	return nil
+}
+
+func (g *GophermartServiceImpl) registerFailedLogin(ctx context.Context, keys []loginAttemptKey) error {
+	now := time.Now()
+	for _, k := range keys {
+		attempts, err := g.loginAttemptStorage.RegisterFailedAttempt(ctx, k.kind, k.key, now, now.Add(-g.loginThrottle.FailureWindow))
+		if err != nil {
+			return fmt.Errorf("error during registering failed login attempt, cause: %w", err)
+		}
+		if k.maxFailures <= 0 || attempts.Failures < k.maxFailures {
+			continue
+		}
+
+		lockout := entity.LoginLockout{
+			Kind:        k.kind,
+			Key:         k.key,
+			Failures:    attempts.Failures,
+			LockedAt:    now,
+			LockedUntil: now.Add(g.lockoutDuration(attempts.Lockouts)),
+		}
+		if err := g.loginAttemptStorage.LockLogin(ctx, lockout); err != nil {
+			return fmt.Errorf("error during locking login, cause: %w", err)
+		}
+		serviceLogger.Warn("%s %s is locked until %s after %d failed login attempts", k.kind, k.key, lockout.LockedUntil, attempts.Failures)
+	}
+	return nil
+}
+
+func (g *GophermartServiceImpl) resetFailedLogins(ctx context.Context, login string) error {
+	err := g.loginAttemptStorage.ResetFailedAttempts(ctx, entity.LoginAttemptKindLogin, login)
+	if err != nil {
+		return fmt.Errorf("error during resetting failed login attempts, cause: %w", err)
+	}
+	return nil
+}
+
+// GetLoginLockouts returns the latest lockouts of logins, IPs and the other throttled keys, it is meant for the
+// admins. A limit below 1 means the default one, the limit is capped.
+func (g *GophermartServiceImpl) GetLoginLockouts(ctx context.Context, limit int) ([]entity.LoginLockout, error) {
+	if limit < 1 {
+		limit = loginLockoutsDefaultLimit
+	}
+	if limit > loginLockoutsMaxLimit {
+		limit = loginLockoutsMaxLimit
+	}
+	lockouts, err := g.loginAttemptStorage.GetLoginLockouts(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error during receiving login lockouts, cause: %w", err)
+	}
+	return lockouts, nil
+}
+
+func (g *GophermartServiceImpl) lockoutDuration(previousLockouts int) time.Duration {
+	duration := g.loginThrottle.BaseLockout
+	for i := 0; i < previousLockouts && duration < g.loginThrottle.MaxLockout; i++ {
+		duration *= 2
+	}
+	if duration > g.loginThrottle.MaxLockout {
+		return g.loginThrottle.MaxLockout
+	}
+	return duration
+}
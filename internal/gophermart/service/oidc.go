@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	loyaltyHTTPClient "github.com/apolsh/yapr-gophermart/internal/gophermart/client"
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	oidcStateTokenPurpose = "oidc"
+	oidcStateTTL          = 10 * time.Minute
+	oidcLoginPrefix       = "oidc-"
+	maxLoginLength        = 255
+)
+
+// StartOIDCLogin prepares an authorization code request with PKCE. The state token keeps the nonce and the code
+// verifier until the provider redirects back, it has to be stored by the client, e.g. in a cookie.
+func (g *GophermartServiceImpl) StartOIDCLogin(ctx context.Context) (entity.OIDCAuthorization, error) {
+	if g.oidcProvider == nil {
+		return entity.OIDCAuthorization{}, ErrorOIDCNotConfigured
+	}
+
+	state, err := generateRandomToken(16)
+	if err != nil {
+		return entity.OIDCAuthorization{}, fmt.Errorf("error during generating oidc state, cause: %w", err)
+	}
+	nonce, err := generateRandomToken(16)
+	if err != nil {
+		return entity.OIDCAuthorization{}, fmt.Errorf("error during generating oidc nonce, cause: %w", err)
+	}
+	codeVerifier, err := generateRandomToken(32)
+	if err != nil {
+		return entity.OIDCAuthorization{}, fmt.Errorf("error during generating pkce code verifier, cause: %w", err)
+	}
+	challenge := sha256.Sum256([]byte(codeVerifier))
+
+	authURL, err := g.oidcProvider.AuthCodeURL(ctx, state, nonce, base64.RawURLEncoding.EncodeToString(challenge[:]))
+	if err != nil {
+		return entity.OIDCAuthorization{}, fmt.Errorf("error during building oidc authorization url, cause: %w", err)
+	}
+
+	now := time.Now()
+	stateToken, err := g.tokenKeys.sign(&jwtTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        state,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(oidcStateTTL)),
+			Audience:  tokenAudience(oidcStateTokenPurpose),
+		},
+		Purpose:      oidcStateTokenPurpose,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+	})
+	if err != nil {
+		return entity.OIDCAuthorization{}, fmt.Errorf("error during generating oidc state token, cause: %w", err)
+	}
+	return entity.OIDCAuthorization{URL: authURL, StateToken: stateToken}, nil
+}
+
+// FinishOIDCLogin exchanges the authorization code and logs in the user linked to the external subject. A user is
+// created on the first login, existing users are never linked by login or email. A user with enabled 2FA gets
+// TwoFactorRequiredError and completes the login with LoginTwoFactor.
+func (g *GophermartServiceImpl) FinishOIDCLogin(ctx context.Context, stateToken, state, code string, client entity.ClientInfo) (entity.TokenPair, error) {
+	if g.oidcProvider == nil {
+		return entity.TokenPair{}, ErrorOIDCNotConfigured
+	}
+	if stateToken == "" || state == "" || code == "" {
+		return entity.TokenPair{}, ErrorInvalidOIDCState
+	}
+
+	claims, err := g.parseClaimsWithPurpose(stateToken, oidcStateTokenPurpose)
+	if err != nil {
+		return entity.TokenPair{}, fmt.Errorf("%w: %s", ErrorInvalidOIDCState, err)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.ID), []byte(state)) != 1 {
+		return entity.TokenPair{}, fmt.Errorf("%w: state does not match", ErrorInvalidOIDCState)
+	}
+	revoked, err := g.isTokenRevoked(ctx, claims)
+	if err != nil {
+		return entity.TokenPair{}, err
+	}
+	if revoked {
+		return entity.TokenPair{}, fmt.Errorf("%w: state is already used", ErrorInvalidOIDCState)
+	}
+	// the state can be used only once
+	err = g.revokedTokenStorage.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return entity.TokenPair{}, fmt.Errorf("error during revoking oidc state, cause: %w", err)
+	}
+	g.revocationCache.setRevoked(claims.ID, claims.ExpiresAt.Time)
+
+	idToken, err := g.oidcProvider.Exchange(ctx, code, claims.CodeVerifier)
+	if err != nil {
+		if errors.Is(err, loyaltyHTTPClient.ErrOIDCCodeRejected) || errors.Is(err, loyaltyHTTPClient.ErrOIDCInvalidIDToken) {
+			return entity.TokenPair{}, fmt.Errorf("%w: %s", ErrorOIDCLoginFailed, err)
+		}
+		return entity.TokenPair{}, fmt.Errorf("error during exchanging oidc authorization code, cause: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(claims.Nonce)) != 1 {
+		return entity.TokenPair{}, fmt.Errorf("%w: nonce does not match", ErrorOIDCLoginFailed)
+	}
+
+	user, err := g.getOrCreateOIDCUser(ctx, idToken)
+	if err != nil {
+		return entity.TokenPair{}, err
+	}
+	if user.TOTPEnabled {
+		challengeToken, err := g.generateChallengeToken(user)
+		if err != nil {
+			return entity.TokenPair{}, fmt.Errorf("error during generating challenge token for user %s, cause: %w", user.ID, err)
+		}
+		return entity.TokenPair{}, &TwoFactorRequiredError{ChallengeToken: challengeToken}
+	}
+	return g.startSession(ctx, user, client)
+}
+
+func (g *GophermartServiceImpl) getOrCreateOIDCUser(ctx context.Context, idToken loyaltyHTTPClient.OIDCIDToken) (entity.User, error) {
+	user, err := g.userStorage.GetByIdentity(ctx, idToken.Issuer, idToken.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, storage.ErrItemNotFound) {
+		return entity.User{}, fmt.Errorf("error during recieving user of oidc subject %s, cause: %w", idToken.Subject, err)
+	}
+
+	identity := entity.UserIdentity{Issuer: idToken.Issuer, Subject: idToken.Subject}
+	if idToken.EmailVerified {
+		identity.Email = idToken.Email
+	}
+	candidates := oidcLoginCandidates(idToken, g.emailVerification.Required)
+	if len(candidates) == 0 {
+		return entity.User{}, fmt.Errorf("%w: the provider has not confirmed an email to be used as login", ErrorOIDCLoginFailed)
+	}
+	for _, login := range candidates {
+		id, err := g.userStorage.NewUserWithIdentity(ctx, login, identity)
+		if errors.Is(err, storage.ErrorLoginIsAlreadyUsed) {
+			continue
+		}
+		if errors.Is(err, storage.ErrIdentityAlreadyLinked) {
+			// a concurrent first login of the same subject has created the user
+			user, err = g.userStorage.GetByIdentity(ctx, idToken.Issuer, idToken.Subject)
+			if err != nil {
+				return entity.User{}, fmt.Errorf("error during recieving user of oidc subject %s, cause: %w", idToken.Subject, err)
+			}
+			return user, nil
+		}
+		if err != nil {
+			return entity.User{}, fmt.Errorf("error during saving user of oidc subject %s, cause: %w", idToken.Subject, err)
+		}
+		serviceLogger.Info("created user %s for oidc subject %s", login, idToken.Subject)
+		user := entity.User{ID: id, Login: login, Roles: []string{entity.RoleUser}}
+		if g.emailVerification.Required {
+			// the login is the email verified by the provider
+			if err := g.userStorage.MarkEmailVerified(ctx, id); err != nil {
+				return entity.User{}, fmt.Errorf("error during marking email of user %s as verified, cause: %w", id, err)
+			}
+			user.EmailVerified = true
+		}
+		return user, nil
+	}
+	return entity.User{}, fmt.Errorf("error during saving user of oidc subject %s, cause: %w", idToken.Subject, storage.ErrorLoginIsAlreadyUsed)
+}
+
+// oidcLoginCandidates returns the logins to try for a new user, the last one is derived from the subject and is
+// unique per provider account. With emailOnly only the verified email is returned if it is a valid email login, the
+// preferred username is not verified even if it looks like an email.
+func oidcLoginCandidates(idToken loyaltyHTTPClient.OIDCIDToken, emailOnly bool) []string {
+	email := normalizeLogin(idToken.Email)
+	if !idToken.EmailVerified || len(email) > maxLoginLength {
+		email = ""
+	}
+	if emailOnly {
+		if email == "" || validateEmailLogin(email) != nil {
+			return nil
+		}
+		return []string{email}
+	}
+
+	candidates := make([]string, 0, 3)
+	if username := normalizeLogin(idToken.PreferredUsername); username != "" && len(username) <= maxLoginLength {
+		candidates = append(candidates, username)
+	}
+	if email != "" {
+		candidates = append(candidates, email)
+	}
+	return append(candidates, oidcLoginPrefix+hashToken(idToken.Issuer + " " + idToken.Subject)[:16])
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+)
+
+const (
+	orderEventsReplayPage       = 1000
+	orderEventsSubscriberBuffer = 64
+	orderEventsListenMaxBackoff = 30 * time.Second
+	// orderEventsLateCommitWindow bounds the time between observing a status and committing it
+	orderEventsLateCommitWindow = time.Minute
+)
+
+// orderEventBroker fans the events of this instance's listener out to the subscribed streams of the owners.
+type orderEventBroker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan entity.OrderEvent]struct{}
+	closed      bool
+}
+
+func newOrderEventBroker() *orderEventBroker {
+	return &orderEventBroker{subscribers: make(map[string]map[chan entity.OrderEvent]struct{})}
+}
+
+// subscribe returns a channel of the events of the user, it is closed by unsubscribe, by close or disconnect of
+// the broker or when the subscriber falls behind.
+func (b *orderEventBroker) subscribe(userID string) (chan entity.OrderEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make(chan entity.OrderEvent, orderEventsSubscriberBuffer)
+	if b.closed {
+		close(events)
+		return events, func() {}
+	}
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan entity.OrderEvent]struct{})
+	}
+	b.subscribers[userID][events] = struct{}{}
+	return events, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(userID, events)
+	}
+}
+
+func (b *orderEventBroker) publish(event entity.OrderEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for events := range b.subscribers[event.UserID] {
+		select {
+		case events <- event:
+		default:
+			// a slow subscriber is dropped rather than blocking the others, it resumes from its last event
+			b.remove(event.UserID, events)
+		}
+	}
+}
+
+func (b *orderEventBroker) remove(userID string, events chan entity.OrderEvent) {
+	if _, ok := b.subscribers[userID][events]; !ok {
+		return
+	}
+	delete(b.subscribers[userID], events)
+	if len(b.subscribers[userID]) == 0 {
+		delete(b.subscribers, userID)
+	}
+	close(events)
+}
+
+func (b *orderEventBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	b.removeAll()
+}
+
+// disconnect closes the current subscribers, so that they resume and replay the events the listener may have
+// missed. Later subscribers are accepted.
+func (b *orderEventBroker) disconnect() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.removeAll()
+}
+
+func (b *orderEventBroker) removeAll() {
+	for userID, subscribers := range b.subscribers {
+		for events := range subscribers {
+			b.remove(userID, events)
+		}
+	}
+}
+
+// StartOrderEventsListener passes the order events of all instances to the subscribed streams until ctx is done,
+// then the streams are closed. A lost connection is reestablished with backoff, the streams opened until the
+// listener is back are closed, so that the clients resume and replay the events sent meanwhile.
+func (g *GophermartServiceImpl) StartOrderEventsListener(ctx context.Context) {
+	go func() {
+		defer g.orderEvents.close()
+		backoff := time.Second
+		for {
+			started := time.Now()
+			err := g.orderStorage.ListenOrderEvents(ctx, g.orderEvents.disconnect, g.orderEvents.publish)
+			if ctx.Err() != nil {
+				return
+			}
+			g.orderEvents.disconnect()
+			serviceLogger.Error(fmt.Errorf("failed to listen to order events: %w", err))
+			if time.Since(started) > orderEventsListenMaxBackoff {
+				backoff = time.Second
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > orderEventsListenMaxBackoff {
+				backoff = orderEventsListenMaxBackoff
+			}
+		}
+	}()
+}
+
+// SubscribeOrderEvents streams the events of the orders of the user until ctx is done. With a lastEventID the
+// stored events after it are sent first, together with the ones observed shortly before it, which may have been
+// committed after it. Thus an event may be delivered again on resume. The channel is closed when the stream ends, e.g. because the client
+// is too slow, so that the client has to resume.
+func (g *GophermartServiceImpl) SubscribeOrderEvents(ctx context.Context, userID string, lastEventID int64) (<-chan entity.OrderEvent, error) {
+	// the subscription starts before the replay, so that no event is lost in between
+	live, unsubscribe := g.orderEvents.subscribe(userID)
+
+	var replay []entity.OrderEvent
+	if lastEventID > 0 {
+		var err error
+		replay, err = g.getOrderEventsAfter(ctx, userID, lastEventID)
+		if err != nil {
+			unsubscribe()
+			return nil, err
+		}
+	}
+
+	events := make(chan entity.OrderEvent)
+	go func() {
+		defer close(events)
+		defer unsubscribe()
+
+		replayed := make(map[int64]bool, len(replay))
+		for _, event := range replay {
+			select {
+			case events <- event:
+				replayed[event.ID] = true
+			case <-ctx.Done():
+				return
+			}
+		}
+		for {
+			select {
+			case event, ok := <-live:
+				if !ok {
+					return
+				}
+				// events are delivered in the order of commits, not of IDs, so only the replayed ones are skipped
+				if replayed[event.ID] {
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (g *GophermartServiceImpl) getOrderEventsAfter(ctx context.Context, userID string, lastEventID int64) ([]entity.OrderEvent, error) {
+	afterID, err := g.orderStorage.GetOrderEventsReplayStart(ctx, userID, lastEventID, orderEventsLateCommitWindow)
+	if err != nil {
+		return nil, fmt.Errorf("error during recieving order events of user %s, cause: %w", userID, err)
+	}
+	events := make([]entity.OrderEvent, 0)
+	for {
+		page, err := g.orderStorage.GetOrderEvents(ctx, userID, afterID, orderEventsReplayPage)
+		if err != nil {
+			return nil, fmt.Errorf("error during recieving order events of user %s, cause: %w", userID, err)
+		}
+		events = append(events, page...)
+		if len(page) < orderEventsReplayPage {
+			return events, nil
+		}
+		afterID = page[len(page)-1].ID
+	}
+}
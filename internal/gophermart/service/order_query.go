@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	maxOrdersPageLimit = 1000
+	defaultOrderSort   = "-" + entity.OrderSortUploadedAt
+)
+
+var orderStatuses = map[string]bool{
+	entity.StatusNew:        true,
+	entity.StatusProcessing: true,
+	entity.StatusInvalid:    true,
+	entity.StatusProcessed:  true,
+	entity.StatusRegistered: true,
+}
+
+// orderCursor is the position after the last order of a page, it is bound to the sort it was issued for.
+type orderCursor struct {
+	Sort       string          `json:"s"`
+	Number     string          `json:"n"`
+	UploadedAt time.Time       `json:"u"`
+	Accrual    decimal.Decimal `json:"a"`
+}
+
+// GetOrdersByUser returns a page of orders of the user, the newest first unless another sort is requested. The
+// next cursor is only set if there are more orders after a limited page.
+func (g *GophermartServiceImpl) GetOrdersByUser(ctx context.Context, id string, query entity.OrderQuery) (entity.OrderPage, error) {
+	filter, err := orderFilter(query)
+	if err != nil {
+		return entity.OrderPage{}, err
+	}
+	if filter.Limit > 0 {
+		// one more order tells whether there is a next page
+		filter.Limit++
+	}
+
+	orders, err := g.orderStorage.GetOrdersByID(ctx, id, filter)
+	if err != nil {
+		return entity.OrderPage{}, fmt.Errorf("error during recieving orders of user: %s, cause %w", id, err)
+	}
+
+	page := entity.OrderPage{Orders: orders}
+	if query.Limit > 0 && len(orders) > query.Limit {
+		page.Orders = orders[:query.Limit]
+		page.NextCursor = encodeOrderCursor(sortOf(query), page.Orders[query.Limit-1])
+	}
+	return page, nil
+}
+
+// GetOrder returns the order of the user together with its status history. Orders of other users are reported
+// as not found.
+func (g *GophermartServiceImpl) GetOrder(ctx context.Context, userID, orderNum string) (entity.OrderDetails, error) {
+	order, err := g.orderStorage.GetOrder(ctx, orderNum)
+	if err != nil {
+		return entity.OrderDetails{}, fmt.Errorf("error during recieving order %s, cause: %w", orderNum, err)
+	}
+	if order.UserID != userID {
+		return entity.OrderDetails{}, storage.ErrItemNotFound
+	}
+	return g.withStatusHistory(ctx, order)
+}
+
+// GetAnyOrder returns the order of any user together with its status history, it is meant for the support.
+func (g *GophermartServiceImpl) GetAnyOrder(ctx context.Context, orderNum string) (entity.OrderDetails, error) {
+	order, err := g.orderStorage.GetOrder(ctx, orderNum)
+	if err != nil {
+		return entity.OrderDetails{}, fmt.Errorf("error during recieving order %s, cause: %w", orderNum, err)
+	}
+	return g.withStatusHistory(ctx, order)
+}
+
+func (g *GophermartServiceImpl) withStatusHistory(ctx context.Context, order entity.Order) (entity.OrderDetails, error) {
+	history, err := g.orderStorage.GetOrderStatusHistory(ctx, order.Number)
+	if err != nil {
+		return entity.OrderDetails{}, fmt.Errorf("error during recieving status history of order %s, cause: %w", order.Number, err)
+	}
+	return entity.OrderDetails{Order: order, History: history}, nil
+}
+
+func orderFilter(query entity.OrderQuery) (entity.OrderFilter, error) {
+	if query.Limit < 0 || query.Limit > maxOrdersPageLimit {
+		return entity.OrderFilter{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrorInvalidOrderQuery, maxOrdersPageLimit)
+	}
+	for _, status := range query.Statuses {
+		if !orderStatuses[status] {
+			return entity.OrderFilter{}, fmt.Errorf("%w: unknown status %s", ErrorInvalidOrderQuery, status)
+		}
+	}
+	if query.UploadedFrom != nil && query.UploadedTo != nil && !query.UploadedFrom.Before(*query.UploadedTo) {
+		return entity.OrderFilter{}, fmt.Errorf("%w: empty uploaded_at range", ErrorInvalidOrderQuery)
+	}
+
+	sort := sortOf(query)
+	sortBy := strings.TrimPrefix(sort, "-")
+	if sortBy != entity.OrderSortUploadedAt && sortBy != entity.OrderSortAccrual {
+		return entity.OrderFilter{}, fmt.Errorf("%w: unknown sort %s", ErrorInvalidOrderQuery, sort)
+	}
+
+	filter := entity.OrderFilter{
+		Statuses:     query.Statuses,
+		UploadedFrom: query.UploadedFrom,
+		UploadedTo:   query.UploadedTo,
+		SortBy:       sortBy,
+		Descending:   strings.HasPrefix(sort, "-"),
+		Limit:        query.Limit,
+	}
+	if query.Cursor != "" {
+		after, err := decodeOrderCursor(query.Cursor, sort)
+		if err != nil {
+			return entity.OrderFilter{}, err
+		}
+		filter.After = &after
+	}
+	return filter, nil
+}
+
+func sortOf(query entity.OrderQuery) string {
+	if query.Sort == "" {
+		return defaultOrderSort
+	}
+	return query.Sort
+}
+
+func encodeOrderCursor(sort string, last entity.Order) string {
+	cursor, _ := json.Marshal(orderCursor{Sort: sort, Number: last.Number, UploadedAt: last.UploadedAt, Accrual: last.Accrual})
+	return base64.RawURLEncoding.EncodeToString(cursor)
+}
+
+func decodeOrderCursor(encoded, sort string) (entity.Order, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return entity.Order{}, fmt.Errorf("%w: malformed cursor", ErrorInvalidOrderQuery)
+	}
+	cursor := orderCursor{}
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.Number == "" {
+		return entity.Order{}, fmt.Errorf("%w: malformed cursor", ErrorInvalidOrderQuery)
+	}
+	if cursor.Sort != sort {
+		return entity.Order{}, fmt.Errorf("%w: cursor is issued for another sort", ErrorInvalidOrderQuery)
+	}
+	return entity.Order{Number: cursor.Number, UploadedAt: cursor.UploadedAt, Accrual: cursor.Accrual}, nil
+}
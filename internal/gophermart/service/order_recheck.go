@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+)
+
+// RecheckOrder restarts the polling of an order of the user with a fresh attempt counter, e.g. after the
+// synchronizer has given up on it. The rechecks of a user are limited within the request limit window.
+func (g *GophermartServiceImpl) RecheckOrder(ctx context.Context, userID, orderNum string) (entity.Order, error) {
+	// every request counts against the limit, whether the order can be rechecked or not
+	if err := g.limitRequests(ctx, entity.RequestLimitKindOrderRecheck, userID, g.maxOrderRechecks); err != nil {
+		return entity.Order{}, err
+	}
+
+	order, err := g.orderStorage.GetOrder(ctx, orderNum)
+	if err != nil {
+		return entity.Order{}, fmt.Errorf("error during recieving order %s, cause: %w", orderNum, err)
+	}
+	if order.UserID != userID {
+		return entity.Order{}, storage.ErrItemNotFound
+	}
+	return g.recheckOrder(ctx, order, userID, false)
+}
+
+// RecheckAnyOrder restarts the polling of an order of any user, it is meant for the support and is not limited.
+func (g *GophermartServiceImpl) RecheckAnyOrder(ctx context.Context, triggeredBy, orderNum string) (entity.Order, error) {
+	order, err := g.orderStorage.GetOrder(ctx, orderNum)
+	if err != nil {
+		return entity.Order{}, fmt.Errorf("error during recieving order %s, cause: %w", orderNum, err)
+	}
+	return g.recheckOrder(ctx, order, triggeredBy, order.UserID != triggeredBy)
+}
+
+func (g *GophermartServiceImpl) recheckOrder(ctx context.Context, order entity.Order, triggeredBy string, byAdmin bool) (entity.Order, error) {
+	if order.Status == entity.StatusProcessed {
+		return entity.Order{}, storage.ErrOrderAlreadyProcessed
+	}
+	err := g.orderStorage.SaveOrderRecheck(ctx, entity.OrderRecheck{
+		OrderNumber: order.Number,
+		TriggeredBy: triggeredBy,
+		ByAdmin:     byAdmin,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		return entity.Order{}, fmt.Errorf("error during rechecking order %s, cause: %w", order.Number, err)
+	}
+
+	g.accrualPoller.wake()
+	order.Attempts = 0
+	return order, nil
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	PasswordHashArgon2id = "argon2id"
+	PasswordHashBcrypt   = "bcrypt"
+
+	argon2idPrefix = "$argon2id$"
+)
+
+var errUnknownPasswordHash = errors.New("unknown password hash format")
+
+// PasswordHasher hashes new passwords with its own algorithm. Argon2idHasher also verifies legacy bcrypt hashes,
+// so stored hashes can be migrated on login.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(encodedHash, password string) (bool, error)
+	NeedsRehash(encodedHash string) bool
+}
+
+type Argon2idParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Argon2idHasher stores hashes in the PHC string format. If a pepper is set, the password is keyed with
+// HMAC-SHA256 before hashing and the k parameter of the hash identifies the pepper. Hashes made without a pepper
+// are still verified once a pepper is set and are rehashed on login, hashes made with another pepper never match.
+type Argon2idHasher struct {
+	params   Argon2idParams
+	pepper   []byte
+	pepperID string
+}
+
+type BcryptHasher struct {
+	cost int
+}
+
+func NewPasswordHasher(algorithm string, argon2Params Argon2idParams, bcryptCost int, pepper string) (PasswordHasher, error) {
+	switch algorithm {
+	case PasswordHashArgon2id:
+		return NewArgon2idHasher(argon2Params, pepper), nil
+	case PasswordHashBcrypt:
+		return NewBcryptHasher(bcryptCost), nil
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm: %s", algorithm)
+	}
+}
+
+func NewArgon2idHasher(params Argon2idParams, pepper string) *Argon2idHasher {
+	h := &Argon2idHasher{params: params}
+	if pepper != "" {
+		h.pepper = []byte(pepper)
+		// the id must not help guessing the pepper, so it is a short MAC rather than a plain hash
+		mac := hmac.New(sha256.New, h.pepper)
+		mac.Write([]byte("password pepper id"))
+		h.pepperID = base64.RawStdEncoding.EncodeToString(mac.Sum(nil)[:6])
+	}
+	return h
+}
+
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{cost: cost}
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey(h.pepperPassword(password, h.pepper), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	paramsPart := fmt.Sprintf("m=%d,t=%d,p=%d", h.params.Memory, h.params.Iterations, h.params.Parallelism)
+	if h.pepperID != "" {
+		paramsPart += ",k=" + h.pepperID
+	}
+	return fmt.Sprintf("%sv=%d$%s$%s$%s", argon2idPrefix, argon2.Version, paramsPart,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) Verify(encodedHash, password string) (bool, error) {
+	if !strings.HasPrefix(encodedHash, argon2idPrefix) {
+		return verifyLegacyHash(encodedHash, password)
+	}
+
+	params, pepperID, salt, key, err := decodeArgon2idHash(encodedHash)
+	if err != nil {
+		return false, err
+	}
+	verify := func(pepper []byte) bool {
+		actual := argon2.IDKey(h.pepperPassword(password, pepper), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		return subtle.ConstantTimeCompare(key, actual) == 1
+	}
+
+	if pepperID != "" {
+		return pepperID == h.pepperID && verify(h.pepper), nil
+	}
+	if verify(nil) {
+		return true, nil
+	}
+	// hashes made with a pepper before the pepper was recorded in the hash
+	return h.pepper != nil && verify(h.pepper), nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(encodedHash string) bool {
+	if !strings.HasPrefix(encodedHash, argon2idPrefix) {
+		return true
+	}
+	params, pepperID, salt, _, err := decodeArgon2idHash(encodedHash)
+	if err != nil {
+		return true
+	}
+	return pepperID != h.pepperID ||
+		params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Parallelism != h.params.Parallelism ||
+		params.KeyLength != h.params.KeyLength ||
+		uint32(len(salt)) != h.params.SaltLength
+}
+
+func (h *Argon2idHasher) pepperPassword(password string, pepper []byte) []byte {
+	if pepper == nil {
+		return []byte(password)
+	}
+	mac := hmac.New(sha256.New, pepper)
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedPassword), nil
+}
+
+func (h *BcryptHasher) Verify(encodedHash, password string) (bool, error) {
+	return verifyLegacyHash(encodedHash, password)
+}
+
+func (h *BcryptHasher) NeedsRehash(encodedHash string) bool {
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	return err != nil || cost != h.cost
+}
+
+// verifyLegacyHash verifies hashes of algorithms that are not used for new passwords anymore, argon2id hashes
+// can only be verified by Argon2idHasher because of the pepper. Unknown hashes never match.
+func verifyLegacyHash(encodedHash, password string) (bool, error) {
+	if _, err := bcrypt.Cost([]byte(encodedHash)); err != nil {
+		return false, nil
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// decodeArgon2idHash returns the parameters, the pepper id, which is empty for hashes made without a pepper, the
+// salt and the key of the hash.
+func decodeArgon2idHash(encodedHash string) (Argon2idParams, string, []byte, []byte, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 {
+		return Argon2idParams{}, "", nil, nil, errUnknownPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2idParams{}, "", nil, nil, errUnknownPasswordHash
+	}
+
+	paramsPart, pepperID, peppered := strings.Cut(parts[3], ",k=")
+	if peppered && pepperID == "" {
+		return Argon2idParams{}, "", nil, nil, errUnknownPasswordHash
+	}
+	var params Argon2idParams
+	_, err := fmt.Sscanf(paramsPart, "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return Argon2idParams{}, "", nil, nil, errUnknownPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2idParams{}, "", nil, nil, errUnknownPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2idParams{}, "", nil, nil, errUnknownPasswordHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, pepperID, salt, key, nil
+}
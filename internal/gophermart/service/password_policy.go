@@ -0,0 +1,81 @@
+package service
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsList string
+
+var commonPasswords = loadCommonPasswords(commonPasswordsList)
+
+type PasswordPolicy struct {
+	MinLength      int
+	MinCharClasses int
+}
+
+type WeakPasswordError struct {
+	Reason string
+}
+
+func (e *WeakPasswordError) Error() string {
+	return fmt.Sprintf("password does not satisfy the password policy: %s", e.Reason)
+}
+
+func (e *WeakPasswordError) Is(target error) bool {
+	return target == ErrorWeakPassword
+}
+
+// Validate checks the password length, the number of used character classes (lower case, upper case, digits and
+// other symbols) and rejects passwords from the embedded list of common passwords.
+func (p PasswordPolicy) Validate(login, password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return &WeakPasswordError{Reason: fmt.Sprintf("must be at least %d characters long", p.MinLength)}
+	}
+
+	if classes := charClasses(password); classes < p.MinCharClasses {
+		return &WeakPasswordError{Reason: fmt.Sprintf(
+			"must contain at least %d of: lower case letters, upper case letters, digits, symbols", p.MinCharClasses)}
+	}
+
+	lowered := strings.ToLower(password)
+	if _, ok := commonPasswords[lowered]; ok {
+		return &WeakPasswordError{Reason: "is too common"}
+	}
+	if login != "" && lowered == strings.ToLower(login) {
+		return &WeakPasswordError{Reason: "must not match the login"}
+	}
+	return nil
+}
+
+func charClasses(password string) int {
+	var lower, upper, digit, other int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
+
+func loadCommonPasswords(list string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			passwords[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	return passwords
+}
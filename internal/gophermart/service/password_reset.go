@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	loyaltyHTTPClient "github.com/apolsh/yapr-gophermart/internal/gophermart/client"
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+)
+
+const passwordResetNotifyTimeout = 30 * time.Second
+
+// PasswordResetNotifier delivers a password reset token to the user, e.g. as a link in a mail.
+type PasswordResetNotifier interface {
+	NotifyPasswordReset(ctx context.Context, user entity.User, resetToken string) error
+}
+
+// PasswordResetOptions configures the password reset. Without a notifier the reset is disabled.
+type PasswordResetOptions struct {
+	Notifier PasswordResetNotifier
+	TTL      time.Duration
+	// MaxRequestsPerLogin limits the reset requests of a login within the request limit window
+	MaxRequestsPerLogin int
+}
+
+// MailPasswordResetNotifier mails a reset link to the login of the user, so it requires logins to be verified email
+// addresses.
+type MailPasswordResetNotifier struct {
+	mailer  loyaltyHTTPClient.Mailer
+	linkURL string
+}
+
+func NewMailPasswordResetNotifier(mailer loyaltyHTTPClient.Mailer, linkURL string) *MailPasswordResetNotifier {
+	return &MailPasswordResetNotifier{mailer: mailer, linkURL: linkURL}
+}
+
+func (n *MailPasswordResetNotifier) NotifyPasswordReset(ctx context.Context, user entity.User, resetToken string) error {
+	link, err := url.Parse(n.linkURL)
+	if err != nil {
+		return fmt.Errorf("error during building password reset link, cause: %w", err)
+	}
+	query := link.Query()
+	query.Set("token", resetToken)
+	link.RawQuery = query.Encode()
+
+	return n.mailer.Send(ctx, loyaltyHTTPClient.Mail{
+		To:      user.Login,
+		Subject: "Reset your Gophermart password",
+		Body: fmt.Sprintf("Follow the link to set a new password:\n\n%s\n\nIf you did not request a password reset, ignore this mail.\n",
+			link),
+	})
+}
+
+// RequestPasswordReset sends a single-use reset token to the user. The result does not depend on the login
+// existing, only the rate limit of the login is reported.
+func (g *GophermartServiceImpl) RequestPasswordReset(ctx context.Context, login string) error {
+	if g.passwordReset.Notifier == nil {
+		return ErrorPasswordResetNotConfigured
+	}
+	normalized := normalizeLogin(login)
+	if normalized == "" {
+		return ErrorEmptyValue
+	}
+
+	// every request counts against the limit, whether the login exists or not
+	if err := g.limitRequests(ctx, entity.RequestLimitKindPasswordReset, normalized, g.passwordReset.MaxRequestsPerLogin); err != nil {
+		return err
+	}
+
+	user, err := g.getUserByLogin(ctx, login)
+	if err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			return nil
+		}
+		return fmt.Errorf("error during recieving user: %s, cause: %w", login, err)
+	}
+	// the login is not known to belong to the user, the token is not sent to whoever owns it
+	if !user.EmailVerified || validateEmailLogin(user.Login) != nil {
+		return nil
+	}
+
+	// the token is issued in the background, so the response time does not tell whether the login exists
+	go g.issuePasswordResetToken(user)
+	return nil
+}
+
+func (g *GophermartServiceImpl) issuePasswordResetToken(user entity.User) {
+	ctx, cancel := context.WithTimeout(context.Background(), passwordResetNotifyTimeout)
+	defer cancel()
+
+	resetToken, err := generateRandomToken(32)
+	if err != nil {
+		serviceLogger.Error(fmt.Errorf("error during generating password reset token for user %s, cause: %w", user.ID, err))
+		return
+	}
+	now := time.Now()
+	err = g.passwordResetStorage.SavePasswordResetToken(ctx, entity.PasswordResetToken{
+		TokenHash: hashToken(resetToken),
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(g.passwordReset.TTL),
+	})
+	if err != nil {
+		serviceLogger.Error(fmt.Errorf("error during saving password reset token of user %s, cause: %w", user.ID, err))
+		return
+	}
+	if err := g.passwordReset.Notifier.NotifyPasswordReset(ctx, user, resetToken); err != nil {
+		serviceLogger.Error(fmt.Errorf("error during sending password reset token to user %s, cause: %w", user.ID, err))
+	}
+}
+
+// ResetPassword sets a new password using a reset token. The token and all other reset tokens of the user are
+// used up, existing tokens and sessions of the user are revoked.
+func (g *GophermartServiceImpl) ResetPassword(ctx context.Context, resetToken, newPassword string) error {
+	if resetToken == "" || newPassword == "" {
+		return ErrorEmptyValue
+	}
+
+	tokenHash := hashToken(resetToken)
+	token, err := g.passwordResetStorage.GetPasswordResetToken(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			return ErrorInvalidPasswordResetToken
+		}
+		return fmt.Errorf("error during recieving password reset token, cause: %w", err)
+	}
+	if token.UsedAt != nil || !time.Now().Before(token.ExpiresAt) {
+		return ErrorInvalidPasswordResetToken
+	}
+
+	user, err := g.userStorage.GetByID(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			return ErrorInvalidPasswordResetToken
+		}
+		return fmt.Errorf("error during recieving user: %s, cause: %w", token.UserID, err)
+	}
+	if err := g.passwordPolicy.Validate(user.Login, newPassword); err != nil {
+		return err
+	}
+	hashedPassword, err := g.passwordHasher.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("error during generating hashed password for user %s, cause: %w", user.ID, err)
+	}
+
+	err = g.passwordResetStorage.ResetPassword(ctx, tokenHash, hashedPassword)
+	if err != nil {
+		if errors.Is(err, storage.ErrPasswordResetTokenUsed) {
+			return ErrorInvalidPasswordResetToken
+		}
+		return fmt.Errorf("error during resetting password of user %s, cause: %w", user.ID, err)
+	}
+	if err := g.revokeUserTokens(ctx, user.ID); err != nil {
+		return err
+	}
+	// the owner has proven access to the account, failures of someone guessing the old password are forgotten
+	if err := g.resetFailedLogins(ctx, user.Login); err != nil {
+		return err
+	}
+	serviceLogger.Info("password of user %s is reset", user.ID)
+	return nil
+}
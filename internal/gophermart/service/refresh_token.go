@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+)
+
+const refreshTokenLength = 32
+
+// RefreshTokens exchanges a refresh token for a new token pair. Every refresh token can be used only once,
+// presenting an already used token revokes the whole family of tokens issued for that login.
+func (g *GophermartServiceImpl) RefreshTokens(ctx context.Context, refreshToken string) (entity.TokenPair, error) {
+	if refreshToken == "" {
+		return entity.TokenPair{}, ErrorEmptyValue
+	}
+	tokenHash := hashToken(refreshToken)
+
+	stored, err := g.refreshTokenStorage.GetRefreshToken(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			return entity.TokenPair{}, ErrorInvalidRefreshToken
+		}
+		return entity.TokenPair{}, fmt.Errorf("error during recieving refresh token, cause: %w", err)
+	}
+
+	if stored.Revoked || time.Now().After(stored.ExpiresAt) {
+		return entity.TokenPair{}, ErrorInvalidRefreshToken
+	}
+	if stored.UsedAt != nil {
+		return entity.TokenPair{}, g.revokeReusedFamily(ctx, stored)
+	}
+
+	err = g.refreshTokenStorage.MarkRefreshTokenUsed(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrRefreshTokenAlreadyUsed) {
+			return entity.TokenPair{}, g.revokeReusedFamily(ctx, stored)
+		}
+		return entity.TokenPair{}, fmt.Errorf("error during marking refresh token as used, cause: %w", err)
+	}
+
+	// roles may have changed since the login, so they are read again
+	user, err := g.userStorage.GetByID(ctx, stored.UserID)
+	if err != nil {
+		return entity.TokenPair{}, fmt.Errorf("error during recieving user: %s, cause: %w", stored.UserID, err)
+	}
+	return g.issueTokens(ctx, user, stored.FamilyID)
+}
+
+func (g *GophermartServiceImpl) revokeReusedFamily(ctx context.Context, token entity.RefreshToken) error {
+	serviceLogger.Warn("refresh token reuse detected for user %s, revoking token family %s", token.UserID, token.FamilyID)
+	if err := g.revokeSessionIfExists(ctx, token.UserID, token.FamilyID); err != nil {
+		return err
+	}
+	return ErrorRefreshTokenReused
+}
+
+// issueTokens generates an access token and a new refresh token of the session. The refresh token family of
+// a session has the session id.
+func (g *GophermartServiceImpl) issueTokens(ctx context.Context, user entity.User, sessionID string) (entity.TokenPair, error) {
+	userID := user.ID
+	accessToken, err := g.generateToken(user, sessionID)
+	if err != nil {
+		return entity.TokenPair{}, fmt.Errorf("error during generating access token for user %s, cause: %w", userID, err)
+	}
+
+	refreshToken, err := generateRandomToken(refreshTokenLength)
+	if err != nil {
+		return entity.TokenPair{}, fmt.Errorf("error during generating refresh token for user %s, cause: %w", userID, err)
+	}
+	err = g.refreshTokenStorage.SaveRefreshToken(ctx, entity.RefreshToken{
+		TokenHash: hashToken(refreshToken),
+		FamilyID:  sessionID,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(g.refreshTokenTTL),
+	})
+	if err != nil {
+		return entity.TokenPair{}, fmt.Errorf("error during saving refresh token for user %s, cause: %w", userID, err)
+	}
+
+	return entity.TokenPair{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+func generateRandomToken(length int) (string, error) {
+	b := make([]byte, length)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
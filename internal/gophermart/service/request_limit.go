@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RateLimitedError is returned when a user makes more requests of a kind than allowed within the request limit
+// window. Unlike the lockouts of failed logins, it only lasts until the window is over.
+type RateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("too many requests, retry after %s", e.RetryAfter)
+}
+
+func (e *RateLimitedError) Is(target error) bool {
+	return target == ErrorRateLimited
+}
+
+// limitRequests counts the request of the key, every request counts whether it succeeds or not. A max below 1
+// means no limit.
+func (g *GophermartServiceImpl) limitRequests(ctx context.Context, kind, key string, max int) error {
+	if max <= 0 {
+		return nil
+	}
+	now := time.Now()
+	count, err := g.requestLimitStorage.RegisterRequest(ctx, kind, key, now, now.Add(-g.requestLimitWindow))
+	if err != nil {
+		return fmt.Errorf("error during registering %s request, cause: %w", kind, err)
+	}
+	if count.Requests > max {
+		return &RateLimitedError{RetryAfter: count.WindowStartedAt.Add(g.requestLimitWindow).Sub(now)}
+	}
+	return nil
+}
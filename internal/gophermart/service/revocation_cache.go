@@ -0,0 +1,141 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	revocationCacheTTL           = 30 * time.Second
+	revocationCachePurgeInterval = 1 * time.Minute
+)
+
+type revocationCacheEntry struct {
+	revoked   bool
+	expiresAt time.Time
+}
+
+type userRevocationCacheEntry struct {
+	revokedBefore time.Time
+	expiresAt     time.Time
+}
+
+// revocationCache keeps results of revocation list lookups in memory. Revoked tokens are cached until the token
+// itself expires, tokens that are not revoked, sessions and per user revocations are cached for revocationCacheTTL
+// only, so revocations made by other instances are picked up with a bounded delay.
+type revocationCache struct {
+	mu        sync.Mutex
+	entries   map[string]revocationCacheEntry
+	sessions  map[string]revocationCacheEntry
+	users     map[string]userRevocationCacheEntry
+	lastPurge time.Time
+}
+
+func newRevocationCache() *revocationCache {
+	return &revocationCache{
+		entries:   make(map[string]revocationCacheEntry),
+		sessions:  make(map[string]revocationCacheEntry),
+		users:     make(map[string]userRevocationCacheEntry),
+		lastPurge: time.Now(),
+	}
+}
+
+func (c *revocationCache) getUser(userID string) (revokedBefore time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.users[userID]
+	if !ok {
+		return time.Time{}, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.users, userID)
+		return time.Time{}, false
+	}
+	return entry.revokedBefore, true
+}
+
+func (c *revocationCache) setUser(userID string, revokedBefore time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.purgeExpired()
+	c.users[userID] = userRevocationCacheEntry{revokedBefore: revokedBefore, expiresAt: time.Now().Add(revocationCacheTTL)}
+}
+
+func (c *revocationCache) get(jti string) (revoked bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return getEntry(c.entries, jti)
+}
+
+func (c *revocationCache) getSession(sessionID string) (revoked bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return getEntry(c.sessions, sessionID)
+}
+
+func (c *revocationCache) setSession(sessionID string, revoked bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.purgeExpired()
+	c.sessions[sessionID] = revocationCacheEntry{revoked: revoked, expiresAt: time.Now().Add(revocationCacheTTL)}
+}
+
+func getEntry(entries map[string]revocationCacheEntry, key string) (revoked bool, ok bool) {
+	entry, ok := entries[key]
+	if !ok {
+		return false, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(entries, key)
+		return false, false
+	}
+	return entry.revoked, true
+}
+
+func (c *revocationCache) setRevoked(jti string, tokenExpiresAt time.Time) {
+	c.set(jti, revocationCacheEntry{revoked: true, expiresAt: tokenExpiresAt})
+}
+
+func (c *revocationCache) setActive(jti string, tokenExpiresAt time.Time) {
+	expiresAt := time.Now().Add(revocationCacheTTL)
+	if tokenExpiresAt.Before(expiresAt) {
+		expiresAt = tokenExpiresAt
+	}
+	c.set(jti, revocationCacheEntry{revoked: false, expiresAt: expiresAt})
+}
+
+func (c *revocationCache) set(jti string, entry revocationCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.purgeExpired()
+	c.entries[jti] = entry
+}
+
+func (c *revocationCache) purgeExpired() {
+	now := time.Now()
+	if now.Sub(c.lastPurge) <= revocationCachePurgeInterval {
+		return
+	}
+	for key, e := range c.entries {
+		if now.After(e.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	for key, e := range c.sessions {
+		if now.After(e.expiresAt) {
+			delete(c.sessions, key)
+		}
+	}
+	for key, e := range c.users {
+		if now.After(e.expiresAt) {
+			delete(c.users, key)
+		}
+	}
+	c.lastPurge = now
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+)
+
+var knownRoles = map[string]bool{
+	entity.RoleUser:    true,
+	entity.RoleSupport: true,
+	entity.RoleAdmin:   true,
+}
+
+// SetUserRoles replaces the roles of the user. Every user keeps the user role. Tokens issued before the change
+// are revoked, so the new roles take effect immediately.
+func (g *GophermartServiceImpl) SetUserRoles(ctx context.Context, userID string, roles []string) ([]string, error) {
+	roles, err := normalizeRoles(roles)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.userStorage.UpdateRoles(ctx, userID, roles)
+	if err != nil {
+		return nil, fmt.Errorf("error during updating roles of user %s, cause: %w", userID, err)
+	}
+	if err := g.revokeUserTokens(ctx, userID); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// BootstrapAdmin makes sure the user with the given login exists and has the admin role. The password is only used
+// to create a missing user, the password of an existing user is left as is.
+func (g *GophermartServiceImpl) BootstrapAdmin(ctx context.Context, login, password string) error {
+	login = normalizeLogin(login)
+	if login == "" {
+		return ErrorEmptyValue
+	}
+
+	user, err := g.userStorage.Get(ctx, login)
+	if err != nil && !errors.Is(err, storage.ErrItemNotFound) {
+		return fmt.Errorf("error during recieving user: %s, cause: %w", login, err)
+	}
+	if errors.Is(err, storage.ErrItemNotFound) {
+		if password == "" {
+			return fmt.Errorf("admin %s does not exist and no password is given: %w", login, ErrorEmptyValue)
+		}
+		if err := g.passwordPolicy.Validate(login, password); err != nil {
+			return err
+		}
+		hashedPassword, err := g.passwordHasher.Hash(password)
+		if err != nil {
+			return fmt.Errorf("error during generating hashed password for user %s, cause: %w", login, err)
+		}
+		user.ID, err = g.userStorage.NewUser(ctx, login, hashedPassword)
+		if err != nil {
+			return fmt.Errorf("error during saving new user: %s, cause: %w", login, err)
+		}
+		// the login is given by the operator, there is nothing to confirm in the email login mode
+		if err := g.userStorage.MarkEmailVerified(ctx, user.ID); err != nil {
+			return fmt.Errorf("error during marking email of user %s as verified, cause: %w", login, err)
+		}
+		serviceLogger.Info("created admin user %s", login)
+	}
+
+	if user.HasRole(entity.RoleAdmin) {
+		return nil
+	}
+	roles, err := normalizeRoles(append(user.Roles, entity.RoleAdmin))
+	if err != nil {
+		return err
+	}
+	err = g.userStorage.UpdateRoles(ctx, user.ID, roles)
+	if err != nil {
+		return fmt.Errorf("error during updating roles of user %s, cause: %w", user.ID, err)
+	}
+	serviceLogger.Info("granted admin role to user %s", login)
+	return nil
+}
+
+func normalizeRoles(roles []string) ([]string, error) {
+	unique := map[string]bool{entity.RoleUser: true}
+	for _, role := range roles {
+		if !knownRoles[role] {
+			return nil, fmt.Errorf("%w: %s", ErrorUnknownRole, role)
+		}
+		unique[role] = true
+	}
+
+	normalized := make([]string, 0, len(unique))
+	for role := range unique {
+		normalized = append(normalized, role)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
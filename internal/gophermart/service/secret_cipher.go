@@ -0,0 +1,56 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// SecretCipher encrypts secrets stored in the database with AES-256-GCM. The additional data binds a ciphertext
+// to its row, so it can't be copied to another user.
+type SecretCipher struct {
+	aead cipher.AEAD
+}
+
+// NewSecretCipher creates a cipher from a base64 encoded 32 bytes key.
+func NewSecretCipher(encodedKey string) (*SecretCipher, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("error during decoding secret encryption key, cause: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, errors.New("secret encryption key must be 32 bytes long")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretCipher{aead: aead}, nil
+}
+
+func (c *SecretCipher) Encrypt(plaintext, additionalData string) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(additionalData)), nil
+}
+
+func (c *SecretCipher) Decrypt(ciphertext []byte, additionalData string) (string, error) {
+	if len(ciphertext) < c.aead.NonceSize() {
+		return "", errors.New("ciphertext is too short")
+	}
+	nonce, sealed := ciphertext[:c.aead.NonceSize()], ciphertext[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, []byte(additionalData))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
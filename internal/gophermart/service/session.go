@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+)
+
+const maxUserAgentLength = 512
+
+// GetSessions returns the active sessions of the user, the session of the caller is marked as current.
+func (g *GophermartServiceImpl) GetSessions(ctx context.Context, userID, currentSessionID string) ([]entity.Session, error) {
+	sessions, err := g.sessionStorage.GetActiveSessions(ctx, userID, time.Now().Add(-g.refreshTokenTTL))
+	if err != nil {
+		return nil, fmt.Errorf("error during recieving sessions of user %s, cause: %w", userID, err)
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession logs out the session: its access tokens are rejected and its refresh tokens can't be used anymore.
+func (g *GophermartServiceImpl) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	err := g.sessionStorage.RevokeSession(ctx, userID, sessionID)
+	if err != nil {
+		return fmt.Errorf("error during revoking session %s, cause: %w", sessionID, err)
+	}
+	return g.revokeSessionTokens(ctx, sessionID)
+}
+
+func (g *GophermartServiceImpl) createSession(ctx context.Context, userID string, client entity.ClientInfo) (string, error) {
+	id, err := generateRandomToken(16)
+	if err != nil {
+		return "", fmt.Errorf("error during generating session id for user %s, cause: %w", userID, err)
+	}
+	userAgent := client.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	now := time.Now()
+	err = g.sessionStorage.CreateSession(ctx, entity.Session{
+		ID:         id,
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error during creating session for user %s, cause: %w", userID, err)
+	}
+	return id, nil
+}
+
+// startSession creates a session for a new login and issues its first token pair.
+func (g *GophermartServiceImpl) startSession(ctx context.Context, user entity.User, client entity.ClientInfo) (entity.TokenPair, error) {
+	sessionID, err := g.createSession(ctx, user.ID, client)
+	if err != nil {
+		return entity.TokenPair{}, err
+	}
+	return g.issueTokens(ctx, user, sessionID)
+}
+
+// isSessionRevoked also records the session as seen, at most once per revocationCacheTTL. Tokens issued before
+// sessions were introduced have no session and are not checked.
+func (g *GophermartServiceImpl) isSessionRevoked(ctx context.Context, claims *jwtTokenClaims) (bool, error) {
+	if claims.SessionID == "" {
+		return false, nil
+	}
+	if revoked, ok := g.revocationCache.getSession(claims.SessionID); ok {
+		return revoked, nil
+	}
+
+	revoked, err := g.sessionStorage.TouchSession(ctx, claims.SessionID, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("error during checking session revocation, cause: %w", err)
+	}
+	g.revocationCache.setSession(claims.SessionID, revoked)
+	return revoked, nil
+}
+
+// revokeSessionIfExists is used where the session may already be revoked or, for refresh token families created
+// before sessions were introduced, may not exist. The refresh tokens are revoked in any case.
+func (g *GophermartServiceImpl) revokeSessionIfExists(ctx context.Context, userID, sessionID string) error {
+	err := g.sessionStorage.RevokeSession(ctx, userID, sessionID)
+	if err != nil && !errors.Is(err, storage.ErrItemNotFound) {
+		return fmt.Errorf("error during revoking session %s, cause: %w", sessionID, err)
+	}
+	return g.revokeSessionTokens(ctx, sessionID)
+}
+
+func (g *GophermartServiceImpl) revokeSessionTokens(ctx context.Context, sessionID string) error {
+	g.revocationCache.setSession(sessionID, true)
+
+	err := g.refreshTokenStorage.RevokeRefreshTokenFamily(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("error during revoking refresh token family %s, cause: %w", sessionID, err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type tokenKey struct {
+	id         string
+	method     jwt.SigningMethod
+	privateKey crypto.PrivateKey
+	publicKey  crypto.PublicKey
+	jwk        entity.JWK
+}
+
+// TokenKeySet holds the keys used to sign and verify access tokens. The first configured key signs new tokens,
+// the remaining ones are only used for verification, so keys can be rotated without invalidating issued tokens.
+// An HMAC secret, if set, is still accepted for tokens issued without a key id.
+type TokenKeySet struct {
+	signingKey *tokenKey
+	keys       []*tokenKey
+	hmacSecret []byte
+}
+
+// NewTokenKeySet loads the keys from the files. Without any key or HMAC secret it fails, unless an ephemeral key is
+// allowed, which is meant for development only: the tokens signed with it don't survive a restart and are not
+// accepted by other instances.
+func NewTokenKeySet(keyFiles []string, hmacSecret string, allowEphemeralKey bool) (*TokenKeySet, error) {
+	keySet := &TokenKeySet{}
+	if hmacSecret != "" {
+		keySet.hmacSecret = []byte(hmacSecret)
+	}
+
+	for i, file := range keyFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error during reading token key file %s, cause: %w", file, err)
+		}
+		key, err := parseTokenKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("error during parsing token key file %s, cause: %w", file, err)
+		}
+		if i == 0 {
+			if key.privateKey == nil {
+				return nil, fmt.Errorf("token signing key file %s does not contain a private key", file)
+			}
+			keySet.signingKey = key
+		}
+		keySet.keys = append(keySet.keys, key)
+	}
+
+	if keySet.signingKey == nil && keySet.hmacSecret == nil {
+		if !allowEphemeralKey {
+			return nil, errors.New("no token signing key or secret is configured")
+		}
+		serviceLogger.Warn("no token signing keys configured, using an ephemeral Ed25519 key: issued tokens will not survive a restart")
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("error during generating ephemeral token key, cause: %w", err)
+		}
+		keySet.signingKey = newTokenKey(jwt.SigningMethodEdDSA, privateKey, publicKey)
+		keySet.keys = append(keySet.keys, keySet.signingKey)
+	}
+
+	return keySet, nil
+}
+
+func (k *TokenKeySet) sign(claims jwt.Claims) (string, error) {
+	if k.signingKey == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.hmacSecret)
+	}
+	token := jwt.NewWithClaims(k.signingKey.method, claims)
+	token.Header["kid"] = k.signingKey.id
+	return token.SignedString(k.signingKey.privateKey)
+}
+
+func (k *TokenKeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok && k.hmacSecret != nil {
+			return k.hmacSecret, nil
+		}
+		return nil, errors.New("token has no key id")
+	}
+
+	for _, key := range k.keys {
+		if key.id == kid {
+			if token.Method.Alg() != key.method.Alg() {
+				return nil, errors.New("invalid signing method")
+			}
+			return key.publicKey, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown token key id %s", kid)
+}
+
+// JWKS returns the public keys as a JSON Web Key Set, see RFC 7517.
+func (k *TokenKeySet) JWKS() entity.JWKS {
+	jwks := entity.JWKS{Keys: make([]entity.JWK, 0, len(k.keys))}
+	for _, key := range k.keys {
+		jwks.Keys = append(jwks.Keys, key.jwk)
+	}
+	return jwks
+}
+
+func parseTokenKey(data []byte) (*tokenKey, error) {
+	if privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return newTokenKey(jwt.SigningMethodRS256, privateKey, &privateKey.PublicKey), nil
+	}
+	if privateKey, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		edPrivateKey := privateKey.(ed25519.PrivateKey)
+		return newTokenKey(jwt.SigningMethodEdDSA, edPrivateKey, edPrivateKey.Public()), nil
+	}
+	if publicKey, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return newTokenKey(jwt.SigningMethodRS256, nil, publicKey), nil
+	}
+	if publicKey, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return newTokenKey(jwt.SigningMethodEdDSA, nil, publicKey), nil
+	}
+	return nil, errors.New("unsupported key, expected an RSA or Ed25519 key in PEM format")
+}
+
+func newTokenKey(method jwt.SigningMethod, privateKey crypto.PrivateKey, publicKey crypto.PublicKey) *tokenKey {
+	jwk := entity.JWK{Use: "sig", Algorithm: method.Alg()}
+	var thumbprintInput string
+
+	switch pub := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		thumbprintInput = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, jwk.E, jwk.N)
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		thumbprintInput = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, jwk.X)
+	}
+
+	// key id is the JWK thumbprint, see RFC 7638
+	thumbprint := sha256.Sum256([]byte(thumbprintInput))
+	jwk.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+
+	return &tokenKey{
+		id:         jwk.KeyID,
+		method:     method,
+		privateKey: privateKey,
+		publicKey:  publicKey,
+		jwk:        jwk,
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+)
+
+// Logout revokes the access token until it expires and its session. Tokens issued before sessions were introduced
+// have no session, for them the family of the refresh token is revoked if it is given.
+func (g *GophermartServiceImpl) Logout(ctx context.Context, accessToken, refreshToken string) error {
+	claims, err := g.parseClaims(accessToken)
+	if err != nil {
+		return err
+	}
+
+	err = g.revokedTokenStorage.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return fmt.Errorf("error during revoking token of user %s, cause: %w", claims.UserID, err)
+	}
+	g.revocationCache.setRevoked(claims.ID, claims.ExpiresAt.Time)
+
+	if claims.SessionID != "" {
+		return g.revokeSessionIfExists(ctx, claims.UserID, claims.SessionID)
+	}
+	if refreshToken == "" {
+		return nil
+	}
+	stored, err := g.refreshTokenStorage.GetRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			return nil
+		}
+		return fmt.Errorf("error during recieving refresh token of user %s, cause: %w", claims.UserID, err)
+	}
+	if stored.UserID != claims.UserID {
+		return nil
+	}
+	err = g.refreshTokenStorage.RevokeRefreshTokenFamily(ctx, stored.FamilyID)
+	if err != nil {
+		return fmt.Errorf("error during revoking refresh token family %s, cause: %w", stored.FamilyID, err)
+	}
+	return nil
+}
+
+func (g *GophermartServiceImpl) isTokenRevoked(ctx context.Context, claims *jwtTokenClaims) (bool, error) {
+	if revoked, ok := g.revocationCache.get(claims.ID); ok {
+		return revoked, nil
+	}
+
+	revoked, err := g.revokedTokenStorage.IsTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return false, fmt.Errorf("error during checking token revocation, cause: %w", err)
+	}
+	if revoked {
+		g.revocationCache.setRevoked(claims.ID, claims.ExpiresAt.Time)
+	} else {
+		g.revocationCache.setActive(claims.ID, claims.ExpiresAt.Time)
+	}
+	return revoked, nil
+}
+
+// revokeUserTokens invalidates every access and refresh token of the user issued before now.
+func (g *GophermartServiceImpl) revokeUserTokens(ctx context.Context, userID string) error {
+	revokedBefore := time.Now().Truncate(time.Second)
+	err := g.revokedTokenStorage.RevokeUserTokens(ctx, userID, revokedBefore)
+	if err != nil {
+		return fmt.Errorf("error during revoking tokens of user %s, cause: %w", userID, err)
+	}
+	g.revocationCache.setUser(userID, revokedBefore)
+
+	err = g.refreshTokenStorage.RevokeUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("error during revoking refresh tokens of user %s, cause: %w", userID, err)
+	}
+
+	err = g.sessionStorage.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("error during revoking sessions of user %s, cause: %w", userID, err)
+	}
+	return nil
+}
+
+func (g *GophermartServiceImpl) isRevokedForUser(ctx context.Context, claims *jwtTokenClaims) (bool, error) {
+	revokedBefore, ok := g.revocationCache.getUser(claims.UserID)
+	if !ok {
+		var err error
+		revokedBefore, err = g.revokedTokenStorage.GetUserTokensRevokedBefore(ctx, claims.UserID)
+		if err != nil {
+			return false, fmt.Errorf("error during checking user token revocation, cause: %w", err)
+		}
+		g.revocationCache.setUser(claims.UserID, revokedBefore)
+	}
+	return claims.IssuedAt == nil || claims.IssuedAt.Time.Before(revokedBefore), nil
+}
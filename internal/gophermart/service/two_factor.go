@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	recoveryCodeCount     = 10
+	recoveryCodeLength    = 10
+	challengeTokenPurpose = "2fa"
+)
+
+// TwoFactorOptions configures TOTP two-factor authentication. Without a Cipher the secrets can't be stored,
+// so users can't enroll.
+type TwoFactorOptions struct {
+	Issuer       string
+	ChallengeTTL time.Duration
+	Cipher       *SecretCipher
+}
+
+// TwoFactorRequiredError is returned by LoginUser when the password is correct but the user has 2FA enabled.
+// The challenge token has to be exchanged for tokens together with a TOTP or recovery code.
+type TwoFactorRequiredError struct {
+	ChallengeToken string
+}
+
+func (e *TwoFactorRequiredError) Error() string {
+	return ErrorTwoFactorRequired.Error()
+}
+
+func (e *TwoFactorRequiredError) Is(target error) bool {
+	return target == ErrorTwoFactorRequired
+}
+
+// EnrollTOTP generates a new TOTP secret for the user. It is not used for logins until it is verified.
+func (g *GophermartServiceImpl) EnrollTOTP(ctx context.Context, userID string) (entity.TOTPEnrollment, error) {
+	if g.twoFactor.Cipher == nil {
+		return entity.TOTPEnrollment{}, ErrorTwoFactorNotConfigured
+	}
+
+	user, err := g.userStorage.GetByID(ctx, userID)
+	if err != nil {
+		return entity.TOTPEnrollment{}, fmt.Errorf("error during recieving user: %s, cause: %w", userID, err)
+	}
+	if user.TOTPEnabled {
+		return entity.TOTPEnrollment{}, ErrorTwoFactorAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return entity.TOTPEnrollment{}, fmt.Errorf("error during generating totp secret for user %s, cause: %w", userID, err)
+	}
+	encryptedSecret, err := g.twoFactor.Cipher.Encrypt(secret, userID)
+	if err != nil {
+		return entity.TOTPEnrollment{}, fmt.Errorf("error during encrypting totp secret of user %s, cause: %w", userID, err)
+	}
+	err = g.userStorage.SetTOTPSecret(ctx, userID, encryptedSecret)
+	if err != nil {
+		return entity.TOTPEnrollment{}, fmt.Errorf("error during saving totp secret of user %s, cause: %w", userID, err)
+	}
+
+	return entity.TOTPEnrollment{Secret: secret, URI: totpURI(g.twoFactor.Issuer, user.Login, secret)}, nil
+}
+
+// VerifyTOTP enables 2FA if the code matches the enrolled secret and returns new recovery codes. The codes are
+// only stored hashed and can't be shown again.
+func (g *GophermartServiceImpl) VerifyTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	if code == "" {
+		return nil, ErrorEmptyValue
+	}
+	user, err := g.userStorage.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error during recieving user: %s, cause: %w", userID, err)
+	}
+	if user.TOTPEnabled {
+		return nil, ErrorTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == nil {
+		return nil, ErrorTwoFactorNotEnrolled
+	}
+
+	step, valid, err := g.validateUserTOTP(user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, ErrorInvalidTOTPCode
+	}
+
+	recoveryCodes := make([]string, 0, recoveryCodeCount)
+	recoveryCodeHashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		recoveryCode, err := generateRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("error during generating recovery codes for user %s, cause: %w", userID, err)
+		}
+		recoveryCodes = append(recoveryCodes, recoveryCode)
+		recoveryCodeHashes = append(recoveryCodeHashes, hashToken(recoveryCode))
+	}
+
+	err = g.userStorage.EnableTOTP(ctx, userID, step, recoveryCodeHashes)
+	if err != nil {
+		return nil, fmt.Errorf("error during enabling totp of user %s, cause: %w", userID, err)
+	}
+	return recoveryCodes, nil
+}
+
+// LoginTwoFactor completes a login started by LoginUser. The code is either a TOTP code or an unused recovery code.
+// Wrong codes count as failed logins, so they are throttled like passwords.
+func (g *GophermartServiceImpl) LoginTwoFactor(ctx context.Context, challengeToken, code string, client entity.ClientInfo) (entity.TokenPair, error) {
+	if challengeToken == "" || code == "" {
+		return entity.TokenPair{}, ErrorEmptyValue
+	}
+
+	claims, err := g.parseClaimsWithPurpose(challengeToken, challengeTokenPurpose)
+	if err != nil {
+		return entity.TokenPair{}, err
+	}
+	revoked, err := g.isTokenRevoked(ctx, claims)
+	if err != nil {
+		return entity.TokenPair{}, err
+	}
+	if revoked {
+		return entity.TokenPair{}, ErrorTokenRevoked
+	}
+
+	user, err := g.userStorage.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return entity.TokenPair{}, fmt.Errorf("error during recieving user: %s, cause: %w", claims.UserID, err)
+	}
+	attemptKeys := g.loginAttemptKeys(user.Login, client.IP)
+	if err := g.checkLoginLockout(ctx, attemptKeys); err != nil {
+		return entity.TokenPair{}, err
+	}
+
+	valid, err := g.verifySecondFactor(ctx, user, code)
+	if err != nil {
+		return entity.TokenPair{}, err
+	}
+	if !valid {
+		if err := g.registerFailedLogin(ctx, attemptKeys); err != nil {
+			return entity.TokenPair{}, err
+		}
+		return entity.TokenPair{}, ErrorInvalidTOTPCode
+	}
+	if err := g.resetFailedLogins(ctx, user.Login); err != nil {
+		return entity.TokenPair{}, err
+	}
+
+	// the challenge can be completed only once
+	err = g.revokedTokenStorage.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return entity.TokenPair{}, fmt.Errorf("error during revoking challenge token of user %s, cause: %w", user.ID, err)
+	}
+	g.revocationCache.setRevoked(claims.ID, claims.ExpiresAt.Time)
+
+	return g.startSession(ctx, user, client)
+}
+
+func (g *GophermartServiceImpl) verifySecondFactor(ctx context.Context, user entity.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		step, valid, err := g.validateUserTOTP(user, code)
+		if err != nil || !valid {
+			return false, err
+		}
+		err = g.userStorage.MarkTOTPStepUsed(ctx, user.ID, step)
+		if errors.Is(err, storage.ErrTOTPCodeAlreadyUsed) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("error during marking totp code of user %s as used, cause: %w", user.ID, err)
+		}
+		return true, nil
+	}
+
+	err := g.userStorage.UseRecoveryCode(ctx, user.ID, hashToken(strings.ToUpper(code)))
+	if errors.Is(err, storage.ErrItemNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error during using recovery code of user %s, cause: %w", user.ID, err)
+	}
+	serviceLogger.Info("user %s logged in with a recovery code", user.ID)
+	return true, nil
+}
+
+func (g *GophermartServiceImpl) validateUserTOTP(user entity.User, code string) (int64, bool, error) {
+	if g.twoFactor.Cipher == nil {
+		return 0, false, ErrorTwoFactorNotConfigured
+	}
+	secret, err := g.twoFactor.Cipher.Decrypt(user.TOTPSecret, user.ID)
+	if err != nil {
+		return 0, false, fmt.Errorf("error during decrypting totp secret of user %s, cause: %w", user.ID, err)
+	}
+	step, valid, err := validateTOTP(secret, code, time.Now())
+	if err != nil {
+		return 0, false, fmt.Errorf("error during validating totp code of user %s, cause: %w", user.ID, err)
+	}
+	return step, valid, nil
+}
+
+func (g *GophermartServiceImpl) generateChallengeToken(user entity.User) (string, error) {
+	now := time.Now()
+
+	jti, err := generateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	return g.tokenKeys.sign(&jwtTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(g.twoFactor.ChallengeTTL)),
+			Audience:  tokenAudience(challengeTokenPurpose),
+		},
+		UserID:  user.ID,
+		Purpose: challengeTokenPurpose,
+	})
+}
+
+func generateRecoveryCode() (string, error) {
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+	return secret[:recoveryCodeLength], nil
+}
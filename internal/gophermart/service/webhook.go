@@ -0,0 +1,334 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	loyaltyHTTPClient "github.com/apolsh/yapr-gophermart/internal/gophermart/client"
+	entity "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+)
+
+const (
+	maxWebhookURLLength       = 2048
+	maxWebhookDeliveriesPage  = 100
+	webhookDispatchInterval   = 5 * time.Second
+	webhookDispatchBatchSize  = 20
+	webhookDeliveryBaseDelay  = 30 * time.Second
+	webhookDeliveryMaxBackoff = 6 * time.Hour
+)
+
+var knownWebhookEvents = map[string]bool{
+	entity.WebhookEventOrderProcessed:   true,
+	entity.WebhookEventOrderInvalid:     true,
+	entity.WebhookEventBalanceWithdrawn: true,
+}
+
+// WebhookOptions configures the delivery of webhook events. Without a sender events are queued but not delivered.
+type WebhookOptions struct {
+	Sender loyaltyHTTPClient.WebhookSender
+	// MaxAttempts limits the attempts to deliver an event, after that the delivery is marked as failed
+	MaxAttempts int
+	// Lease is the time an instance has to deliver a claimed event before other instances retry it, it must be
+	// longer than the timeout of the sender
+	Lease time.Duration
+	// Cipher encrypts the secrets of the webhooks, without it webhooks can't be created
+	Cipher *SecretCipher
+	// TOTPCipher decrypts the secrets encrypted with the TOTP key before the webhooks had a key of their own
+	TOTPCipher *SecretCipher
+	// AllowPrivateTargets permits webhooks on the host and the internal network, it is meant for development only
+	AllowPrivateTargets bool
+}
+
+// CreateWebhook subscribes the url to the given event types of the user. The payloads are signed with a secret,
+// which is returned once.
+func (g *GophermartServiceImpl) CreateWebhook(ctx context.Context, userID, webhookURL string, eventTypes []string) (entity.CreatedWebhook, error) {
+	if g.webhooks.Cipher == nil {
+		return entity.CreatedWebhook{}, ErrorWebhooksNotConfigured
+	}
+	if err := validateWebhookURL(webhookURL, g.webhooks.AllowPrivateTargets); err != nil {
+		return entity.CreatedWebhook{}, err
+	}
+	eventTypes, err := normalizeWebhookEvents(eventTypes)
+	if err != nil {
+		return entity.CreatedWebhook{}, err
+	}
+
+	secret, err := generateRandomToken(32)
+	if err != nil {
+		return entity.CreatedWebhook{}, fmt.Errorf("error during generating webhook secret for user %s, cause: %w", userID, err)
+	}
+	encryptedSecret, err := g.webhooks.Cipher.Encrypt(secret, webhookSecretAdditionalData(userID))
+	if err != nil {
+		return entity.CreatedWebhook{}, fmt.Errorf("error during encrypting webhook secret for user %s, cause: %w", userID, err)
+	}
+	webhook := entity.Webhook{
+		UserID:     userID,
+		URL:        webhookURL,
+		EventTypes: eventTypes,
+		CreatedAt:  time.Now(),
+	}
+	webhook.ID, err = g.webhookStorage.CreateWebhook(ctx, webhook, encryptedSecret)
+	if err != nil {
+		return entity.CreatedWebhook{}, fmt.Errorf("error during saving webhook of user %s, cause: %w", userID, err)
+	}
+	return entity.CreatedWebhook{Webhook: webhook, Secret: secret}, nil
+}
+
+func (g *GophermartServiceImpl) GetWebhooks(ctx context.Context, userID string) ([]entity.Webhook, error) {
+	webhooks, err := g.webhookStorage.GetWebhooks(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error during recieving webhooks of user %s, cause: %w", userID, err)
+	}
+	return webhooks, nil
+}
+
+func (g *GophermartServiceImpl) DeleteWebhook(ctx context.Context, userID, webhookID string) error {
+	err := g.webhookStorage.DeleteWebhook(ctx, userID, webhookID)
+	if err != nil {
+		return fmt.Errorf("error during deleting webhook %s, cause: %w", webhookID, err)
+	}
+	return nil
+}
+
+// GetWebhookDeliveries returns the latest deliveries of the webhook of the user, the most recent first.
+func (g *GophermartServiceImpl) GetWebhookDeliveries(ctx context.Context, userID, webhookID string) ([]entity.WebhookDelivery, error) {
+	deliveries, err := g.webhookStorage.GetWebhookDeliveries(ctx, userID, webhookID, maxWebhookDeliveriesPage)
+	if err != nil {
+		return nil, fmt.Errorf("error during recieving deliveries of webhook %s, cause: %w", webhookID, err)
+	}
+	return deliveries, nil
+}
+
+// StartWebhookDispatcher periodically delivers the due webhook events until ctx is done. Several instances can
+// run it at the same time, every delivery is claimed by one of them.
+func (g *GophermartServiceImpl) StartWebhookDispatcher(ctx context.Context) {
+	if g.webhooks.Sender == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(webhookDispatchInterval)
+		defer ticker.Stop()
+		for {
+			for {
+				dispatched, err := g.dispatchWebhooks(ctx)
+				if err != nil {
+					serviceLogger.Error(fmt.Errorf("failed to dispatch webhooks: %w", err))
+				}
+				// a full batch means that more deliveries may be due
+				if err != nil || dispatched < webhookDispatchBatchSize {
+					break
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// dispatchWebhooks delivers a batch of due events concurrently and returns the size of the batch.
+func (g *GophermartServiceImpl) dispatchWebhooks(ctx context.Context) (int, error) {
+	now := time.Now()
+	deliveries, err := g.webhookStorage.ClaimWebhookDeliveries(ctx, now, now.Add(g.webhooks.Lease), webhookDispatchBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("error during claiming webhook deliveries, cause: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery entity.WebhookDelivery) {
+			defer wg.Done()
+			g.deliverWebhook(ctx, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+func (g *GophermartServiceImpl) deliverWebhook(ctx context.Context, delivery entity.WebhookDelivery) {
+	var status int
+	secret, err := g.webhookSecret(delivery)
+	if err != nil {
+		serviceLogger.Error(err)
+	} else {
+		status, err = g.webhooks.Sender.Send(ctx, loyaltyHTTPClient.WebhookRequest{
+			URL:        delivery.URL,
+			Secret:     secret,
+			EventType:  delivery.EventType,
+			DeliveryID: delivery.ID,
+			Payload:    delivery.Payload,
+		})
+	}
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = nil
+	delivery.NextAttemptAt = nil
+	delivery.LastError = ""
+	if status != 0 {
+		delivery.ResponseStatus = &status
+	}
+
+	if err == nil && status >= 200 && status < 300 {
+		delivery.Status = entity.WebhookDeliveryDelivered
+	} else {
+		if err != nil {
+			// the cause is not shown to the user, it could tell about the internal network
+			delivery.LastError = webhookDeliveryError(err)
+			serviceLogger.Debug("webhook delivery %d failed: %s", delivery.ID, err)
+		} else {
+			delivery.LastError = fmt.Sprintf("unexpected response status %d", status)
+		}
+		delivery.Status = entity.WebhookDeliveryFailed
+		if delivery.Attempts < g.webhooks.MaxAttempts {
+			delivery.Status = entity.WebhookDeliveryPending
+			nextAttemptAt := now.Add(webhookBackoff(delivery.Attempts))
+			delivery.NextAttemptAt = &nextAttemptAt
+		}
+	}
+
+	// the context of the dispatcher may be done already, the outcome is saved anyway
+	err = g.webhookStorage.SaveWebhookDeliveryAttempt(context.Background(), delivery)
+	if err != nil {
+		serviceLogger.Error(fmt.Errorf("failed to save attempt of webhook delivery %d: %w", delivery.ID, err))
+	}
+}
+
+// webhookBackoff doubles the delay after every failed attempt up to webhookDeliveryMaxBackoff.
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookDeliveryBaseDelay
+	for i := 1; i < attempts && backoff < webhookDeliveryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > webhookDeliveryMaxBackoff {
+		return webhookDeliveryMaxBackoff
+	}
+	return backoff
+}
+
+// webhookSecret decrypts the secret of the webhook of the delivery, the plain secrets of the webhooks created
+// before the encryption and the secrets encrypted with the TOTP key are used until EncryptWebhookSecrets has run.
+func (g *GophermartServiceImpl) webhookSecret(delivery entity.WebhookDelivery) (string, error) {
+	if delivery.EncryptedSecret == nil {
+		return delivery.Secret, nil
+	}
+	if g.webhooks.Cipher == nil {
+		return "", ErrorWebhooksNotConfigured
+	}
+	additionalData := webhookSecretAdditionalData(delivery.UserID)
+	secret, err := g.webhooks.Cipher.Decrypt(delivery.EncryptedSecret, additionalData)
+	if err != nil && g.webhooks.TOTPCipher != nil {
+		secret, err = g.webhooks.TOTPCipher.Decrypt(delivery.EncryptedSecret, additionalData)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error during decrypting secret of webhook %s, cause: %w", delivery.WebhookID, err)
+	}
+	return secret, nil
+}
+
+// EncryptWebhookSecrets encrypts the secrets of the webhooks created before the secrets were encrypted and
+// re-encrypts the ones encrypted with the TOTP key with the webhook key. It can't be done by a database migration,
+// the keys are known to the application only. It is safe to run on every start.
+func (g *GophermartServiceImpl) EncryptWebhookSecrets(ctx context.Context) error {
+	secrets, err := g.webhookStorage.GetWebhookSecretsToEncrypt(ctx)
+	if err != nil {
+		return fmt.Errorf("error during recieving webhook secrets to encrypt, cause: %w", err)
+	}
+	if len(secrets) > 0 && g.webhooks.Cipher == nil {
+		return fmt.Errorf("%d webhook secrets have to be encrypted: %w", len(secrets), ErrorWebhooksNotConfigured)
+	}
+	for _, secret := range secrets {
+		plainSecret := secret.Secret
+		if secret.EncryptedSecret != nil {
+			if g.webhooks.TOTPCipher == nil {
+				return fmt.Errorf("secret of webhook %s is encrypted with the totp key: %w", secret.WebhookID, ErrorWebhooksNotConfigured)
+			}
+			plainSecret, err = g.webhooks.TOTPCipher.Decrypt(secret.EncryptedSecret, webhookSecretAdditionalData(secret.UserID))
+			if err != nil {
+				return fmt.Errorf("error during decrypting secret of webhook %s, cause: %w", secret.WebhookID, err)
+			}
+		}
+		encryptedSecret, err := g.webhooks.Cipher.Encrypt(plainSecret, webhookSecretAdditionalData(secret.UserID))
+		if err != nil {
+			return fmt.Errorf("error during encrypting secret of webhook %s, cause: %w", secret.WebhookID, err)
+		}
+		if err := g.webhookStorage.SaveEncryptedWebhookSecret(ctx, secret.WebhookID, encryptedSecret); err != nil {
+			return fmt.Errorf("error during saving encrypted secret of webhook %s, cause: %w", secret.WebhookID, err)
+		}
+	}
+	return nil
+}
+
+// webhookSecretAdditionalData binds the secret to the user and keeps it apart from the TOTP secrets, which were
+// encrypted with the same key before the webhooks had a key of their own.
+func webhookSecretAdditionalData(userID string) string {
+	return "webhook:" + userID
+}
+
+func webhookDeliveryError(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, loyaltyHTTPClient.ErrWebhookTargetForbidden):
+		return "target address is not allowed"
+	case errors.Is(err, ErrorWebhooksNotConfigured):
+		return "webhook secret can't be decrypted"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "request timed out"
+	default:
+		return "request failed"
+	}
+}
+
+// validateWebhookURL rejects the obviously internal hosts early, the sender checks the resolved addresses as well.
+func validateWebhookURL(webhookURL string, allowPrivateTargets bool) error {
+	if webhookURL == "" {
+		return ErrorEmptyValue
+	}
+	parsed, err := url.Parse(webhookURL)
+	if err != nil || len(webhookURL) > maxWebhookURLLength || parsed.Hostname() == "" || parsed.User != nil ||
+		(parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return ErrorInvalidWebhookURL
+	}
+	if allowPrivateTargets {
+		return nil
+	}
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrorInvalidWebhookURL
+	}
+	if ip := net.ParseIP(host); ip != nil && loyaltyHTTPClient.IsForbiddenWebhookIP(ip) {
+		return ErrorInvalidWebhookURL
+	}
+	return nil
+}
+
+func normalizeWebhookEvents(eventTypes []string) ([]string, error) {
+	if len(eventTypes) == 0 {
+		return nil, ErrorEmptyValue
+	}
+	unique := make(map[string]bool, len(eventTypes))
+	for _, eventType := range eventTypes {
+		if !knownWebhookEvents[eventType] {
+			return nil, fmt.Errorf("%w: %s", ErrorUnknownWebhookEvent, eventType)
+		}
+		unique[eventType] = true
+	}
+
+	normalized := make([]string, 0, len(unique))
+	for eventType := range unique {
+		normalized = append(normalized, eventType)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
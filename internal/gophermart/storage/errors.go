@@ -10,4 +10,9 @@ var (
 	ErrOrderAlreadyStored            = errors.New("order is already uploaded by user")
 	ErrOrderAlreadyStoredByOtherUser = errors.New("order is already uploaded by another user")
 	ErrInsufficientFunds             = errors.New("insufficient funds to complete the operation")
+	ErrRefreshTokenAlreadyUsed       = errors.New("refresh token is already used")
+	ErrTOTPCodeAlreadyUsed           = errors.New("totp code is already used")
+	ErrIdentityAlreadyLinked         = errors.New("external identity is already linked to a user")
+	ErrPasswordResetTokenUsed        = errors.New("password reset token is already used")
+	ErrOrderAlreadyProcessed         = errors.New("order is already processed")
 )
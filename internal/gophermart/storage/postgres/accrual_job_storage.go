@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type AccrualJobStoragePG struct {
+	pool *pgxpool.Pool
+}
+
+func NewAccrualJobStoragePG(pool *pgxpool.Pool) *AccrualJobStoragePG {
+	return &AccrualJobStoragePG{pool: pool}
+}
+
+// ClaimAccrualJobs leases up to limit due jobs to the owner until leaseUntil. Jobs leased by other owners are
+// skipped until their lease runs out, so that the jobs of a stopped instance are taken over.
+func (s *AccrualJobStoragePG) ClaimAccrualJobs(ctx context.Context, owner string, now, leaseUntil time.Time, limit int) ([]dto.AccrualJob, error) {
+	//language=postgresql
+	q := `UPDATE accrual_job SET lease_owner = $1, lease_until = $3
+		WHERE order_number IN (
+			SELECT order_number FROM accrual_job
+			WHERE next_attempt_at <= $2 AND (lease_until IS NULL OR lease_until < $2)
+			ORDER BY next_attempt_at LIMIT $4 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING order_number, attempts, next_attempt_at, coalesce(last_error, ''), lease_owner, lease_until`
+	rows, err := s.pool.Query(ctx, q, owner, now, leaseUntil, limit)
+	if err != nil {
+		return nil, fmt.Errorf("storage error while claiming accrual jobs, cause: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := make([]dto.AccrualJob, 0)
+	for rows.Next() {
+		var job dto.AccrualJob
+		err := rows.Scan(&job.OrderNumber, &job.Attempts, &job.NextAttemptAt, &job.LastError, &job.LeaseOwner, &job.LeaseUntil)
+		if err != nil {
+			return nil, fmt.Errorf("storage error while claiming accrual jobs, cause: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// ExtendAccrualJobLeases prolongs the leases of the jobs the owner is still working on.
+func (s *AccrualJobStoragePG) ExtendAccrualJobLeases(ctx context.Context, owner string, orderNums []string, leaseUntil time.Time) error {
+	//language=postgresql
+	q := "UPDATE accrual_job SET lease_until = $3 WHERE lease_owner = $1 AND order_number = ANY($2)"
+	_, err := s.pool.Exec(ctx, q, owner, orderNums, leaseUntil)
+	if err != nil {
+		return fmt.Errorf("storage error while extending accrual job leases, cause: %w", err)
+	}
+	return nil
+}
+
+// RescheduleAccrualJob saves the outcome of an attempt and releases the job. Nothing is saved if the lease has
+// been taken over meanwhile.
+func (s *AccrualJobStoragePG) RescheduleAccrualJob(ctx context.Context, job dto.AccrualJob) error {
+	//language=postgresql
+	q := `UPDATE accrual_job SET attempts = $3, next_attempt_at = $4, last_error = nullif($5, ''),
+		lease_owner = NULL, lease_until = NULL
+		WHERE order_number = $1 AND lease_owner = $2`
+	_, err := s.pool.Exec(ctx, q, job.OrderNumber, job.LeaseOwner, job.Attempts, job.NextAttemptAt, job.LastError)
+	if err != nil {
+		return fmt.Errorf("storage error while rescheduling accrual job of order %s, cause: %w", job.OrderNumber, err)
+	}
+	return nil
+}
+
+// CompleteAccrualJob removes the job once the order has got a final status.
+func (s *AccrualJobStoragePG) CompleteAccrualJob(ctx context.Context, job dto.AccrualJob) error {
+	//language=postgresql
+	q := "DELETE FROM accrual_job WHERE order_number = $1 AND lease_owner = $2"
+	_, err := s.pool.Exec(ctx, q, job.OrderNumber, job.LeaseOwner)
+	if err != nil {
+		return fmt.Errorf("storage error while completing accrual job of order %s, cause: %w", job.OrderNumber, err)
+	}
+	return nil
+}
+
+// enqueueAccrualJobs schedules the polling of the orders in the transaction which saves them. An existing job
+// starts over with a fresh attempt counter and loses its lease, so that the result of a running attempt is dropped.
+func enqueueAccrualJobs(ctx context.Context, tx pgx.Tx, orderNums []string, now time.Time) error {
+	//language=postgresql
+	q := `INSERT INTO accrual_job (order_number, attempts, next_attempt_at, created_at)
+		SELECT number, 0, $2, $2 FROM unnest($1::varchar[]) AS number
+		ON CONFLICT (order_number) DO UPDATE SET attempts = 0, next_attempt_at = excluded.next_attempt_at,
+			last_error = NULL, lease_owner = NULL, lease_until = NULL`
+	_, err := tx.Exec(ctx, q, orderNums, now)
+	return err
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type APIKeyStoragePG struct {
+	pool *pgxpool.Pool
+}
+
+func NewAPIKeyStoragePG(pool *pgxpool.Pool) *APIKeyStoragePG {
+	return &APIKeyStoragePG{pool: pool}
+}
+
+func (s *APIKeyStoragePG) CreateAPIKey(ctx context.Context, key dto.APIKey, keyHash string) (string, error) {
+	//language=postgresql
+	q := `INSERT INTO api_key (user_id, name, prefix, key_hash, scopes, created_at) VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	var id string
+	err := s.pool.QueryRow(ctx, q, key.UserID, key.Name, key.Prefix, keyHash, key.Scopes, key.CreatedAt).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("storage error while creating api key of user %s, cause: %w", key.UserID, err)
+	}
+	return id, nil
+}
+
+// GetAPIKeys returns the keys of the user that are not revoked, the most recent first.
+func (s *APIKeyStoragePG) GetAPIKeys(ctx context.Context, userID string) ([]dto.APIKey, error) {
+	//language=postgresql
+	q := `SELECT id, user_id, name, prefix, scopes, created_at, last_used_at FROM api_key
+		WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC`
+	rows, err := s.pool.Query(ctx, q, userID)
+	if err != nil {
+		return nil, fmt.Errorf("storage error while getting api keys of user %s, cause: %w", userID, err)
+	}
+	defer rows.Close()
+
+	keys := make([]dto.APIKey, 0)
+	for rows.Next() {
+		var key dto.APIKey
+		err := rows.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Scopes, &key.CreatedAt, &key.LastUsedAt)
+		if err != nil {
+			return nil, fmt.Errorf("storage error while getting api keys of user %s, cause: %w", userID, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// UseAPIKey records the usage of the key and returns it. Revoked keys and keys of deleted users are not found.
+func (s *APIKeyStoragePG) UseAPIKey(ctx context.Context, keyHash string, usedAt time.Time) (dto.APIKey, error) {
+	//language=postgresql
+	q := `UPDATE api_key k SET last_used_at = greatest(k.last_used_at, $2)
+		FROM "user" u WHERE u.id = k.user_id AND u.deleted_at IS NULL AND k.key_hash = $1 AND k.revoked_at IS NULL
+		RETURNING k.id, k.user_id, k.name, k.prefix, k.scopes, k.created_at, k.last_used_at`
+	var key dto.APIKey
+	err := s.pool.QueryRow(ctx, q, keyHash, usedAt).
+		Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Scopes, &key.CreatedAt, &key.LastUsedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dto.APIKey{}, storage.ErrItemNotFound
+		}
+		return dto.APIKey{}, fmt.Errorf("storage error while using api key, cause: %w", err)
+	}
+	return key, nil
+}
+
+func (s *APIKeyStoragePG) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	//language=postgresql
+	q := "UPDATE api_key SET revoked_at = now() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL"
+	tag, err := s.pool.Exec(ctx, q, id, userID)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == invalidTextRepresentation {
+		return storage.ErrItemNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("storage error while revoking api key %s, cause: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrItemNotFound
+	}
+	return nil
+}
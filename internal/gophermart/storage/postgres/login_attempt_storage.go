@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type LoginAttemptStoragePG struct {
+	pool *pgxpool.Pool
+}
+
+func NewLoginAttemptStoragePG(pool *pgxpool.Pool) *LoginAttemptStoragePG {
+	return &LoginAttemptStoragePG{pool: pool}
+}
+
+func (s *LoginAttemptStoragePG) GetLockedUntil(ctx context.Context, kind, key string) (time.Time, error) {
+	//language=postgresql
+	q := "SELECT locked_until FROM login_attempt WHERE kind = $1 AND key = $2"
+	var lockedUntil *time.Time
+	err := s.pool.QueryRow(ctx, q, kind, key).Scan(&lockedUntil)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, fmt.Errorf("storage error while getting lockout of %s %s, cause: %w", kind, key, err)
+	}
+	if lockedUntil == nil {
+		return time.Time{}, nil
+	}
+	return *lockedUntil, nil
+}
+
+func (s *LoginAttemptStoragePG) RegisterFailedAttempt(ctx context.Context, kind, key string, now, windowStart time.Time) (dto.LoginAttempts, error) {
+	//language=postgresql
+	q := `INSERT INTO login_attempt AS a (kind, key, failures, lockouts, last_failed_at) VALUES ($1, $2, 1, 0, $3)
+		ON CONFLICT (kind, key) DO UPDATE SET
+			failures = CASE WHEN a.last_failed_at < $4 THEN 1 ELSE a.failures + 1 END,
+			lockouts = CASE WHEN greatest(a.last_failed_at, coalesce(a.locked_until, a.last_failed_at)) < $4 THEN 0 ELSE a.lockouts END,
+			last_failed_at = $3
+		RETURNING failures, lockouts`
+	var attempts dto.LoginAttempts
+	err := s.pool.QueryRow(ctx, q, kind, key, now, windowStart).Scan(&attempts.Failures, &attempts.Lockouts)
+	if err != nil {
+		return dto.LoginAttempts{}, fmt.Errorf("storage error while registering failed login attempt of %s %s, cause: %w", kind, key, err)
+	}
+	return attempts, nil
+}
+
+func (s *LoginAttemptStoragePG) LockLogin(ctx context.Context, lockout dto.LoginLockout) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("storage error while locking %s %s, cause: %w", lockout.Kind, lockout.Key, err)
+	}
+	defer tx.Rollback(ctx)
+
+	//language=postgresql
+	q := "UPDATE login_attempt SET failures = 0, lockouts = lockouts + 1, locked_until = $3 WHERE kind = $1 AND key = $2"
+	if _, err = tx.Exec(ctx, q, lockout.Kind, lockout.Key, lockout.LockedUntil); err != nil {
+		return fmt.Errorf("storage error while locking %s %s, cause: %w", lockout.Kind, lockout.Key, err)
+	}
+	//language=postgresql
+	q = "INSERT INTO login_lockout (kind, key, failures, locked_at, locked_until) VALUES ($1, $2, $3, $4, $5)"
+	if _, err = tx.Exec(ctx, q, lockout.Kind, lockout.Key, lockout.Failures, lockout.LockedAt, lockout.LockedUntil); err != nil {
+		return fmt.Errorf("storage error while recording lockout of %s %s, cause: %w", lockout.Kind, lockout.Key, err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetLoginLockouts returns up to limit lockouts, the latest first.
+func (s *LoginAttemptStoragePG) GetLoginLockouts(ctx context.Context, limit int) ([]dto.LoginLockout, error) {
+	//language=postgresql
+	q := "SELECT kind, key, failures, locked_at, locked_until FROM login_lockout ORDER BY locked_at DESC, id DESC LIMIT $1"
+	rows, err := s.pool.Query(ctx, q, limit)
+	if err != nil {
+		return nil, fmt.Errorf("storage error while getting lockouts, cause: %w", err)
+	}
+	defer rows.Close()
+
+	lockouts := make([]dto.LoginLockout, 0)
+	for rows.Next() {
+		var lockout dto.LoginLockout
+		err := rows.Scan(&lockout.Kind, &lockout.Key, &lockout.Failures, &lockout.LockedAt, &lockout.LockedUntil)
+		if err != nil {
+			return nil, fmt.Errorf("storage error while getting lockouts, cause: %w", err)
+		}
+		lockouts = append(lockouts, lockout)
+	}
+	return lockouts, rows.Err()
+}
+
+func (s *LoginAttemptStoragePG) ResetFailedAttempts(ctx context.Context, kind, key string) error {
+	//language=postgresql
+	q := "DELETE FROM login_attempt WHERE kind = $1 AND key = $2"
+	if _, err := s.pool.Exec(ctx, q, kind, key); err != nil {
+		return fmt.Errorf("storage error while resetting failed login attempts of %s %s, cause: %w", kind, key, err)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+BEGIN;
+create table if not exists refresh_token
+(
+    token_hash varchar(64)              not null
+        constraint refresh_token_pk
+            primary key,
+    family_id  varchar(64)              not null,
+    user_id    uuid                     not null
+        constraint refresh_token_user_id_fk
+            references "user"
+            on delete cascade,
+    expires_at timestamp with time zone not null,
+    used_at    timestamp with time zone,
+    revoked    boolean default false    not null
+);
+
+create index if not exists refresh_token_family_id_index
+    on refresh_token (family_id);
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+create table if not exists revoked_token
+(
+    jti        varchar(64)              not null
+        constraint revoked_token_pk
+            primary key,
+    expires_at timestamp with time zone not null
+);
+
+create index if not exists revoked_token_expires_at_index
+    on revoked_token (expires_at);
+
+COMMIT;
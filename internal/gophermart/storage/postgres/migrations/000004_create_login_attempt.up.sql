@@ -0,0 +1,29 @@
+BEGIN;
+create table if not exists login_attempt
+(
+    kind           varchar(16)              not null,
+    key            varchar(255)             not null,
+    failures       integer default 0        not null,
+    lockouts       integer default 0        not null,
+    last_failed_at timestamp with time zone not null,
+    locked_until   timestamp with time zone,
+    constraint login_attempt_pk
+        primary key (kind, key)
+);
+
+create table if not exists login_lockout
+(
+    id           bigserial                not null
+        constraint login_lockout_pk
+            primary key,
+    kind         varchar(16)              not null,
+    key          varchar(255)             not null,
+    failures     integer                  not null,
+    locked_at    timestamp with time zone not null,
+    locked_until timestamp with time zone not null
+);
+
+create index if not exists login_lockout_locked_at_index
+    on login_lockout (locked_at desc);
+
+COMMIT;
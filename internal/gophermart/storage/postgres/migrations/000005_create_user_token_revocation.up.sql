@@ -0,0 +1,16 @@
+BEGIN;
+create table if not exists user_token_revocation
+(
+    user_id        uuid                     not null
+        constraint user_token_revocation_pk
+            primary key
+        constraint user_token_revocation_user_id_fk
+            references "user"
+            on delete cascade,
+    revoked_before timestamp with time zone not null
+);
+
+create index if not exists refresh_token_user_id_index
+    on refresh_token (user_id);
+
+COMMIT;
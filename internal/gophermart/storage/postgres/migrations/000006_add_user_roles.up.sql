@@ -0,0 +1,5 @@
+BEGIN;
+alter table "user"
+    add column if not exists roles text[] default '{user}' not null;
+
+COMMIT;
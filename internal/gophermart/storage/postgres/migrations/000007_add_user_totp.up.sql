@@ -0,0 +1,19 @@
+BEGIN;
+alter table "user"
+    add column if not exists totp_secret bytea,
+    add column if not exists totp_enabled boolean default false not null,
+    add column if not exists totp_last_step bigint default 0 not null;
+
+create table if not exists user_recovery_code
+(
+    user_id   uuid        not null
+        constraint user_recovery_code_user_id_fk
+            references "user"
+            on delete cascade,
+    code_hash varchar(64) not null,
+    used_at   timestamp with time zone,
+    constraint user_recovery_code_pk
+        primary key (user_id, code_hash)
+);
+
+COMMIT;
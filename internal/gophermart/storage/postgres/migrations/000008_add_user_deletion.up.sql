@@ -0,0 +1,23 @@
+BEGIN;
+alter table "user"
+    add column if not exists deleted_at   timestamp with time zone,
+    add column if not exists retain_until timestamp with time zone;
+
+create index if not exists user_retain_until_index
+    on "user" (retain_until)
+    where retain_until is not null;
+
+-- financial records must outlive the user until the retention period ends, they are purged explicitly
+alter table withdrawal
+    drop constraint if exists withdrawal_user_id_fk,
+    add constraint withdrawal_user_id_fk
+        foreign key (user_id) references "user"
+            on delete restrict;
+
+alter table "order"
+    drop constraint if exists order_user_id_fk,
+    add constraint order_user_id_fk
+        foreign key (user_id) references "user"
+            on delete restrict;
+
+COMMIT;
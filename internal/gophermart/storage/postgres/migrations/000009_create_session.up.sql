@@ -0,0 +1,29 @@
+BEGIN;
+create table if not exists session
+(
+    id           varchar(64)              not null
+        constraint session_pk
+            primary key,
+    user_id      uuid                     not null
+        constraint session_user_id_fk
+            references "user"
+            on delete cascade,
+    user_agent   varchar(512)             not null,
+    ip           varchar(64)              not null,
+    created_at   timestamp with time zone not null,
+    last_seen_at timestamp with time zone not null,
+    revoked_at   timestamp with time zone
+);
+
+create index if not exists session_user_id_index
+    on session (user_id);
+
+-- every existing refresh token family becomes a session, the session id is the family id
+insert into session (id, user_id, user_agent, ip, created_at, last_seen_at)
+select family_id, user_id, '', '', now(), now()
+from refresh_token
+where not revoked
+group by family_id, user_id
+on conflict do nothing;
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+create table if not exists api_key
+(
+    id           uuid default gen_random_uuid() not null
+        constraint api_key_pk
+            primary key,
+    user_id      uuid                           not null
+        constraint api_key_user_id_fk
+            references "user"
+            on delete cascade,
+    name         varchar(100)                   not null,
+    prefix       varchar(16)                    not null,
+    key_hash     varchar(64)                    not null
+        constraint api_key_key_hash_uindex
+            unique,
+    scopes       text[]                         not null,
+    created_at   timestamp with time zone       not null,
+    last_used_at timestamp with time zone,
+    revoked_at   timestamp with time zone
+);
+
+create index if not exists api_key_user_id_index
+    on api_key (user_id);
+COMMIT;
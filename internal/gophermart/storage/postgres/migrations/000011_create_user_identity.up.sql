@@ -0,0 +1,18 @@
+BEGIN;
+create table if not exists user_identity
+(
+    issuer     varchar(255)             not null,
+    subject    varchar(255)             not null,
+    user_id    uuid                     not null
+        constraint user_identity_user_id_fk
+            references "user"
+            on delete cascade,
+    email      varchar(255)             not null,
+    created_at timestamp with time zone not null,
+    constraint user_identity_pk
+        primary key (issuer, subject)
+);
+
+create index if not exists user_identity_user_id_index
+    on user_identity (user_id);
+COMMIT;
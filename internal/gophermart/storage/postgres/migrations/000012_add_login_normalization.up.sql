@@ -0,0 +1,42 @@
+BEGIN;
+alter table "user"
+    add column if not exists email_verified_at timestamp with time zone;
+
+-- logins of existing users were never verified as email addresses, they are trusted as they are
+update "user"
+set email_verified_at = now()
+where email_verified_at is null;
+
+-- users whose login can't be normalized because the normalized login is used by another user, they keep
+-- logging in with the exact login until the collision is resolved
+create table if not exists login_collision
+(
+    user_id          uuid                     not null
+        constraint login_collision_pk
+            primary key
+        constraint login_collision_user_id_fk
+            references "user"
+            on delete cascade,
+    login            varchar(255)             not null,
+    normalized_login varchar(255)             not null,
+    detected_at      timestamp with time zone not null
+);
+
+-- logins which normalize to the same login, or to the login of another user, are recorded as collisions here, so
+-- that every instance sees the same collisions. lower() stands in for the case folding of the application, the
+-- other logins are normalized on start.
+insert into login_collision (user_id, login, normalized_login, detected_at)
+select u.id, u.login, n.normalized, now()
+from "user" u
+         cross join lateral (select normalize(lower(normalize(u.login, NFKC)), NFKC) as normalized) n
+where u.deleted_at is null
+  and u.login <> n.normalized
+  and (exists(select 1 from "user" o where o.id <> u.id and o.login = n.normalized)
+    or exists(select 1
+              from "user" o
+              where o.id <> u.id
+                and o.deleted_at is null
+                and o.login <> n.normalized
+                and normalize(lower(normalize(o.login, NFKC)), NFKC) = n.normalized))
+on conflict (user_id) do nothing;
+COMMIT;
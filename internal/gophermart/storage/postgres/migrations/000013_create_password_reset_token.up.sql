@@ -0,0 +1,18 @@
+BEGIN;
+create table if not exists password_reset_token
+(
+    token_hash varchar(64)              not null
+        constraint password_reset_token_pk
+            primary key,
+    user_id    uuid                     not null
+        constraint password_reset_token_user_id_fk
+            references "user"
+            on delete cascade,
+    created_at timestamp with time zone not null,
+    expires_at timestamp with time zone not null,
+    used_at    timestamp with time zone
+);
+
+create index if not exists password_reset_token_user_id_index
+    on password_reset_token (user_id);
+COMMIT;
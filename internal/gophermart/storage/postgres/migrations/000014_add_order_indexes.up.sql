@@ -0,0 +1,10 @@
+BEGIN;
+create index if not exists order_user_id_uploaded_at_index
+    on "order" (user_id, uploaded_at, number);
+
+create index if not exists order_user_id_status_uploaded_at_index
+    on "order" (user_id, status, uploaded_at, number);
+
+create index if not exists order_user_id_accrual_index
+    on "order" (user_id, accrual, number);
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+create table if not exists order_status_history
+(
+    id           bigserial                not null
+        constraint order_status_history_pk
+            primary key,
+    order_number varchar(255)             not null
+        constraint order_status_history_order_number_fk
+            references "order"
+            on delete cascade,
+    status       varchar(255)             not null,
+    accrual      numeric(12, 2)           not null,
+    attempt      integer                  not null,
+    observed_at  timestamp with time zone not null
+);
+
+create index if not exists order_status_history_order_number_index
+    on order_status_history (order_number, observed_at);
+
+-- the history of existing orders starts with their current status
+insert into order_status_history (order_number, status, accrual, attempt, observed_at)
+select number, status, accrual, 0, now()
+from "order";
+COMMIT;
@@ -0,0 +1,47 @@
+BEGIN;
+create table if not exists webhook
+(
+    id          uuid default gen_random_uuid() not null
+        constraint webhook_pk
+            primary key,
+    user_id     uuid                           not null
+        constraint webhook_user_id_fk
+            references "user"
+            on delete cascade,
+    url         varchar(2048)                  not null,
+    -- the secret is kept in plain text, it is needed to sign every payload
+    secret      varchar(64)                    not null,
+    event_types text[]                         not null,
+    created_at  timestamp with time zone       not null
+);
+
+create index if not exists webhook_user_id_index
+    on webhook (user_id);
+
+create table if not exists webhook_delivery
+(
+    id              bigserial                not null
+        constraint webhook_delivery_pk
+            primary key,
+    webhook_id      uuid                     not null
+        constraint webhook_delivery_webhook_id_fk
+            references webhook
+            on delete cascade,
+    event_type      varchar(64)              not null,
+    payload         text                     not null,
+    status          varchar(16)              not null,
+    attempts        integer                  not null,
+    response_status integer,
+    last_error      text,
+    created_at      timestamp with time zone not null,
+    last_attempt_at timestamp with time zone,
+    next_attempt_at timestamp with time zone
+);
+
+create index if not exists webhook_delivery_due_index
+    on webhook_delivery (next_attempt_at)
+    where status = 'pending';
+
+create index if not exists webhook_delivery_webhook_id_index
+    on webhook_delivery (webhook_id, created_at);
+COMMIT;
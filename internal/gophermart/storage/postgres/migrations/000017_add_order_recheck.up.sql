@@ -0,0 +1,34 @@
+BEGIN;
+alter table "order"
+    add column if not exists attempts integer not null default 0,
+    add column if not exists last_checked_at timestamp with time zone;
+
+-- every polling attempt updates the order, only changes of the status and the accrual reach the balance
+drop trigger if exists add_accrual_to_balance on "order";
+
+create trigger add_accrual_to_balance
+    after update of status, accrual
+    on "order"
+    for each row
+    execute procedure add_accrual_to_balance();
+
+create table if not exists order_recheck
+(
+    id           bigserial                not null
+        constraint order_recheck_pk
+            primary key,
+    order_number varchar(255)             not null
+        constraint order_recheck_order_number_fk
+            references "order"
+            on delete cascade,
+    triggered_by uuid
+        constraint order_recheck_triggered_by_fk
+            references "user"
+            on delete set null,
+    by_admin     boolean                  not null,
+    created_at   timestamp with time zone not null
+);
+
+create index if not exists order_recheck_order_number_index
+    on order_recheck (order_number, created_at);
+COMMIT;
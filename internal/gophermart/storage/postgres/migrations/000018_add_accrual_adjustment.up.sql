@@ -0,0 +1,58 @@
+BEGIN;
+alter table balance
+    add column if not exists debt numeric(12, 2) not null default 0;
+
+alter table balance
+    add constraint debt_non_negative check (debt >= 0);
+
+-- the balance follows the change of the accrual of the order: an increase repays the debt first, a decrease
+-- which exceeds the current balance becomes debt. The current balance and the debt are never both positive.
+create or replace function add_accrual_to_balance() returns trigger
+    language plpgsql
+as
+$$
+declare
+    delta numeric(12, 2) := new.accrual - old.accrual;
+begin
+    update balance
+    set current = greatest(balance.current - balance.debt + delta, 0),
+        debt    = greatest(balance.debt - balance.current - delta, 0)
+    where user_id = new.user_id;
+    return new;
+END;
+$$;
+
+drop trigger if exists add_accrual_to_balance on "order";
+
+create trigger add_accrual_to_balance
+    after update of accrual
+    on "order"
+    for each row
+    when (old.accrual is distinct from new.accrual)
+    execute procedure add_accrual_to_balance();
+
+create table if not exists accrual_adjustment
+(
+    id               bigserial                not null
+        constraint accrual_adjustment_pk
+            primary key,
+    order_number     varchar(255)             not null
+        constraint accrual_adjustment_order_number_fk
+            references "order"
+            on delete cascade,
+    user_id          uuid                     not null
+        constraint accrual_adjustment_user_id_fk
+            references "user"
+            on delete cascade,
+    amount           numeric(12, 2)           not null,
+    previous_accrual numeric(12, 2)           not null,
+    accrual          numeric(12, 2)           not null,
+    created_at       timestamp with time zone not null
+);
+
+create index if not exists accrual_adjustment_user_id_index
+    on accrual_adjustment (user_id, created_at);
+
+create index if not exists order_status_uploaded_at_index
+    on "order" (status, uploaded_at);
+COMMIT;
@@ -0,0 +1,29 @@
+BEGIN;
+create table if not exists accrual_job
+(
+    order_number    varchar(255)             not null
+        constraint accrual_job_pk
+            primary key
+        constraint accrual_job_order_number_fk
+            references "order"
+            on delete cascade,
+    attempts        integer                  not null,
+    -- null once the attempts are exhausted, the job is kept for its last error until the order is rechecked
+    next_attempt_at timestamp with time zone,
+    last_error      text,
+    lease_owner     varchar(255),
+    lease_until     timestamp with time zone,
+    created_at      timestamp with time zone not null
+);
+
+create index if not exists accrual_job_next_attempt_at_index
+    on accrual_job (next_attempt_at)
+    where next_attempt_at is not null;
+
+-- the polling of every unfinished order starts over, the retries scheduled in memory are lost anyway
+insert into accrual_job (order_number, attempts, next_attempt_at, created_at)
+select number, 0, now(), now()
+from "order"
+where status in ('NEW', 'REGISTERED', 'PROCESSING')
+on conflict (order_number) do nothing;
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+-- the secrets are encrypted by the application, the ones stored before are encrypted on the next start
+alter table webhook
+    add column if not exists encrypted_secret bytea;
+
+alter table webhook
+    alter column secret drop not null;
+
+alter table webhook
+    add constraint webhook_secret_present check (secret is not null or encrypted_secret is not null);
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+create table if not exists request_limit
+(
+    kind              varchar(16)              not null,
+    key               varchar(255)             not null,
+    requests          integer                  not null,
+    window_started_at timestamp with time zone not null,
+    constraint request_limit_pk
+        primary key (kind, key)
+);
+
+-- password reset requests and order rechecks used to be counted as failed logins
+delete from login_attempt where kind in ('password_reset', 'order_recheck');
+delete from login_lockout where kind in ('password_reset', 'order_recheck');
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+-- webhook secrets have a key of their own, the ones encrypted with the TOTP key are re-encrypted on the next start
+alter table webhook
+    add column if not exists totp_key_encrypted boolean not null default false;
+
+update webhook
+set totp_key_encrypted = true
+where encrypted_secret is not null;
+COMMIT;
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	dto "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/shopspring/decimal"
+)
+
+// orderEventsChannel is the notification channel of the status history, it is shared by all instances.
+const orderEventsChannel = "order_events"
+
+type orderEventPayload struct {
+	ID         int64           `json:"id"`
+	UserID     string          `json:"user_id"`
+	Number     string          `json:"number"`
+	Status     string          `json:"status"`
+	Accrual    decimal.Decimal `json:"accrual"`
+	Attempt    int             `json:"attempt"`
+	ObservedAt time.Time       `json:"observed_at"`
+}
+
+func (p orderEventPayload) event() dto.OrderEvent {
+	return dto.OrderEvent{
+		ID:     p.ID,
+		UserID: p.UserID,
+		Number: p.Number,
+		OrderStatusChange: dto.OrderStatusChange{
+			Status:     p.Status,
+			Accrual:    p.Accrual,
+			Attempt:    p.Attempt,
+			ObservedAt: p.ObservedAt,
+		},
+	}
+}
+
+// ListenOrderEvents passes the events of all users to handle until ctx is done or the connection fails. listening
+// is called once the notifications are received, the events committed before may have been missed. The
+// connection is dedicated to listening and is closed afterwards.
+func (o *OrderStoragePG) ListenOrderEvents(ctx context.Context, listening func(), handle func(dto.OrderEvent)) error {
+	conn, err := o.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("storage error while listening to order events, cause: %w", err)
+	}
+	defer conn.Release()
+	defer conn.Conn().Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+orderEventsChannel)
+	if err != nil {
+		return fmt.Errorf("storage error while listening to order events, cause: %w", err)
+	}
+	listening()
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("storage error while listening to order events, cause: %w", err)
+		}
+		var payload orderEventPayload
+		if err := json.Unmarshal([]byte(notification.Payload), &payload); err != nil {
+			return fmt.Errorf("storage error while decoding order event, cause: %w", err)
+		}
+		handle(payload.event())
+	}
+}
+
+// GetOrderEventsReplayStart returns the ID to replay the events of the user after, so that the events observed
+// within window before lastEventID are included. IDs are taken before the commit, thus an event with a lower ID
+// may become visible after lastEventID has been delivered.
+func (o *OrderStoragePG) GetOrderEventsReplayStart(ctx context.Context, userID string, lastEventID int64, window time.Duration) (int64, error) {
+	//language=postgresql
+	q := `SELECT coalesce(min(h.id) - 1, $2) FROM order_status_history h JOIN "order" o ON o.number = h.order_number
+		WHERE o.user_id = $1 AND h.id < $2
+		AND h.observed_at >= (SELECT observed_at FROM order_status_history WHERE id = $2) - $3 * interval '1 second'`
+	var afterID int64
+	err := o.pool.QueryRow(ctx, q, userID, lastEventID, window.Seconds()).Scan(&afterID)
+	if err != nil {
+		return 0, fmt.Errorf("error during recieving order events of user %s, cause: %w", userID, err)
+	}
+	return afterID, nil
+}
+
+// GetOrderEvents returns up to limit events of the user after the given one, the oldest first.
+func (o *OrderStoragePG) GetOrderEvents(ctx context.Context, userID string, afterID int64, limit int) ([]dto.OrderEvent, error) {
+	//language=postgresql
+	q := `SELECT h.id, h.order_number, h.status, h.accrual, h.attempt, h.observed_at
+		FROM order_status_history h JOIN "order" o ON o.number = h.order_number
+		WHERE o.user_id = $1 AND h.id > $2 ORDER BY h.id LIMIT $3`
+	rows, err := o.pool.Query(ctx, q, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error during recieving order events of user %s, cause: %w", userID, err)
+	}
+	defer rows.Close()
+
+	events := make([]dto.OrderEvent, 0)
+	event := dto.OrderEvent{UserID: userID}
+	for rows.Next() {
+		err := rows.Scan(&event.ID, &event.Number, &event.Status, &event.Accrual, &event.Attempt, &event.ObservedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error during recieving order events of user %s, cause: %w", userID, err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -2,13 +2,17 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	dto "github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
 	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/shopspring/decimal"
 )
@@ -28,9 +32,28 @@ func NewOrderStoragePG(pool *pgxpool.Pool) *OrderStoragePG {
 
 func (o *OrderStoragePG) SaveNewOrder(ctx context.Context, orderNum string, userID string) error {
 	order := dto.NewOrder(orderNum, userID)
+	tx, err := o.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error during saving new order %s, cause: %w", orderNum, err)
+	}
+	defer tx.Rollback(ctx)
+
 	//language=postgresql
 	s := "INSERT INTO \"order\" (number, status, accrual, uploaded_at, user_id) VALUES ($1, $2, $3, $4, $5)"
-	_, err := o.pool.Exec(ctx, s, orderNum, order.Status, order.Accrual, order.UploadedAt, order.UserID)
+	_, err = tx.Exec(ctx, s, orderNum, order.Status, order.Accrual, order.UploadedAt, order.UserID)
+	if err == nil {
+		err = insertOrderStatusChange(ctx, tx, userID, orderNum, dto.OrderStatusChange{
+			Status:     order.Status,
+			Accrual:    order.Accrual,
+			ObservedAt: order.UploadedAt,
+		})
+	}
+	if err == nil {
+		err = enqueueAccrualJobs(ctx, tx, []string{orderNum}, order.UploadedAt)
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
 	var pgErr *pgconn.PgError
 	if err != nil {
 		if errors.As(err, &pgErr) {
@@ -54,43 +77,352 @@ func (o *OrderStoragePG) SaveNewOrder(ctx context.Context, orderNum string, user
 	return nil
 }
 
-func (o *OrderStoragePG) UpdateOrder(ctx context.Context, orderNum string, status string, accrual decimal.Decimal) error {
+// SaveNewOrders saves the orders in one transaction. The result of every number is one of dto.OrderUploadAccepted,
+// dto.OrderUploadAlreadyUploaded and dto.OrderUploadOwnedByOtherUser.
+func (o *OrderStoragePG) SaveNewOrders(ctx context.Context, orderNums []string, userID string) (map[string]string, error) {
+	tx, err := o.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error during saving new orders of user %s, cause: %w", userID, err)
+	}
+	defer tx.Rollback(ctx)
+
+	uploadedAt := time.Now()
+	//language=postgresql
+	q := `INSERT INTO "order" (number, status, accrual, uploaded_at, user_id)
+		SELECT number, $2, 0, $3, $4 FROM unnest($1::varchar[]) AS number
+		ON CONFLICT (number) DO NOTHING RETURNING number`
+	rows, err := tx.Query(ctx, q, orderNums, dto.StatusNew, uploadedAt, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error during saving new orders of user %s, cause: %w", userID, err)
+	}
+	results := make(map[string]string, len(orderNums))
+	accepted := make([]string, 0, len(orderNums))
+	for rows.Next() {
+		var orderNum string
+		if err := rows.Scan(&orderNum); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error during saving new orders of user %s, cause: %w", userID, err)
+		}
+		results[orderNum] = dto.OrderUploadAccepted
+		accepted = append(accepted, orderNum)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during saving new orders of user %s, cause: %w", userID, err)
+	}
+
+	//language=postgresql
+	q = `INSERT INTO order_status_history (order_number, status, accrual, attempt, observed_at)
+		SELECT number, $2, 0, 0, $3 FROM unnest($1::varchar[]) AS number RETURNING id, order_number`
+	rows, err = tx.Query(ctx, q, accepted, dto.StatusNew, uploadedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error during saving new orders of user %s, cause: %w", userID, err)
+	}
+	payloads := make([]string, 0, len(accepted))
+	for rows.Next() {
+		event := orderEventPayload{UserID: userID, Status: dto.StatusNew, Accrual: decimal.Zero, ObservedAt: uploadedAt}
+		if err := rows.Scan(&event.ID, &event.Number); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error during saving new orders of user %s, cause: %w", userID, err)
+		}
+		payload, _ := json.Marshal(event)
+		payloads = append(payloads, string(payload))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during saving new orders of user %s, cause: %w", userID, err)
+	}
+	//language=postgresql
+	q = "SELECT pg_notify($1, payload) FROM unnest($2::text[]) AS payload"
+	if _, err := tx.Exec(ctx, q, orderEventsChannel, payloads); err != nil {
+		return nil, fmt.Errorf("error during saving new orders of user %s, cause: %w", userID, err)
+	}
+	if err := enqueueAccrualJobs(ctx, tx, accepted, uploadedAt); err != nil {
+		return nil, fmt.Errorf("error during saving new orders of user %s, cause: %w", userID, err)
+	}
+
+	if len(accepted) < len(orderNums) {
+		//language=postgresql
+		q = "SELECT number, user_id FROM \"order\" WHERE number = ANY($1) AND NOT number = ANY($2)"
+		rows, err := tx.Query(ctx, q, orderNums, accepted)
+		if err != nil {
+			return nil, fmt.Errorf("error during saving new orders of user %s, cause: %w", userID, err)
+		}
+		for rows.Next() {
+			var orderNum, ownerID string
+			if err := rows.Scan(&orderNum, &ownerID); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("error during saving new orders of user %s, cause: %w", userID, err)
+			}
+			results[orderNum] = dto.OrderUploadOwnedByOtherUser
+			if ownerID == userID {
+				results[orderNum] = dto.OrderUploadAlreadyUploaded
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error during saving new orders of user %s, cause: %w", userID, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error during saving new orders of user %s, cause: %w", userID, err)
+	}
+	return results, nil
+}
+
+// UpdateOrder saves the status observed by the given polling attempt. A change of the status or the accrual is
+// recorded in the status history in the same transaction, repeated observations of the same status are not. A
+// change of the accrual of a processed order is recorded as an adjustment, the balance follows it by the trigger.
+func (o *OrderStoragePG) UpdateOrder(ctx context.Context, orderNum string, status string, accrual decimal.Decimal, attempt int) error {
+	tx, err := o.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error during updating order %s, cause: %w", orderNum, err)
+	}
+	defer tx.Rollback(ctx)
+
+	var currentStatus, userID string
+	var currentAccrual decimal.Decimal
+	var uploadedAt time.Time
 	//language=postgresql
-	q := "UPDATE \"order\" SET status = $1, accrual = $2 WHERE number = $3"
-	_, err := o.pool.Exec(ctx, q, status, accrual, orderNum)
+	q := "SELECT status, accrual, user_id, uploaded_at FROM \"order\" WHERE number = $1 FOR UPDATE"
+	err = tx.QueryRow(ctx, q, orderNum).Scan(&currentStatus, &currentAccrual, &userID, &uploadedAt)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrItemNotFound
+		}
 		return fmt.Errorf("error during updating order %s, cause: %w", orderNum, err)
 	}
+	if currentStatus == status && currentAccrual.Equal(accrual) {
+		return nil
+	}
+
+	//language=postgresql
+	q = "UPDATE \"order\" SET status = $1, accrual = $2 WHERE number = $3"
+	_, err = tx.Exec(ctx, q, status, accrual, orderNum)
+	if err != nil {
+		return fmt.Errorf("error during updating order %s, cause: %w", orderNum, err)
+	}
+	observedAt := time.Now()
+	err = insertOrderStatusChange(ctx, tx, userID, orderNum, dto.OrderStatusChange{
+		Status:     status,
+		Accrual:    accrual,
+		Attempt:    attempt,
+		ObservedAt: observedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("error during updating order %s, cause: %w", orderNum, err)
+	}
+	if currentStatus == dto.StatusProcessed && !currentAccrual.Equal(accrual) {
+		//language=postgresql
+		q = `INSERT INTO accrual_adjustment (order_number, user_id, amount, previous_accrual, accrual, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)`
+		_, err = tx.Exec(ctx, q, orderNum, userID, accrual.Sub(currentAccrual), currentAccrual, accrual, observedAt)
+		if err != nil {
+			return fmt.Errorf("error during updating order %s, cause: %w", orderNum, err)
+		}
+	}
+	if eventType, ok := dto.OrderStatusWebhookEvents[status]; ok && currentStatus != status {
+		err = enqueueWebhookEvent(ctx, tx, userID, dto.WebhookEvent{
+			Type:      eventType,
+			CreatedAt: observedAt,
+			Data: dto.Order{Number: orderNum, Status: status, Accrual: accrual, UploadedAt: uploadedAt,
+				Attempts: attempt, LastCheckedAt: &observedAt},
+		})
+		if err != nil {
+			return fmt.Errorf("error during updating order %s, cause: %w", orderNum, err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// SaveOrderCheck records a polling attempt of the order, whatever its outcome.
+func (o *OrderStoragePG) SaveOrderCheck(ctx context.Context, orderNum string, attempt int, checkedAt time.Time) error {
+	//language=postgresql
+	q := "UPDATE \"order\" SET attempts = $1, last_checked_at = $2 WHERE number = $3"
+	_, err := o.pool.Exec(ctx, q, attempt, checkedAt, orderNum)
+	if err != nil {
+		return fmt.Errorf("error during saving check of order %s, cause: %w", orderNum, err)
+	}
 	return nil
 }
 
-func (o *OrderStoragePG) GetOrdersByID(ctx context.Context, id string) ([]dto.Order, error) {
+// SaveOrderRecheck resets the attempt counter of the order, schedules its polling and records who restarted it.
+// Processed orders are final and are not rechecked, a missing order is reported as not found.
+func (o *OrderStoragePG) SaveOrderRecheck(ctx context.Context, recheck dto.OrderRecheck) error {
+	tx, err := o.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error during rechecking order %s, cause: %w", recheck.OrderNumber, err)
+	}
+	defer tx.Rollback(ctx)
+
 	//language=postgresql
-	q := "SELECT number, status, accrual, uploaded_at, user_id FROM \"order\" WHERE user_id = $1"
+	q := "UPDATE \"order\" SET attempts = 0 WHERE number = $1 AND status <> $2"
+	tag, err := tx.Exec(ctx, q, recheck.OrderNumber, dto.StatusProcessed)
+	if err != nil {
+		return fmt.Errorf("error during rechecking order %s, cause: %w", recheck.OrderNumber, err)
+	}
+	if tag.RowsAffected() == 0 {
+		//language=postgresql
+		q = "SELECT EXISTS (SELECT 1 FROM \"order\" WHERE number = $1)"
+		var exists bool
+		if err = tx.QueryRow(ctx, q, recheck.OrderNumber).Scan(&exists); err != nil {
+			return fmt.Errorf("error during rechecking order %s, cause: %w", recheck.OrderNumber, err)
+		}
+		if !exists {
+			return storage.ErrItemNotFound
+		}
+		return storage.ErrOrderAlreadyProcessed
+	}
 
-	rows, err := o.pool.Query(ctx, q, id)
+	//language=postgresql
+	q = "INSERT INTO order_recheck (order_number, triggered_by, by_admin, created_at) VALUES ($1, $2, $3, $4)"
+	_, err = tx.Exec(ctx, q, recheck.OrderNumber, recheck.TriggeredBy, recheck.ByAdmin, recheck.CreatedAt)
+	if err == nil {
+		err = enqueueAccrualJobs(ctx, tx, []string{recheck.OrderNumber}, recheck.CreatedAt)
+	}
+	if err != nil {
+		return fmt.Errorf("error during rechecking order %s, cause: %w", recheck.OrderNumber, err)
+	}
+	return tx.Commit(ctx)
+}
+
+// insertOrderStatusChange records the change in the status history and notifies the listeners of all instances
+// about it, the notification is delivered when the transaction commits.
+func insertOrderStatusChange(ctx context.Context, tx pgx.Tx, userID, orderNum string, change dto.OrderStatusChange) error {
+	event := orderEventPayload{UserID: userID, Number: orderNum, Status: change.Status, Accrual: change.Accrual,
+		Attempt: change.Attempt, ObservedAt: change.ObservedAt}
+	//language=postgresql
+	q := "INSERT INTO order_status_history (order_number, status, accrual, attempt, observed_at) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	err := tx.QueryRow(ctx, q, orderNum, change.Status, change.Accrual, change.Attempt, change.ObservedAt).Scan(&event.ID)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	//language=postgresql
+	q = "SELECT pg_notify($1, $2)"
+	_, err = tx.Exec(ctx, q, orderEventsChannel, string(payload))
+	return err
+}
+
+func (o *OrderStoragePG) GetOrder(ctx context.Context, orderNum string) (dto.Order, error) {
+	//language=postgresql
+	q := "SELECT number, status, accrual, uploaded_at, attempts, last_checked_at, user_id FROM \"order\" WHERE number = $1"
+	var order dto.Order
+	err := o.pool.QueryRow(ctx, q, orderNum).Scan(&order.Number, &order.Status, &order.Accrual, &order.UploadedAt,
+		&order.Attempts, &order.LastCheckedAt, &order.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dto.Order{}, storage.ErrItemNotFound
+		}
+		return dto.Order{}, fmt.Errorf("error during recieving order %s, cause: %w", orderNum, err)
+	}
+	return order, nil
+}
+
+// GetOrderStatusHistory returns the observed statuses of the order, the oldest first.
+func (o *OrderStoragePG) GetOrderStatusHistory(ctx context.Context, orderNum string) ([]dto.OrderStatusChange, error) {
+	//language=postgresql
+	q := "SELECT status, accrual, attempt, observed_at FROM order_status_history WHERE order_number = $1 ORDER BY observed_at, id"
+	rows, err := o.pool.Query(ctx, q, orderNum)
+	if err != nil {
+		return nil, fmt.Errorf("error during recieving status history of order %s, cause: %w", orderNum, err)
+	}
+	defer rows.Close()
+
+	history := make([]dto.OrderStatusChange, 0)
+	var change dto.OrderStatusChange
+	for rows.Next() {
+		err := rows.Scan(&change.Status, &change.Accrual, &change.Attempt, &change.ObservedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error during recieving status history of order %s, cause: %w", orderNum, err)
+		}
+		history = append(history, change)
+	}
+	return history, rows.Err()
+}
+
+// orderSortColumns maps the sort fields to columns, only these columns can be used in ORDER BY.
+var orderSortColumns = map[string]string{
+	dto.OrderSortUploadedAt: "uploaded_at",
+	dto.OrderSortAccrual:    "accrual",
+}
+
+// GetOrdersByID returns orders of the user matching the filter. Pages are selected by the sort key and the
+// number of the last order of the previous page, so that the indexes on (user_id, key, number) are used.
+func (o *OrderStoragePG) GetOrdersByID(ctx context.Context, id string, filter dto.OrderFilter) ([]dto.Order, error) {
+	column, ok := orderSortColumns[filter.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown order sort field %s", filter.SortBy)
+	}
+
+	args := []interface{}{id}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+	conditions := []string{"user_id = $1"}
+	if len(filter.Statuses) > 0 {
+		conditions = append(conditions, "status = ANY("+arg(filter.Statuses)+")")
+	}
+	if filter.UploadedFrom != nil {
+		conditions = append(conditions, "uploaded_at >= "+arg(*filter.UploadedFrom))
+	}
+	if filter.UploadedTo != nil {
+		conditions = append(conditions, "uploaded_at < "+arg(*filter.UploadedTo))
+	}
+	direction, comparison := "ASC", ">"
+	if filter.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	if filter.After != nil {
+		var key interface{} = filter.After.UploadedAt
+		if filter.SortBy == dto.OrderSortAccrual {
+			key = filter.After.Accrual
+		}
+		conditions = append(conditions,
+			fmt.Sprintf("(%s, number) %s (%s, %s)", column, comparison, arg(key), arg(filter.After.Number)))
+	}
+
+	q := "SELECT number, status, accrual, uploaded_at, attempts, last_checked_at, user_id FROM \"order\" WHERE " +
+		strings.Join(conditions, " AND ") +
+		fmt.Sprintf(" ORDER BY %s %s, number %s", column, direction, direction)
+	if filter.Limit > 0 {
+		q += " LIMIT " + arg(filter.Limit)
+	}
+
+	rows, err := o.pool.Query(ctx, q, args...)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error during recieving orders of user %s, cause: %w", id, err)
 	}
+	defer rows.Close()
 
 	orders := make([]dto.Order, 0)
 	var order dto.Order
 	for rows.Next() {
-		err := rows.Scan(&order.Number, &order.Status, &order.Accrual, &order.UploadedAt, &order.UserID)
+		err := rows.Scan(&order.Number, &order.Status, &order.Accrual, &order.UploadedAt, &order.Attempts,
+			&order.LastCheckedAt, &order.UserID)
 		if err != nil {
 			return nil, fmt.Errorf("error during recieving orders of user %s, cause: %w", id, err)
 		}
 		orders = append(orders, order)
 	}
-	return orders, nil
+	return orders, rows.Err()
 }
 
 func (o *OrderStoragePG) GetBalanceByUserID(ctx context.Context, id string) (dto.Balance, error) {
 	//language=postgresql
-	q := "SELECT current, withdrawn FROM \"balance\" WHERE user_id = $1"
+	q := "SELECT current, withdrawn, debt FROM \"balance\" WHERE user_id = $1"
 	var balance dto.Balance
 
-	err := o.pool.QueryRow(ctx, q, id).Scan(&balance.Current, &balance.Withdrawn)
+	err := o.pool.QueryRow(ctx, q, id).Scan(&balance.Current, &balance.Withdrawn, &balance.Debt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// the balance is created with the first order
+		return dto.Balance{}, nil
+	}
 	if err != nil {
 		return balance, fmt.Errorf("error during recieving balance of user %s, cause: %w", id, err)
 	}
@@ -98,9 +430,26 @@ func (o *OrderStoragePG) GetBalanceByUserID(ctx context.Context, id string) (dto
 }
 
 func (o *OrderStoragePG) CreateWithdraw(ctx context.Context, id string, withdraw dto.Withdraw) error {
+	tx, err := o.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error during creating withdrawal of user %s, cause: %w", id, err)
+	}
+	defer tx.Rollback(ctx)
+
+	withdraw.ProcessedAt = time.Now()
 	//language=postgresql
 	q := "INSERT INTO withdrawal (\"order\", sum, processed_at, user_id) VALUES ($1, $2, $3, $4)"
-	_, err := o.pool.Exec(ctx, q, withdraw.Order, withdraw.Sum, time.Now(), id)
+	_, err = tx.Exec(ctx, q, withdraw.Order, withdraw.Sum, withdraw.ProcessedAt, id)
+	if err == nil {
+		err = enqueueWebhookEvent(ctx, tx, id, dto.WebhookEvent{
+			Type:      dto.WebhookEventBalanceWithdrawn,
+			CreatedAt: withdraw.ProcessedAt,
+			Data:      withdraw,
+		})
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
 
 	var pgErr *pgconn.PgError
 	if err != nil {
@@ -134,22 +483,75 @@ func (o *OrderStoragePG) GetWithdrawalsByUserID(ctx context.Context, id string)
 	return withdrawals, nil
 }
 
-func (o *OrderStoragePG) GetAllUnfinishedAccrualOrderNums(ctx context.Context) ([]string, error) {
-	q := "SELECT number FROM \"order\" WHERE status = $1"
-	rows, err := o.pool.Query(ctx, q, dto.StatusNew)
+// TryLockAccrualReconciliation takes the lock of the reconciliation shared by all instances, ok is false if another
+// instance holds it. The lock is held by a dedicated connection until unlock is called or the connection is lost.
+func (o *OrderStoragePG) TryLockAccrualReconciliation(ctx context.Context) (unlock func(), ok bool, err error) {
+	conn, err := o.pool.Acquire(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error during recieving unfinished accrual order ids cause: %w", err)
+		return nil, false, fmt.Errorf("storage error while locking accrual reconciliation, cause: %w", err)
 	}
 
+	//language=postgresql
+	q := "SELECT pg_try_advisory_lock(hashtext('accrual_reconciliation'))"
+	if err := conn.QueryRow(ctx, q).Scan(&ok); err != nil || !ok {
+		conn.Release()
+		if err != nil {
+			return nil, false, fmt.Errorf("storage error while locking accrual reconciliation, cause: %w", err)
+		}
+		return nil, false, nil
+	}
+	return func() {
+		defer conn.Release()
+		//language=postgresql
+		q := "SELECT pg_advisory_unlock(hashtext('accrual_reconciliation'))"
+		if _, err := conn.Exec(context.Background(), q); err != nil {
+			// the lock must not outlive the round, so the connection holding it is dropped
+			conn.Conn().Close(context.Background())
+		}
+	}, true, nil
+}
+
+// GetOrderNumsToReconcile returns the processed and invalid orders uploaded since the given time.
+func (o *OrderStoragePG) GetOrderNumsToReconcile(ctx context.Context, uploadedSince time.Time) ([]string, error) {
+	//language=postgresql
+	q := "SELECT number FROM \"order\" WHERE status IN ($1, $2) AND uploaded_at >= $3 ORDER BY uploaded_at"
+	rows, err := o.pool.Query(ctx, q, dto.StatusProcessed, dto.StatusInvalid, uploadedSince)
+	if err != nil {
+		return nil, fmt.Errorf("error during recieving order nums to reconcile, cause: %w", err)
+	}
+	defer rows.Close()
+
 	orders := make([]string, 0)
 	var orderNum string
 	for rows.Next() {
-		err := rows.Scan(&orderNum)
-		if err != nil {
-			return nil, fmt.Errorf("error during recieving unfinished accrual order ids cause: %w", err)
+		if err := rows.Scan(&orderNum); err != nil {
+			return nil, fmt.Errorf("error during recieving order nums to reconcile, cause: %w", err)
 		}
 		orders = append(orders, orderNum)
 	}
+	return orders, rows.Err()
+}
+
+// GetAccrualAdjustments returns the adjustments of the accruals of the user, the most recent first.
+func (o *OrderStoragePG) GetAccrualAdjustments(ctx context.Context, id string) ([]dto.AccrualAdjustment, error) {
+	//language=postgresql
+	q := `SELECT order_number, amount, previous_accrual, accrual, created_at FROM accrual_adjustment
+		WHERE user_id = $1 ORDER BY created_at DESC, id DESC`
+	rows, err := o.pool.Query(ctx, q, id)
+	if err != nil {
+		return nil, fmt.Errorf("error during recieving accrual adjustments of user %s, cause: %w", id, err)
+	}
+	defer rows.Close()
 
-	return orders, nil
+	adjustments := make([]dto.AccrualAdjustment, 0)
+	var adjustment dto.AccrualAdjustment
+	for rows.Next() {
+		err := rows.Scan(&adjustment.Order, &adjustment.Amount, &adjustment.PreviousAccrual, &adjustment.Accrual,
+			&adjustment.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error during recieving accrual adjustments of user %s, cause: %w", id, err)
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+	return adjustments, rows.Err()
 }
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type PasswordResetStoragePG struct {
+	pool *pgxpool.Pool
+}
+
+func NewPasswordResetStoragePG(pool *pgxpool.Pool) *PasswordResetStoragePG {
+	return &PasswordResetStoragePG{pool: pool}
+}
+
+// SavePasswordResetToken stores a new token and removes expired tokens of the same user.
+func (s *PasswordResetStoragePG) SavePasswordResetToken(ctx context.Context, token dto.PasswordResetToken) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("storage error while saving password reset token of user %s, cause: %w", token.UserID, err)
+	}
+	defer tx.Rollback(ctx)
+
+	//language=postgresql
+	q := "DELETE FROM password_reset_token WHERE user_id = $1 AND expires_at < $2"
+	_, err = tx.Exec(ctx, q, token.UserID, token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("storage error while saving password reset token of user %s, cause: %w", token.UserID, err)
+	}
+	//language=postgresql
+	q = "INSERT INTO password_reset_token (token_hash, user_id, created_at, expires_at) VALUES ($1, $2, $3, $4)"
+	_, err = tx.Exec(ctx, q, token.TokenHash, token.UserID, token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("storage error while saving password reset token of user %s, cause: %w", token.UserID, err)
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *PasswordResetStoragePG) GetPasswordResetToken(ctx context.Context, tokenHash string) (dto.PasswordResetToken, error) {
+	//language=postgresql
+	q := "SELECT token_hash, user_id, created_at, expires_at, used_at FROM password_reset_token WHERE token_hash = $1"
+	var token dto.PasswordResetToken
+	err := s.pool.QueryRow(ctx, q, tokenHash).
+		Scan(&token.TokenHash, &token.UserID, &token.CreatedAt, &token.ExpiresAt, &token.UsedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dto.PasswordResetToken{}, storage.ErrItemNotFound
+		}
+		return dto.PasswordResetToken{}, fmt.Errorf("storage error while getting password reset token, cause: %w", err)
+	}
+	return token, nil
+}
+
+// ResetPassword sets the password of the user of the token and marks the token as used together with all other
+// unused tokens of the same user, so that older links stop working as well. Both happen in one transaction, the
+// token is not used up if the password can't be set.
+func (s *PasswordResetStoragePG) ResetPassword(ctx context.Context, tokenHash, hashedPassword string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("storage error while resetting password, cause: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var userID string
+	//language=postgresql
+	q := "SELECT user_id FROM password_reset_token WHERE token_hash = $1 AND used_at IS NULL FOR UPDATE"
+	err = tx.QueryRow(ctx, q, tokenHash).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ErrPasswordResetTokenUsed
+	}
+	if err != nil {
+		return fmt.Errorf("storage error while resetting password, cause: %w", err)
+	}
+
+	//language=postgresql
+	q = "UPDATE password_reset_token SET used_at = now() WHERE used_at IS NULL AND user_id = $1"
+	if _, err = tx.Exec(ctx, q, userID); err != nil {
+		return fmt.Errorf("storage error while marking password reset tokens of user %s as used, cause: %w", userID, err)
+	}
+	//language=postgresql
+	q = "UPDATE \"user\" SET password = $1 WHERE id = $2"
+	tag, err := tx.Exec(ctx, q, hashedPassword, userID)
+	if err != nil {
+		return fmt.Errorf("storage error while updating password of user %s, cause: %w", userID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrItemNotFound
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type RefreshTokenStoragePG struct {
+	pool *pgxpool.Pool
+}
+
+func NewRefreshTokenStoragePG(pool *pgxpool.Pool) *RefreshTokenStoragePG {
+	return &RefreshTokenStoragePG{pool: pool}
+}
+
+func (s *RefreshTokenStoragePG) SaveRefreshToken(ctx context.Context, token dto.RefreshToken) error {
+	//language=postgresql
+	q := "INSERT INTO refresh_token (token_hash, family_id, user_id, expires_at) VALUES ($1, $2, $3, $4)"
+	_, err := s.pool.Exec(ctx, q, token.TokenHash, token.FamilyID, token.UserID, token.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("storage error while saving refresh token of user %s, cause: %w", token.UserID, err)
+	}
+	return nil
+}
+
+func (s *RefreshTokenStoragePG) GetRefreshToken(ctx context.Context, tokenHash string) (dto.RefreshToken, error) {
+	//language=postgresql
+	q := "SELECT token_hash, family_id, user_id, expires_at, used_at, revoked FROM refresh_token WHERE token_hash = $1"
+	var token dto.RefreshToken
+	err := s.pool.QueryRow(ctx, q, tokenHash).
+		Scan(&token.TokenHash, &token.FamilyID, &token.UserID, &token.ExpiresAt, &token.UsedAt, &token.Revoked)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dto.RefreshToken{}, storage.ErrItemNotFound
+		}
+		return dto.RefreshToken{}, fmt.Errorf("storage error while getting refresh token, cause: %w", err)
+	}
+	return token, nil
+}
+
+func (s *RefreshTokenStoragePG) MarkRefreshTokenUsed(ctx context.Context, tokenHash string) error {
+	//language=postgresql
+	q := "UPDATE refresh_token SET used_at = now() WHERE token_hash = $1 AND used_at IS NULL"
+	tag, err := s.pool.Exec(ctx, q, tokenHash)
+	if err != nil {
+		return fmt.Errorf("storage error while marking refresh token as used, cause: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrRefreshTokenAlreadyUsed
+	}
+	return nil
+}
+
+func (s *RefreshTokenStoragePG) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	//language=postgresql
+	q := "UPDATE refresh_token SET revoked = true WHERE family_id = $1"
+	_, err := s.pool.Exec(ctx, q, familyID)
+	if err != nil {
+		return fmt.Errorf("storage error while revoking refresh token family %s, cause: %w", familyID, err)
+	}
+	return nil
+}
+
+func (s *RefreshTokenStoragePG) RevokeUserRefreshTokens(ctx context.Context, userID string) error {
+	//language=postgresql
+	q := "UPDATE refresh_token SET revoked = true WHERE user_id = $1 AND NOT revoked"
+	_, err := s.pool.Exec(ctx, q, userID)
+	if err != nil {
+		return fmt.Errorf("storage error while revoking refresh tokens of user %s, cause: %w", userID, err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type RequestLimitStoragePG struct {
+	pool *pgxpool.Pool
+}
+
+func NewRequestLimitStoragePG(pool *pgxpool.Pool) *RequestLimitStoragePG {
+	return &RequestLimitStoragePG{pool: pool}
+}
+
+// RegisterRequest counts the request in the current window of the key, a window started before windowStart is over
+// and the counting starts again with the request.
+func (s *RequestLimitStoragePG) RegisterRequest(ctx context.Context, kind, key string, now, windowStart time.Time) (dto.RequestCount, error) {
+	//language=postgresql
+	q := `INSERT INTO request_limit AS l (kind, key, requests, window_started_at) VALUES ($1, $2, 1, $3)
+		ON CONFLICT (kind, key) DO UPDATE SET
+			requests = CASE WHEN l.window_started_at < $4 THEN 1 ELSE l.requests + 1 END,
+			window_started_at = CASE WHEN l.window_started_at < $4 THEN $3 ELSE l.window_started_at END
+		RETURNING requests, window_started_at`
+	var count dto.RequestCount
+	err := s.pool.QueryRow(ctx, q, kind, key, now, windowStart).Scan(&count.Requests, &count.WindowStartedAt)
+	if err != nil {
+		return dto.RequestCount{}, fmt.Errorf("storage error while registering request of %s %s, cause: %w", kind, key, err)
+	}
+	return count, nil
+}
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type RevokedTokenStoragePG struct {
+	pool *pgxpool.Pool
+}
+
+func NewRevokedTokenStoragePG(pool *pgxpool.Pool) *RevokedTokenStoragePG {
+	return &RevokedTokenStoragePG{pool: pool}
+}
+
+func (s *RevokedTokenStoragePG) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("storage error while revoking token %s, cause: %w", jti, err)
+	}
+	defer tx.Rollback(ctx)
+
+	//language=postgresql
+	q := "INSERT INTO revoked_token (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING"
+	if _, err = tx.Exec(ctx, q, jti, expiresAt); err != nil {
+		return fmt.Errorf("storage error while revoking token %s, cause: %w", jti, err)
+	}
+	//language=postgresql
+	q = "DELETE FROM revoked_token WHERE expires_at < now()"
+	if _, err = tx.Exec(ctx, q); err != nil {
+		return fmt.Errorf("storage error while removing expired revoked tokens, cause: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *RevokedTokenStoragePG) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	//language=postgresql
+	q := "SELECT EXISTS(SELECT 1 FROM revoked_token WHERE jti = $1 AND expires_at > now())"
+	var revoked bool
+	err := s.pool.QueryRow(ctx, q, jti).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("storage error while checking token %s revocation, cause: %w", jti, err)
+	}
+	return revoked, nil
+}
+
+func (s *RevokedTokenStoragePG) RevokeUserTokens(ctx context.Context, userID string, revokedBefore time.Time) error {
+	//language=postgresql
+	q := `INSERT INTO user_token_revocation (user_id, revoked_before) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET revoked_before = greatest(user_token_revocation.revoked_before, excluded.revoked_before)`
+	_, err := s.pool.Exec(ctx, q, userID, revokedBefore)
+	if err != nil {
+		return fmt.Errorf("storage error while revoking tokens of user %s, cause: %w", userID, err)
+	}
+	return nil
+}
+
+func (s *RevokedTokenStoragePG) GetUserTokensRevokedBefore(ctx context.Context, userID string) (time.Time, error) {
+	//language=postgresql
+	q := "SELECT revoked_before FROM user_token_revocation WHERE user_id = $1"
+	var revokedBefore time.Time
+	err := s.pool.QueryRow(ctx, q, userID).Scan(&revokedBefore)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("storage error while getting token revocation of user %s, cause: %w", userID, err)
+	}
+	return revokedBefore, nil
+}
@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type SessionStoragePG struct {
+	pool *pgxpool.Pool
+}
+
+func NewSessionStoragePG(pool *pgxpool.Pool) *SessionStoragePG {
+	return &SessionStoragePG{pool: pool}
+}
+
+func (s *SessionStoragePG) CreateSession(ctx context.Context, session dto.Session) error {
+	//language=postgresql
+	q := "INSERT INTO session (id, user_id, user_agent, ip, created_at, last_seen_at) VALUES ($1, $2, $3, $4, $5, $6)"
+	_, err := s.pool.Exec(ctx, q, session.ID, session.UserID, session.UserAgent, session.IP, session.CreatedAt, session.LastSeenAt)
+	if err != nil {
+		return fmt.Errorf("storage error while creating session of user %s, cause: %w", session.UserID, err)
+	}
+	return nil
+}
+
+// GetActiveSessions returns sessions that are not revoked and were seen after activeSince, the most recent first.
+func (s *SessionStoragePG) GetActiveSessions(ctx context.Context, userID string, activeSince time.Time) ([]dto.Session, error) {
+	//language=postgresql
+	q := `SELECT id, user_id, user_agent, ip, created_at, last_seen_at FROM session
+		WHERE user_id = $1 AND revoked_at IS NULL AND last_seen_at > $2 ORDER BY last_seen_at DESC`
+	rows, err := s.pool.Query(ctx, q, userID, activeSince)
+	if err != nil {
+		return nil, fmt.Errorf("storage error while getting sessions of user %s, cause: %w", userID, err)
+	}
+	defer rows.Close()
+
+	sessions := make([]dto.Session, 0)
+	for rows.Next() {
+		var session dto.Session
+		err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt)
+		if err != nil {
+			return nil, fmt.Errorf("storage error while getting sessions of user %s, cause: %w", userID, err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// TouchSession updates the last seen time and reports whether the session is revoked. Unknown sessions are
+// reported as revoked.
+func (s *SessionStoragePG) TouchSession(ctx context.Context, id string, lastSeenAt time.Time) (bool, error) {
+	//language=postgresql
+	q := `UPDATE session SET last_seen_at = greatest(last_seen_at, $2) WHERE id = $1
+		RETURNING revoked_at IS NOT NULL`
+	var revoked bool
+	err := s.pool.QueryRow(ctx, q, id, lastSeenAt).Scan(&revoked)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return true, nil
+		}
+		return false, fmt.Errorf("storage error while updating session %s, cause: %w", id, err)
+	}
+	return revoked, nil
+}
+
+func (s *SessionStoragePG) RevokeSession(ctx context.Context, userID, id string) error {
+	//language=postgresql
+	q := "UPDATE session SET revoked_at = now() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL"
+	tag, err := s.pool.Exec(ctx, q, id, userID)
+	if err != nil {
+		return fmt.Errorf("storage error while revoking session %s, cause: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrItemNotFound
+	}
+	return nil
+}
+
+func (s *SessionStoragePG) RevokeUserSessions(ctx context.Context, userID string) error {
+	//language=postgresql
+	q := "UPDATE session SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL"
+	_, err := s.pool.Exec(ctx, q, userID)
+	if err != nil {
+		return fmt.Errorf("storage error while revoking sessions of user %s, cause: %w", userID, err)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
 	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
@@ -17,7 +18,11 @@ type UserStoragePG struct {
 }
 
 const (
-	constraintUniqLogin = "user_login_uindex"
+	constraintUniqLogin    = "user_login_uindex"
+	constraintIdentityPkey = "user_identity_pk"
+
+	// invalidTextRepresentation is returned for ids that are not valid uuids
+	invalidTextRepresentation = "22P02"
 )
 
 func NewUserStoragePG(pool *pgxpool.Pool) *UserStoragePG {
@@ -41,10 +46,56 @@ func (s *UserStoragePG) NewUser(ctx context.Context, login, hashedPassword strin
 	return id, nil
 }
 
+// NewUserWithIdentity creates a user without password that can log in with the external identity only.
+func (s *UserStoragePG) NewUserWithIdentity(ctx context.Context, login string, identity dto.UserIdentity) (string, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("storage error while creating user %s, cause: %w", login, err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id string
+	err = tx.QueryRow(ctx, "INSERT INTO \"user\" (login, password) VALUES ($1, '') RETURNING id", login).Scan(&id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == constraintUniqLogin {
+		return "", storage.ErrorLoginIsAlreadyUsed
+	}
+	if err != nil {
+		return "", fmt.Errorf("storage error while creating user %s, cause: %w", login, err)
+	}
+
+	q := "INSERT INTO user_identity (issuer, subject, user_id, email, created_at) VALUES ($1, $2, $3, $4, now())"
+	_, err = tx.Exec(ctx, q, identity.Issuer, identity.Subject, id, identity.Email)
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == constraintIdentityPkey {
+		return "", storage.ErrIdentityAlreadyLinked
+	}
+	if err != nil {
+		return "", fmt.Errorf("storage error while linking identity of user %s, cause: %w", login, err)
+	}
+
+	return id, tx.Commit(ctx)
+}
+
+func (s *UserStoragePG) GetByIdentity(ctx context.Context, issuer, subject string) (dto.User, error) {
+	q := `SELECT u.id, u.login, u.password, u.roles, u.totp_secret, u.totp_enabled, u.email_verified_at IS NOT NULL FROM "user" u
+		JOIN user_identity i ON i.user_id = u.id WHERE i.issuer = $1 AND i.subject = $2`
+	var user dto.User
+	err := s.pool.QueryRow(ctx, q, issuer, subject).Scan(&user.ID, &user.Login, &user.HashedPassword, &user.Roles, &user.TOTPSecret, &user.TOTPEnabled,
+		&user.EmailVerified)
+	if err != nil {
+		if errors.Is(pgx.ErrNoRows, err) {
+			return dto.User{}, storage.ErrItemNotFound
+		}
+		return dto.User{}, fmt.Errorf("storage error while getting user of identity %s, cause: %w", subject, err)
+	}
+	return user, nil
+}
+
 func (s *UserStoragePG) Get(ctx context.Context, login string) (dto.User, error) {
-	q := "SELECT id, login, password from \"user\" WHERE login = $1"
+	q := "SELECT id, login, password, roles, totp_secret, totp_enabled, email_verified_at IS NOT NULL from \"user\" WHERE login = $1"
 	var user dto.User
-	err := s.pool.QueryRow(ctx, q, login).Scan(&user.ID, &user.Login, &user.HashedPassword)
+	err := s.pool.QueryRow(ctx, q, login).Scan(&user.ID, &user.Login, &user.HashedPassword, &user.Roles, &user.TOTPSecret, &user.TOTPEnabled,
+		&user.EmailVerified)
 	if err != nil {
 		if errors.Is(pgx.ErrNoRows, err) {
 			return dto.User{}, storage.ErrItemNotFound
@@ -53,3 +104,265 @@ func (s *UserStoragePG) Get(ctx context.Context, login string) (dto.User, error)
 	}
 	return user, nil
 }
+
+func (s *UserStoragePG) GetByID(ctx context.Context, id string) (dto.User, error) {
+	q := "SELECT id, login, password, roles, totp_secret, totp_enabled, email_verified_at IS NOT NULL from \"user\" WHERE id = $1"
+	var user dto.User
+	err := s.pool.QueryRow(ctx, q, id).Scan(&user.ID, &user.Login, &user.HashedPassword, &user.Roles, &user.TOTPSecret, &user.TOTPEnabled,
+		&user.EmailVerified)
+	if err != nil {
+		if errors.Is(pgx.ErrNoRows, err) {
+			return dto.User{}, storage.ErrItemNotFound
+		}
+		return dto.User{}, fmt.Errorf("storage error while getting user %s, cause: %w", id, err)
+	}
+	return user, nil
+}
+
+func (s *UserStoragePG) UpdatePassword(ctx context.Context, id, hashedPassword string) error {
+	tag, err := s.pool.Exec(ctx, "UPDATE \"user\" SET password = $1 WHERE id = $2", hashedPassword, id)
+	if err != nil {
+		return fmt.Errorf("storage error while updating password of user %s, cause: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrItemNotFound
+	}
+	return nil
+}
+
+// UpdatePasswordHash replaces the hash only if it was not changed concurrently.
+func (s *UserStoragePG) UpdatePasswordHash(ctx context.Context, id, oldHashedPassword, newHashedPassword string) error {
+	q := "UPDATE \"user\" SET password = $1 WHERE id = $2 AND password = $3"
+	_, err := s.pool.Exec(ctx, q, newHashedPassword, id, oldHashedPassword)
+	if err != nil {
+		return fmt.Errorf("storage error while updating password hash of user %s, cause: %w", id, err)
+	}
+	return nil
+}
+
+func (s *UserStoragePG) UpdateRoles(ctx context.Context, id string, roles []string) error {
+	tag, err := s.pool.Exec(ctx, "UPDATE \"user\" SET roles = $1 WHERE id = $2", roles, id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == invalidTextRepresentation {
+		return storage.ErrItemNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("storage error while updating roles of user %s, cause: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrItemNotFound
+	}
+	return nil
+}
+
+// SetTOTPSecret stores a new secret that is not enabled until EnableTOTP is called.
+func (s *UserStoragePG) SetTOTPSecret(ctx context.Context, id string, encryptedSecret []byte) error {
+	q := "UPDATE \"user\" SET totp_secret = $1, totp_enabled = false, totp_last_step = 0 WHERE id = $2 AND NOT totp_enabled"
+	tag, err := s.pool.Exec(ctx, q, encryptedSecret, id)
+	if err != nil {
+		return fmt.Errorf("storage error while setting totp secret of user %s, cause: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrItemNotFound
+	}
+	return nil
+}
+
+// EnableTOTP enables the stored secret and replaces the recovery codes of the user.
+func (s *UserStoragePG) EnableTOTP(ctx context.Context, id string, step int64, recoveryCodeHashes []string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("storage error while enabling totp of user %s, cause: %w", id, err)
+	}
+	defer tx.Rollback(ctx)
+
+	q := "UPDATE \"user\" SET totp_enabled = true, totp_last_step = $1 WHERE id = $2 AND totp_secret IS NOT NULL AND NOT totp_enabled"
+	tag, err := tx.Exec(ctx, q, step, id)
+	if err != nil {
+		return fmt.Errorf("storage error while enabling totp of user %s, cause: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrItemNotFound
+	}
+	if _, err = tx.Exec(ctx, "DELETE FROM user_recovery_code WHERE user_id = $1", id); err != nil {
+		return fmt.Errorf("storage error while deleting recovery codes of user %s, cause: %w", id, err)
+	}
+	for _, codeHash := range recoveryCodeHashes {
+		_, err = tx.Exec(ctx, "INSERT INTO user_recovery_code (user_id, code_hash) VALUES ($1, $2)", id, codeHash)
+		if err != nil {
+			return fmt.Errorf("storage error while saving recovery codes of user %s, cause: %w", id, err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *UserStoragePG) MarkEmailVerified(ctx context.Context, id string) error {
+	q := "UPDATE \"user\" SET email_verified_at = coalesce(email_verified_at, now()) WHERE id = $1 AND deleted_at IS NULL"
+	tag, err := s.pool.Exec(ctx, q, id)
+	if err != nil {
+		return fmt.Errorf("storage error while marking email of user %s as verified, cause: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrItemNotFound
+	}
+	return nil
+}
+
+// GetLoginsToNormalize returns users whose login may not be normalized yet, that is logins with upper case or
+// non-ASCII characters. Users with a known collision are skipped.
+func (s *UserStoragePG) GetLoginsToNormalize(ctx context.Context) ([]dto.User, error) {
+	q := `SELECT id, login FROM "user" u WHERE deleted_at IS NULL AND (login <> lower(login) OR login ~ '[^\x01-\x7f]')
+		AND NOT EXISTS (SELECT 1 FROM login_collision c WHERE c.user_id = u.id)`
+	rows, err := s.pool.Query(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("storage error while getting logins to normalize, cause: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]dto.User, 0)
+	for rows.Next() {
+		var user dto.User
+		if err := rows.Scan(&user.ID, &user.Login); err != nil {
+			return nil, fmt.Errorf("storage error while getting logins to normalize, cause: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// GetLogins returns the logins of all users, the anonymized logins of deleted users included.
+func (s *UserStoragePG) GetLogins(ctx context.Context) ([]dto.User, error) {
+	rows, err := s.pool.Query(ctx, `SELECT id, login FROM "user"`)
+	if err != nil {
+		return nil, fmt.Errorf("storage error while getting logins, cause: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]dto.User, 0)
+	for rows.Next() {
+		var user dto.User
+		if err := rows.Scan(&user.ID, &user.Login); err != nil {
+			return nil, fmt.Errorf("storage error while getting logins, cause: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (s *UserStoragePG) UpdateLogin(ctx context.Context, id, login string) error {
+	_, err := s.pool.Exec(ctx, "UPDATE \"user\" SET login = $1 WHERE id = $2", login, id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == constraintUniqLogin {
+		return storage.ErrorLoginIsAlreadyUsed
+	}
+	if err != nil {
+		return fmt.Errorf("storage error while updating login of user %s, cause: %w", id, err)
+	}
+	return nil
+}
+
+func (s *UserStoragePG) SaveLoginCollision(ctx context.Context, collision dto.LoginCollision) error {
+	q := `INSERT INTO login_collision (user_id, login, normalized_login, detected_at) VALUES ($1, $2, $3, now())
+		ON CONFLICT (user_id) DO UPDATE SET login = excluded.login, normalized_login = excluded.normalized_login`
+	_, err := s.pool.Exec(ctx, q, collision.UserID, collision.Login, collision.NormalizedLogin)
+	if err != nil {
+		return fmt.Errorf("storage error while saving login collision of user %s, cause: %w", collision.UserID, err)
+	}
+	return nil
+}
+
+// MarkTOTPStepUsed records the time step of an accepted code, codes of the same or earlier steps are rejected.
+func (s *UserStoragePG) MarkTOTPStepUsed(ctx context.Context, id string, step int64) error {
+	q := "UPDATE \"user\" SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1"
+	tag, err := s.pool.Exec(ctx, q, step, id)
+	if err != nil {
+		return fmt.Errorf("storage error while marking totp step of user %s, cause: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrTOTPCodeAlreadyUsed
+	}
+	return nil
+}
+
+func (s *UserStoragePG) UseRecoveryCode(ctx context.Context, id, codeHash string) error {
+	q := "UPDATE user_recovery_code SET used_at = now() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL"
+	tag, err := s.pool.Exec(ctx, q, id, codeHash)
+	if err != nil {
+		return fmt.Errorf("storage error while using recovery code of user %s, cause: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrItemNotFound
+	}
+	return nil
+}
+
+// DeleteUser anonymizes the user and removes everything that allows to log in. Orders and withdrawals are kept
+// until retainUntil, see PurgeDeletedUsers.
+func (s *UserStoragePG) DeleteUser(ctx context.Context, id, anonymizedLogin string, retainUntil time.Time) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("storage error while deleting user %s, cause: %w", id, err)
+	}
+	defer tx.Rollback(ctx)
+
+	var login string
+	q := `UPDATE "user" u SET login = $1, password = '', roles = '{}', totp_secret = NULL, totp_enabled = false,
+		deleted_at = now(), retain_until = $2
+		FROM "user" old WHERE u.id = old.id AND u.id = $3 AND u.deleted_at IS NULL
+		RETURNING old.login`
+	err = tx.QueryRow(ctx, q, anonymizedLogin, retainUntil, id).Scan(&login)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrItemNotFound
+		}
+		return fmt.Errorf("storage error while deleting user %s, cause: %w", id, err)
+	}
+
+	cleanup := []struct{ q, arg string }{
+		{"DELETE FROM user_recovery_code WHERE user_id = $1", id},
+		{"DELETE FROM refresh_token WHERE user_id = $1", id},
+		{"DELETE FROM session WHERE user_id = $1", id},
+		{"DELETE FROM api_key WHERE user_id = $1", id},
+		{"DELETE FROM user_identity WHERE user_id = $1", id},
+		{"DELETE FROM password_reset_token WHERE user_id = $1", id},
+		{"DELETE FROM webhook WHERE user_id = $1", id},
+		{"DELETE FROM request_limit WHERE kind = 'order_recheck' AND key = $1", id},
+		// lockout and request limit records are keyed by the login, which must not survive the deletion
+		{"DELETE FROM login_attempt WHERE kind = 'login' AND key = $1", login},
+		{"DELETE FROM login_lockout WHERE kind = 'login' AND key = $1", login},
+		{"DELETE FROM request_limit WHERE kind = 'password_reset' AND key = $1", login},
+	}
+	for _, c := range cleanup {
+		if _, err = tx.Exec(ctx, c.q, c.arg); err != nil {
+			return fmt.Errorf("storage error while deleting user %s, cause: %w", id, err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// PurgeDeletedUsers removes deleted users together with their financial records once the retention period is over.
+func (s *UserStoragePG) PurgeDeletedUsers(ctx context.Context, now time.Time) (int64, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("storage error while purging deleted users, cause: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	expired := "SELECT id FROM \"user\" WHERE retain_until < $1"
+	for _, q := range []string{
+		"DELETE FROM withdrawal WHERE user_id IN (" + expired + ")",
+		"DELETE FROM \"order\" WHERE user_id IN (" + expired + ")",
+	} {
+		if _, err = tx.Exec(ctx, q, now); err != nil {
+			return 0, fmt.Errorf("storage error while purging deleted users, cause: %w", err)
+		}
+	}
+	tag, err := tx.Exec(ctx, "DELETE FROM \"user\" WHERE retain_until < $1", now)
+	if err != nil {
+		return 0, fmt.Errorf("storage error while purging deleted users, cause: %w", err)
+	}
+
+	return tag.RowsAffected(), tx.Commit(ctx)
+}
@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/dto"
+	"github.com/apolsh/yapr-gophermart/internal/gophermart/storage"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type WebhookStoragePG struct {
+	pool *pgxpool.Pool
+}
+
+func NewWebhookStoragePG(pool *pgxpool.Pool) *WebhookStoragePG {
+	return &WebhookStoragePG{pool: pool}
+}
+
+func (s *WebhookStoragePG) CreateWebhook(ctx context.Context, webhook dto.Webhook, encryptedSecret []byte) (string, error) {
+	//language=postgresql
+	q := `INSERT INTO webhook (user_id, url, encrypted_secret, event_types, created_at) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+	var id string
+	err := s.pool.QueryRow(ctx, q, webhook.UserID, webhook.URL, encryptedSecret, webhook.EventTypes, webhook.CreatedAt).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("storage error while creating webhook of user %s, cause: %w", webhook.UserID, err)
+	}
+	return id, nil
+}
+
+// GetWebhooks returns the webhooks of the user, the most recent first.
+func (s *WebhookStoragePG) GetWebhooks(ctx context.Context, userID string) ([]dto.Webhook, error) {
+	//language=postgresql
+	q := "SELECT id, user_id, url, event_types, created_at FROM webhook WHERE user_id = $1 ORDER BY created_at DESC"
+	rows, err := s.pool.Query(ctx, q, userID)
+	if err != nil {
+		return nil, fmt.Errorf("storage error while getting webhooks of user %s, cause: %w", userID, err)
+	}
+	defer rows.Close()
+
+	webhooks := make([]dto.Webhook, 0)
+	for rows.Next() {
+		var webhook dto.Webhook
+		err := rows.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.EventTypes, &webhook.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("storage error while getting webhooks of user %s, cause: %w", userID, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// DeleteWebhook removes the webhook together with its pending deliveries and the delivery log.
+func (s *WebhookStoragePG) DeleteWebhook(ctx context.Context, userID, id string) error {
+	//language=postgresql
+	q := "DELETE FROM webhook WHERE id = $1 AND user_id = $2"
+	tag, err := s.pool.Exec(ctx, q, id, userID)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == invalidTextRepresentation {
+		return storage.ErrItemNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("storage error while deleting webhook %s, cause: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrItemNotFound
+	}
+	return nil
+}
+
+// GetWebhookDeliveries returns up to limit deliveries of the webhook of the user, the most recent first.
+func (s *WebhookStoragePG) GetWebhookDeliveries(ctx context.Context, userID, webhookID string, limit int) ([]dto.WebhookDelivery, error) {
+	var exists bool
+	//language=postgresql
+	q := "SELECT EXISTS(SELECT 1 FROM webhook WHERE id = $1 AND user_id = $2)"
+	err := s.pool.QueryRow(ctx, q, webhookID, userID).Scan(&exists)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == invalidTextRepresentation {
+		return nil, storage.ErrItemNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("storage error while getting deliveries of webhook %s, cause: %w", webhookID, err)
+	}
+	if !exists {
+		return nil, storage.ErrItemNotFound
+	}
+
+	//language=postgresql
+	q = `SELECT id, webhook_id, event_type, payload, status, attempts, response_status, coalesce(last_error, ''),
+		created_at, last_attempt_at, next_attempt_at
+		FROM webhook_delivery WHERE webhook_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2`
+	rows, err := s.pool.Query(ctx, q, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("storage error while getting deliveries of webhook %s, cause: %w", webhookID, err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]dto.WebhookDelivery, 0)
+	for rows.Next() {
+		var delivery dto.WebhookDelivery
+		var payload string
+		err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventType, &payload, &delivery.Status,
+			&delivery.Attempts, &delivery.ResponseStatus, &delivery.LastError, &delivery.CreatedAt,
+			&delivery.LastAttemptAt, &delivery.NextAttemptAt)
+		if err != nil {
+			return nil, fmt.Errorf("storage error while getting deliveries of webhook %s, cause: %w", webhookID, err)
+		}
+		delivery.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries that are due at now. They are leased until
+// leaseUntil, so that other instances skip them meanwhile and take them over if this instance stops.
+func (s *WebhookStoragePG) ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]dto.WebhookDelivery, error) {
+	//language=postgresql
+	q := `UPDATE webhook_delivery d SET next_attempt_at = $2
+		FROM webhook w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_delivery WHERE status = $3 AND next_attempt_at <= $1
+			ORDER BY next_attempt_at LIMIT $4 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts, d.created_at, w.user_id, w.url,
+			coalesce(w.secret, ''), w.encrypted_secret`
+	rows, err := s.pool.Query(ctx, q, now, leaseUntil, dto.WebhookDeliveryPending, limit)
+	if err != nil {
+		return nil, fmt.Errorf("storage error while claiming webhook deliveries, cause: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]dto.WebhookDelivery, 0)
+	for rows.Next() {
+		var delivery dto.WebhookDelivery
+		var payload string
+		err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventType, &payload, &delivery.Status,
+			&delivery.Attempts, &delivery.CreatedAt, &delivery.UserID, &delivery.URL, &delivery.Secret,
+			&delivery.EncryptedSecret)
+		if err != nil {
+			return nil, fmt.Errorf("storage error while claiming webhook deliveries, cause: %w", err)
+		}
+		delivery.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetWebhookSecretsToEncrypt returns the secrets of the webhooks created before the secrets were encrypted and the
+// secrets encrypted with the TOTP key.
+func (s *WebhookStoragePG) GetWebhookSecretsToEncrypt(ctx context.Context) ([]dto.WebhookSecret, error) {
+	//language=postgresql
+	q := "SELECT id, user_id, coalesce(secret, ''), encrypted_secret FROM webhook WHERE encrypted_secret IS NULL OR totp_key_encrypted"
+	rows, err := s.pool.Query(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("storage error while getting webhook secrets to encrypt, cause: %w", err)
+	}
+	defer rows.Close()
+
+	secrets := make([]dto.WebhookSecret, 0)
+	for rows.Next() {
+		var secret dto.WebhookSecret
+		if err := rows.Scan(&secret.WebhookID, &secret.UserID, &secret.Secret, &secret.EncryptedSecret); err != nil {
+			return nil, fmt.Errorf("storage error while getting webhook secrets to encrypt, cause: %w", err)
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, rows.Err()
+}
+
+// SaveEncryptedWebhookSecret replaces the secret of the webhook with the one encrypted with the webhook key.
+func (s *WebhookStoragePG) SaveEncryptedWebhookSecret(ctx context.Context, webhookID string, encryptedSecret []byte) error {
+	//language=postgresql
+	q := "UPDATE webhook SET encrypted_secret = $2, secret = NULL, totp_key_encrypted = false WHERE id = $1"
+	_, err := s.pool.Exec(ctx, q, webhookID, encryptedSecret)
+	if err != nil {
+		return fmt.Errorf("storage error while saving encrypted secret of webhook %s, cause: %w", webhookID, err)
+	}
+	return nil
+}
+
+// SaveWebhookDeliveryAttempt records the outcome of an attempt, a pending delivery is retried at its next attempt.
+func (s *WebhookStoragePG) SaveWebhookDeliveryAttempt(ctx context.Context, delivery dto.WebhookDelivery) error {
+	//language=postgresql
+	q := `UPDATE webhook_delivery SET status = $2, attempts = $3, response_status = $4, last_error = nullif($5, ''),
+		last_attempt_at = $6, next_attempt_at = $7 WHERE id = $1`
+	_, err := s.pool.Exec(ctx, q, delivery.ID, delivery.Status, delivery.Attempts, delivery.ResponseStatus,
+		delivery.LastError, delivery.LastAttemptAt, delivery.NextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("storage error while saving attempt of webhook delivery %d, cause: %w", delivery.ID, err)
+	}
+	return nil
+}
+
+// enqueueWebhookEvent queues a delivery of the event to every webhook of the user subscribed to its type. It runs in
+// the transaction of the change the event is about, so that no event is lost or published for a rolled back change.
+func enqueueWebhookEvent(ctx context.Context, tx pgx.Tx, userID string, event dto.WebhookEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	//language=postgresql
+	q := `INSERT INTO webhook_delivery (webhook_id, event_type, payload, status, attempts, created_at, next_attempt_at)
+		SELECT id, $2::text, $3, $4, 0, $5, $5 FROM webhook WHERE user_id = $1 AND $2::text = ANY(event_types)`
+	_, err = tx.Exec(ctx, q, userID, event.Type, string(payload), dto.WebhookDeliveryPending, event.CreatedAt)
+	return err
+}